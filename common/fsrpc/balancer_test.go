@@ -0,0 +1,177 @@
+package fsrpc_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/gobcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type Who struct {
+	name string
+}
+
+func (this *Who) Name_rpc(d int, reply *string) error {
+	time.Sleep(time.Duration(d) * time.Millisecond)
+	*reply = this.name
+	return nil
+}
+
+// 在 addr 上启动名为 name 的 Who 服务器，返回服务器和它的端点
+func startWho(t *testing.T, name string, addr string) (*server.S_Server, client.S_Endpoint) {
+	svr := newGobServer()
+	svr.RegisterByName(&Who{name}, "Who")
+	port := serveOn(t, svr, addr)
+	ep, _ := client.ParseEndpoint(fmt.Sprintf("127.0.0.1:%d", port))
+	return svr, ep
+}
+
+func newWhoClient(resolver client.I_Resolver) *client.S_BalancedClient {
+	return client.NewBalancedClient("who", resolver, func() client.I_ClientCodec { return gobcodec.NewClienCodec() })
+}
+
+func healthyCount(bc *client.S_BalancedClient) int {
+	n := 0
+	for _, state := range bc.Endpoints() {
+		if state.Healthy {
+			n++
+		}
+	}
+	return n
+}
+
+func callNames(bc *client.S_BalancedClient, n int) map[string]int {
+	seen := map[string]int{}
+	for i := 0; i < n; i++ {
+		var r string
+		if err := bc.Call("Who.Name_rpc", 0, &r); err != nil {
+			seen[err.Error()]++
+		} else {
+			seen[r]++
+		}
+	}
+	return seen
+}
+
+func TestBalancedClientDefaults(t *testing.T) {
+	_, ep := startWho(t, "a", "127.0.0.1:0")
+	resolver := client.NewStaticResolver()
+	resolver.Set("who", ep)
+	bc := newWhoClient(resolver)
+	bc.ResolveInterval = 0
+	bc.HealthCheckInterval = -time.Second
+	bc.HealthCheckTimeout = 0
+	bc.Start()
+	defer bc.Close()
+	if bc.ResolveInterval != 5*time.Second || bc.HealthCheckInterval != 5*time.Second || bc.HealthCheckTimeout != 3*time.Second {
+		t.Fatal("defaults are not applied:", bc.ResolveInterval, bc.HealthCheckInterval, bc.HealthCheckTimeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var r string
+	if err := bc.CallContext(ctx, "Who.Name_rpc", 0, &r); err != nil || r != "a" {
+		t.Fatal(err, r)
+	}
+}
+
+func TestBalancedClientFailover(t *testing.T) {
+	_, ep1 := startWho(t, "a", "127.0.0.1:0")
+	s2, ep2 := startWho(t, "b", "127.0.0.1:0")
+
+	path := filepath.Join(t.TempDir(), "endpoints.txt")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("# test\nwho %s %s\n", ep1, ep2)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bc := newWhoClient(client.NewFileResolver(path))
+	bc.ResolveInterval = 20 * time.Millisecond
+	bc.HealthCheckInterval = 20 * time.Millisecond
+	bc.Start()
+	defer bc.Close()
+	waitFor(t, "both endpoints healthy", func() bool { return healthyCount(bc) == 2 })
+
+	if seen := callNames(bc, 10); seen["a"] != 5 || seen["b"] != 5 {
+		t.Fatal("round robin:", seen)
+	}
+
+	// 端点断开后，调用只分配到健康的端点
+	s2.Shutdown(context.Background())
+	waitFor(t, "endpoint down", func() bool { return healthyCount(bc) == 1 })
+	if seen := callNames(bc, 4); seen["a"] != 4 {
+		t.Fatal("failover:", seen)
+	}
+
+	// 端点恢复后重新加入
+	startWho(t, "b2", ep2.String())
+	waitFor(t, "endpoint back", func() bool { return healthyCount(bc) == 2 })
+	if seen := callNames(bc, 4); seen["a"] != 2 || seen["b2"] != 2 {
+		t.Fatal("recovered:", seen)
+	}
+
+	// 端点文件修改后重新加载
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("who %s\n", ep2)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "file reload", func() bool {
+		eps := bc.Endpoints()
+		return len(eps) == 1 && eps[0].Endpoint == ep2
+	})
+	if seen := callNames(bc, 3); seen["b2"] != 3 {
+		t.Fatal("reloaded:", seen)
+	}
+}
+
+func TestBalancedClientLeastPending(t *testing.T) {
+	_, ep1 := startWho(t, "a", "127.0.0.1:0")
+	_, ep2 := startWho(t, "b", "127.0.0.1:0")
+	resolver := client.NewStaticResolver()
+	resolver.Set("who", ep1, ep2)
+	bc := newWhoClient(resolver)
+	bc.Strategy = client.LeastPending
+	bc.Start()
+	defer bc.Close()
+	waitFor(t, "both endpoints healthy", func() bool { return healthyCount(bc) == 2 })
+
+	// 一个端点上有未完成的慢请求，之后的调用都分配到另一个端点
+	var slow string
+	ch := bc.Go("Who.Name_rpc", 300, &slow, make(chan *client.S_ReqInfo, 1)).ReqCh
+	seen := callNames(bc, 6)
+	if req := <-ch; req.Error != nil {
+		t.Fatal(req.Error)
+	}
+	if seen[slow] != 0 || len(seen) != 1 {
+		t.Fatal("least pending:", slow, seen)
+	}
+}
+
+func TestBalancedClientHealthCheck(t *testing.T) {
+	_, ep := startWho(t, "a", "127.0.0.1:0")
+	resolver := client.NewStaticResolver()
+	resolver.Set("who", ep)
+	bc := newWhoClient(resolver)
+	bc.HealthCheckInterval = 20 * time.Millisecond
+	changes := make(chan bool, 16)
+	bc.OnEndpointChange = func(endpoint client.S_Endpoint, healthy bool, err error) {
+		changes <- healthy
+	}
+	bc.HealthCheck = func(ctx context.Context, c *client.S_Client) error {
+		return fmt.Errorf("sick")
+	}
+	bc.Start()
+	defer bc.Close()
+	time.Sleep(100 * time.Millisecond)
+	var r string
+	if err := bc.Call("Who.Name_rpc", 0, &r); err != client.ErrNoConnection {
+		t.Fatal("expect ErrNoConnection, got", err)
+	}
+	for len(changes) > 0 {
+		if <-changes {
+			t.Fatal("sick endpoint reported healthy")
+		}
+	}
+}
@@ -0,0 +1,503 @@
+/**
+@copyright: fantasysky 2016
+@brief: load balancing client
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 负载均衡客户端
+// 通过解析器获取服务的所有端点，与每个端点保持一条链接，调用按策略分配到健康的端点上
+// 定时检查端点健康状态，检查失败的端点不再分配调用，恢复后重新加入，如：
+//   resolver := client.NewFileResolver("endpoints.txt")
+//   newCodec := func() client.I_ClientCodec { return gobcodec.NewClienCodec() }
+//   bc := client.NewBalancedClient("game", resolver, newCodec)
+//   bc.Strategy = client.LeastPending
+//   bc.HealthCheck = func(ctx context.Context, c *client.S_Client) error {
+//       return c.CallContext(ctx, "Health.Ping_rpc", nil, nil)
+//   }
+//   bc.Start()
+//   err := bc.CallContext(ctx, "Svc.Method_rpc", arg, &reply)
+
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"fsky.pro/fslog"
+	"fsky.pro/fsrpc"
+)
+
+// 负载均衡策略
+type T_Strategy int
+
+const (
+	RoundRobin   T_Strategy = iota // 依次分配
+	LeastPending                   // 分配到未完成请求最少的端点
+)
+
+const (
+	defaultResolveInterval     = 5 * time.Second
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout  = 3 * time.Second
+)
+
+// 健康检查函数，返回错误表示端点不健康
+type F_HealthCheck func(ctx context.Context, c *S_Client) error
+
+// 端点状态
+type S_EndpointState struct {
+	Endpoint S_Endpoint
+	Healthy  bool // 已连接并且通过健康检查
+	Pending  int  // 未完成的请求数
+}
+
+// -----------------------------------------------------------------------------
+// S_BalancedClient
+// -----------------------------------------------------------------------------
+type s_Backend struct {
+	endpoint S_Endpoint
+	client   *S_Client // 已连接的客户端，未连接时为 nil
+	healthy  bool
+	checking bool // 正在拨号或者健康检查
+	removed  bool // 已经从解析结果中移除
+}
+
+type S_BalancedClient struct {
+	s_Interceptors
+	service  string
+	resolver I_Resolver
+	newCodec func() I_ClientCodec
+
+	// 以下字段必须在 Start 前设置，时间不大于 0 时使用默认值
+	Strategy            T_Strategy    // 负载均衡策略，默认 RoundRobin
+	ResolveInterval     time.Duration // 重新解析端点的间隔，默认 5 秒
+	HealthCheckInterval time.Duration // 健康检查和断线重连的间隔，默认 5 秒
+	HealthCheckTimeout  time.Duration // 单次健康检查的超时时间，默认 3 秒
+
+	// 健康检查函数，为 nil 时只检查链接是否断开
+	HealthCheck F_HealthCheck
+
+	// 拨号函数，默认为 DialTCP
+	Dial func(c *S_Client, endpoint S_Endpoint) error
+
+	// 端点健康状态改变或者被移除时回调，err 为拨号或健康检查失败的原因
+	OnEndpointChange func(endpoint S_Endpoint, healthy bool, err error)
+
+	// 调用统计，设置后应用到每个端点的客户端上
+	Metrics fsrpc.I_Metrics
+
+	mutex    sync.Mutex
+	backends map[S_Endpoint]*s_Backend
+	next     int           // 轮询时下一次调用从该序号开始查找
+	ready    chan struct{} // 有健康的端点时关闭
+	started  bool
+	closed   bool
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// service 为解析器中的服务名，newCodec 为编码解码器创建函数，每个端点使用一个编码解码器
+func NewBalancedClient(service string, resolver I_Resolver, newCodec func() I_ClientCodec) *S_BalancedClient {
+	return &S_BalancedClient{
+		service:             service,
+		resolver:            resolver,
+		newCodec:            newCodec,
+		ResolveInterval:     defaultResolveInterval,
+		HealthCheckInterval: defaultHealthCheckInterval,
+		HealthCheckTimeout:  defaultHealthCheckTimeout,
+		backends:            make(map[S_Endpoint]*s_Backend),
+		ready:               make(chan struct{}),
+		stop:                make(chan struct{}),
+	}
+}
+
+// -------------------------------------------------------------------
+// S_BalancedClient inner methods
+// -------------------------------------------------------------------
+// 客户端是否已经断开
+func _isDone(c *S_Client) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// 在锁内启动一个后台任务，已经关闭则返回 false
+func (this *S_BalancedClient) _spawn(f func()) bool {
+	if this.closed {
+		return false
+	}
+	this.wg.Add(1)
+	go func() {
+		defer this.wg.Done()
+		f()
+	}()
+	return true
+}
+
+// 根据当前是否有健康的端点，更新 ready 通道，必须在锁内调用
+func (this *S_BalancedClient) _updateReady() {
+	healthy := false
+	for _, backend := range this.backends {
+		if backend.healthy {
+			healthy = true
+			break
+		}
+	}
+	select {
+	case <-this.ready:
+		if !healthy {
+			this.ready = make(chan struct{})
+		}
+	default:
+		if healthy {
+			close(this.ready)
+		}
+	}
+}
+
+// 修改端点的健康状态
+func (this *S_BalancedClient) _setHealthy(backend *s_Backend, healthy bool, err error) {
+	this.mutex.Lock()
+	changed := backend.healthy != healthy && !backend.removed
+	backend.healthy = healthy && !backend.removed
+	this._updateReady()
+	this.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if healthy {
+		fslog.Infof("fsrpc: endpoint %s of service %q is healthy", backend.endpoint, this.service)
+	} else {
+		fslog.Warnf("fsrpc: endpoint %s of service %q is unhealthy: %v", backend.endpoint, this.service, err)
+	}
+	if this.OnEndpointChange != nil {
+		this.OnEndpointChange(backend.endpoint, healthy, err)
+	}
+}
+
+// 重新解析端点，添加新的端点，移除已经不存在的端点
+func (this *S_BalancedClient) _resolve() {
+	endpoints, err := this.resolver.Resolve(this.service)
+	if err != nil {
+		fslog.Warnf("fsrpc: resolve service %q fail: %v", this.service, err)
+		return
+	}
+	current := make(map[S_Endpoint]bool)
+	for _, ep := range endpoints {
+		current[ep] = true
+	}
+
+	removed := []*s_Backend{}
+	this.mutex.Lock()
+	for _, ep := range endpoints {
+		if _, ok := this.backends[ep]; ok {
+			continue
+		}
+		backend := &s_Backend{endpoint: ep, checking: true}
+		this.backends[ep] = backend
+		this._spawn(func() { this._check(backend) })
+	}
+	for ep, backend := range this.backends {
+		if current[ep] {
+			continue
+		}
+		delete(this.backends, ep)
+		backend.removed = true
+		if backend.healthy {
+			removed = append(removed, backend)
+		}
+		backend.healthy = false
+		if backend.client != nil {
+			backend.client.Close()
+			backend.client = nil
+		}
+	}
+	this._updateReady()
+	this.mutex.Unlock()
+
+	for _, backend := range removed {
+		if this.OnEndpointChange != nil {
+			this.OnEndpointChange(backend.endpoint, false, nil)
+		}
+	}
+}
+
+// 未连接或者链接已断开则重新拨号，然后做健康检查
+func (this *S_BalancedClient) _check(backend *s_Backend) {
+	defer func() {
+		this.mutex.Lock()
+		backend.checking = false
+		this.mutex.Unlock()
+	}()
+
+	this.mutex.Lock()
+	client := backend.client
+	this.mutex.Unlock()
+
+	if client == nil || _isDone(client) {
+		client = NewClient(this.newCodec())
+		client.SetMetrics(this.Metrics)
+		var err error
+		if this.Dial != nil {
+			err = this.Dial(client, backend.endpoint)
+		} else {
+			err = client.DialTCP(backend.endpoint.Host, backend.endpoint.Port)
+		}
+		if err != nil {
+			this._setHealthy(backend, false, err)
+			return
+		}
+		this.mutex.Lock()
+		if backend.removed || this.closed {
+			this.mutex.Unlock()
+			client.Close()
+			return
+		}
+		if backend.client != nil {
+			backend.client.Close()
+		}
+		backend.client = client
+		this.mutex.Unlock()
+	}
+
+	var err error
+	if this.HealthCheck != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), this.HealthCheckTimeout)
+		err = this.HealthCheck(ctx, client)
+		cancel()
+	}
+	this._setHealthy(backend, err == nil, err)
+}
+
+// 检查所有端点
+func (this *S_BalancedClient) _checkAll() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for _, backend := range this.backends {
+		if backend.checking {
+			continue
+		}
+		backend.checking = true
+		backend := backend
+		this._spawn(func() { this._check(backend) })
+	}
+}
+
+// 定时解析和检查
+func (this *S_BalancedClient) _loop() {
+	resolveTicker := time.NewTicker(this.ResolveInterval)
+	defer resolveTicker.Stop()
+	checkTicker := time.NewTicker(this.HealthCheckInterval)
+	defer checkTicker.Stop()
+	for {
+		select {
+		case <-this.stop:
+			return
+		case <-resolveTicker.C:
+			this._resolve()
+		case <-checkTicker.C:
+			this._checkAll()
+		}
+	}
+}
+
+// 按策略选取一个健康的客户端
+// 没有可用端点时，返回 nil 和一个有健康端点时关闭的通道
+func (this *S_BalancedClient) _pick() (*S_Client, <-chan struct{}, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.closed {
+		return nil, nil, _errShutdown
+	}
+	candidates := []*s_Backend{}
+	for _, backend := range this.backends {
+		if !backend.healthy {
+			continue
+		}
+		if !_isDone(backend.client) {
+			candidates = append(candidates, backend)
+			continue
+		}
+		// 链接已经断开，不等下一次检查，马上标记为不健康并重连
+		backend.healthy = false
+		if !backend.checking {
+			backend.checking = true
+			backend, err := backend, backend.client.doneErr
+			this._spawn(func() {
+				fslog.Warnf("fsrpc: endpoint %s of service %q is disconnected: %v", backend.endpoint, this.service, err)
+				if this.OnEndpointChange != nil {
+					this.OnEndpointChange(backend.endpoint, false, err)
+				}
+				this._check(backend)
+			})
+		}
+	}
+	this._updateReady()
+	if len(candidates) == 0 {
+		return nil, this.ready, ErrNoConnection
+	}
+
+	// map 的遍历顺序不固定，以端点排序后轮询
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].endpoint.String() < candidates[j].endpoint.String()
+	})
+	start := this.next % len(candidates)
+	this.next++
+	best := candidates[start].client
+	if this.Strategy == LeastPending {
+		// 从轮询位置开始找，未完成请求数相同时依次分配
+		least := best._pendingCount()
+		for i := 1; i < len(candidates) && least > 0; i++ {
+			c := candidates[(start+i)%len(candidates)].client
+			if n := c._pendingCount(); n < least {
+				best, least = c, n
+			}
+		}
+	}
+	return best, nil, nil
+}
+
+// -------------------------------------------------------------------
+// S_BalancedClient public methods
+// -------------------------------------------------------------------
+// 解析端点并开始拨号，之后定时重新解析和做健康检查
+func (this *S_BalancedClient) Start() {
+	this.mutex.Lock()
+	if this.started || this.closed {
+		this.mutex.Unlock()
+		return
+	}
+	this.started = true
+	// time.NewTicker 的间隔不大于 0 时会 panic
+	if this.ResolveInterval <= 0 {
+		this.ResolveInterval = defaultResolveInterval
+	}
+	if this.HealthCheckInterval <= 0 {
+		this.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if this.HealthCheckTimeout <= 0 {
+		this.HealthCheckTimeout = defaultHealthCheckTimeout
+	}
+	this.mutex.Unlock()
+
+	this._resolve()
+	this.mutex.Lock()
+	this._spawn(this._loop)
+	this.mutex.Unlock()
+}
+
+// 所有端点的当前状态
+func (this *S_BalancedClient) Endpoints() []S_EndpointState {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	states := make([]S_EndpointState, 0, len(this.backends))
+	for _, backend := range this.backends {
+		state := S_EndpointState{Endpoint: backend.endpoint, Healthy: backend.healthy}
+		if backend.client != nil {
+			state.Pending = backend.client._pendingCount()
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// 异步调用服务器方法，没有可用端点时，返回的请求信息中 Error 为 ErrNoConnection
+func (this *S_BalancedClient) Go(svrc string, arg interface{}, reply interface{}, chReq chan *S_ReqInfo) *S_ReqInfo {
+	client, _, err := this._pick()
+	if err == nil {
+		return client.Go(svrc, arg, reply, chReq)
+	}
+	reqInfo := newReqInfo(svrc, arg, reply, chReq)
+	reqInfo.Error = err
+	reqInfo.send()
+	return reqInfo
+}
+
+// 同步调用服务器方法，没有可用端点时，直接返回 ErrNoConnection
+func (this *S_BalancedClient) Call(svrc string, arg interface{}, reply interface{}) error {
+	return this.chain(this._call)(context.Background(), svrc, arg, reply)
+}
+
+func (this *S_BalancedClient) _call(_ context.Context, svrc string, arg interface{}, reply interface{}) error {
+	client, _, err := this._pick()
+	if err != nil {
+		return err
+	}
+	return client.Call(svrc, arg, reply)
+}
+
+// 带 context 的同步调用，没有可用端点时，等待有端点恢复或 ctx 结束
+func (this *S_BalancedClient) CallContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	return this.chain(this._callContext)(ctx, svrc, arg, reply)
+}
+
+func (this *S_BalancedClient) _callContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	client, err := this._wait(ctx, svrc)
+	if err != nil {
+		return err
+	}
+	return client.CallContext(ctx, svrc, arg, reply)
+}
+
+// 发起流调用，没有可用端点时，等待有端点恢复或 ctx 结束
+func (this *S_BalancedClient) Stream(ctx context.Context, svrc string, arg interface{}, reply interface{}) (*S_Stream, error) {
+	client, err := this._wait(ctx, svrc)
+	if err != nil {
+		return nil, err
+	}
+	return client.Stream(ctx, svrc, arg, reply)
+}
+
+// 等待一个可用的客户端
+func (this *S_BalancedClient) _wait(ctx context.Context, svrc string) (*S_Client, error) {
+	for {
+		client, ready, err := this._pick()
+		if client != nil {
+			return client, nil
+		}
+		if ready == nil {
+			return nil, err
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			reqInfo := newReqInfo(svrc, nil, nil, nil)
+			if deadline, ok := ctx.Deadline(); ok {
+				reqInfo.Deadline = deadline
+			}
+			return nil, reqInfo.ctxError(ctx.Err())
+		}
+	}
+}
+
+// 关闭所有链接，停止解析和健康检查
+func (this *S_BalancedClient) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return _errShutdown
+	}
+	this.closed = true
+	close(this.stop)
+	this.mutex.Unlock()
+
+	this.wg.Wait()
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for _, backend := range this.backends {
+		backend.healthy = false
+		if backend.client != nil {
+			backend.client.Close()
+			backend.client = nil
+		}
+	}
+	return nil
+}
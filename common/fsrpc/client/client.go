@@ -10,14 +10,18 @@ package client
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"fsky.pro/fserror"
 	"fsky.pro/fslog"
@@ -30,6 +34,7 @@ import (
 // 客户端结构
 type S_Client struct {
 	sync.Mutex
+	s_Interceptors
 	codec     I_ClientCodec     // 编码解码器
 	reqHeader fsrpc.S_ReqHeader // 请求头，每次发送时临时使用，以免每次都创建又销毁
 
@@ -38,6 +43,10 @@ type S_Client struct {
 	pending   map[uint64]*S_ReqInfo // 已经发送出去的请求队列
 	closing   bool
 	shutdown  bool
+	done      chan struct{} // 链接断开后关闭
+	doneErr   error         // 链接断开的原因
+
+	metrics fsrpc.I_Metrics // 调用统计，为 nil 则不统计
 }
 
 // 客户端已关闭错误
@@ -58,13 +67,24 @@ func (c *S_Client) _send(reqInfo *S_ReqInfo) {
 	}
 	reqID := c.currReqID
 	c.currReqID++
+	reqInfo.seq = reqID
 	c.pending[reqID] = reqInfo
+	if c.metrics != nil {
+		reqInfo.start = time.Now()
+		c.metrics.Begin(reqInfo.ServiceName + "." + reqInfo.MethodName)
+	}
 
 	// 编码数据并发送请求
 	c.Lock()
 	c.reqHeader.ServiceName = reqInfo.ServiceName
 	c.reqHeader.MethodName = reqInfo.MethodName
 	c.reqHeader.ReqID = reqID
+	c.reqHeader.RequestID = reqInfo.RequestID
+	c.reqHeader.Deadline = 0
+	c.reqHeader.Stream = fsrpc.StreamNone
+	if !reqInfo.Deadline.IsZero() {
+		c.reqHeader.Deadline = reqInfo.Deadline.UnixNano()
+	}
 	err := c.codec.WriteRequest(&c.reqHeader, reqInfo.Arg)
 	c.Unlock()
 	if err == nil {
@@ -77,9 +97,51 @@ func (c *S_Client) _send(reqInfo *S_ReqInfo) {
 	reqInfo = c.pending[reqID]
 	delete(c.pending, reqID)
 	reqInfo.Error = err
+	c._finish(reqInfo)
+}
+
+// 请求从等待队列中移除，记录统计
+func (c *S_Client) _metricsEnd(reqInfo *S_ReqInfo) {
+	if c.metrics == nil {
+		return
+	}
+	result := fsrpc.ResultOK
+	if _, ok := reqInfo.Error.(T_ServiceError); ok {
+		result = fsrpc.ResultError
+	} else if reqInfo.Error != nil {
+		result = fsrpc.ResultFail
+	}
+	c.metrics.End(reqInfo.ServiceName+"."+reqInfo.MethodName, result, time.Since(reqInfo.start))
+}
+
+// 请求结束，记录统计并将结果放入用户通道
+func (c *S_Client) _finish(reqInfo *S_ReqInfo) {
+	c._metricsEnd(reqInfo)
 	reqInfo.send()
 }
 
+// 取消请求，将请求从 pending 中移除
+// 返回 false 表示请求已经返回，结果已经或即将放入 reqInfo.ReqCh
+func (c *S_Client) _cancel(reqInfo *S_ReqInfo) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pending[reqInfo.seq] != reqInfo {
+		return false
+	}
+	delete(c.pending, reqInfo.seq)
+	if c.metrics != nil {
+		c.metrics.End(reqInfo.ServiceName+"."+reqInfo.MethodName, fsrpc.ResultFail, time.Since(reqInfo.start))
+	}
+	return true
+}
+
+// 未完成的请求数
+func (c *S_Client) _pendingCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pending)
+}
+
 // 接收请求
 func (c *S_Client) _receive() {
 	var err error
@@ -93,38 +155,52 @@ func (c *S_Client) _receive() {
 
 		reqID := header.ReqID
 		c.mutex.Lock()
-		defer c.mutex.Unlock()
-
 		reqInfo := c.pending[reqID]
-		delete(c.pending, reqID)
+		// 流消息不结束请求，服务方法返回后服务器会再发送一个普通回复
+		if header.Stream == fsrpc.StreamNone {
+			delete(c.pending, reqID)
+		}
+		c.mutex.Unlock()
+
+		if header.Stream != fsrpc.StreamNone {
+			c._receiveStream(reqInfo, &header)
+			continue
+		}
 
 		switch {
 		case reqInfo == nil:
-			// 通常不会出现这种情况，除非无限低概率地读取到一个错误的头
+			// 请求已经被取消或超时，或者无限低概率地读取到一个错误的头
 			c.codec.ReadResponseReply(nil)
-			fslog.Errorf("fsrpc: a request('%s.%s') has lost, ReqID=%d", header.ServiceName, header.MethodName, reqID)
+			fslog.Warnf("fsrpc: a request('%s.%s') has been cancelled or lost, ReqID=%d", header.ServiceName, header.MethodName, reqID)
+		case header.Fail == fsrpc.DeadlineExceededText:
+			// 请求到达服务器时已经超时
+			reqInfo.Error = &S_TimeoutError{reqInfo.ServiceName, reqInfo.MethodName, reqInfo.Deadline}
+			c.codec.ReadResponseReply(nil)
+			c._finish(reqInfo)
 		case header.Fail != "":
 			// 调用失败
 			reqInfo.Error = S_ServerError(header.Fail)
 			c.codec.ReadResponseReply(nil)
 			fslog.Errorf("fsrpc: server error, request('%s.%s') fail: %s", header.ServiceName, header.MethodName, header.Fail)
-			reqInfo.send()
+			c._finish(reqInfo)
 		case header.Error != "":
 			// 调用返回错误
 			reqInfo.Error = T_ServiceError(header.Error)
 			c.codec.ReadResponseReply(nil)
-			reqInfo.send()
+			c._finish(reqInfo)
 		default:
 			err := c.codec.ReadResponseReply(reqInfo.Reply)
 			if err != nil {
 				reqInfo.Error = errors.New("read reply error: " + err.Error())
 				fslog.Errorf("fsrsp: read request(%s.%s)'s reply error: %s", header.ServiceName, header.MethodName, err.Error())
 			}
-			reqInfo.send()
+			c._finish(reqInfo)
 		}
 	}
 
 	// 清理所有请求队列
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.shutdown = true
 	closing := c.closing
 	if err == io.EOF {
@@ -134,13 +210,34 @@ func (c *S_Client) _receive() {
 			err = io.ErrUnexpectedEOF
 		}
 	}
-	for _, reqInfo := range c.pending {
+	for reqID, reqInfo := range c.pending {
+		delete(c.pending, reqID)
 		reqInfo.Error = err
-		reqInfo.send()
+		c._finish(reqInfo)
 	}
 	if err != io.EOF && !closing {
 		fslog.Error("fsrpc: client protocol error: " + err.Error())
 	}
+	c.doneErr = err
+	close(c.done)
+}
+
+// 发送 HTTP CONNECT 校验，成功后在链接上初始化编码解码器
+func (c *S_Client) _connectHTTP(conn net.Conn, path string) error {
+	io.WriteString(conn, "CONNECT "+path+" HTTP/1.0\n\n")
+
+	// 获取校验返回
+	rsp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return errors.New("no respond from server")
+	}
+	if rsp.Status != fsrpc.ConnectedText {
+		// 校验失败（原则上不会出现这种情况）
+		return errors.New("verify fail")
+	}
+	c.codec.Initialize(conn)
+	go c._receive()
+	return nil
 }
 
 // -------------------------------------------------------------------
@@ -148,7 +245,7 @@ func (c *S_Client) _receive() {
 // -------------------------------------------------------------------
 // TCP 拨号
 func (c *S_Client) DialTCP(host string, port uint16) error {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("dial tcp(%s:%d) error: %v", host, port, err)
@@ -166,37 +263,44 @@ func (c *S_Client) DialHTTP(host string, port uint16) error {
 
 // HTTP 以指定路径拨号
 func (c *S_Client) DialHTTPPath(host string, port uint16, path string) error {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("dial http server(%s:%d) error: %v", host, port, err)
 	}
+	if err = c._connectHTTP(conn, path); err != nil {
+		conn.Close()
+		return fmt.Errorf("dial http server(%s:%d) error, %v", host, port, err)
+	}
+	return nil
+}
 
-	// 发送链接校验
-	io.WriteString(conn, "CONNECT "+path+" HTTP/1.0\n\n")
+// TLS 拨号，conf 可以由 NewClientTLSConfig 创建
+func (c *S_Client) DialTLS(host string, port uint16, conf *tls.Config) error {
+	conn, err := dialTLS(host, port, conf)
+	if err != nil {
+		return fmt.Errorf("dial tls(%s:%d) error: %v", host, port, err)
+	}
+	c.codec.Initialize(conn)
+	go c._receive()
+	return nil
+}
 
-	// 获取校验返回
-	rsp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
-	if err == nil {
-		// 链接成功
-		if rsp.Status == fsrpc.ConnectedText {
-			// 初始化编码解码器
-			c.codec.Initialize(conn)
-			go c._receive()
-			return nil
-		} else {
-			// 校验失败（原则上不会出现这种情况）
-			err = fmt.Errorf("dial http server(%s:%d) error, verify fail", host, port)
-		}
+// HTTPS 以指定路径拨号，服务器以 S_HttpServeArg.TLSConfig 启动
+func (c *S_Client) DialHTTPSPath(host string, port uint16, path string, conf *tls.Config) error {
+	conn, err := dialTLS(host, port, conf)
+	if err != nil {
+		return fmt.Errorf("dial https server(%s:%d) error: %v", host, port, err)
+	}
+	if err = c._connectHTTP(conn, path); err != nil {
+		conn.Close()
+		return fmt.Errorf("dial https server(%s:%d) error, %v", host, port, err)
 	}
-	err = fmt.Errorf("dial http server(%s:%d) error, no respond from server", host, port)
-	conn.Close()
-	return err
+	return nil
 }
 
-// 异步调用服务器方法
-// 注意：对于同一个 client，如果使用了 Go，就不能同时又使用 Call
-func (c *S_Client) Go(svrc string, arg interface{}, reply interface{}, chReq chan *S_ReqInfo) *S_ReqInfo {
+// 创建请求信息
+func newReqInfo(svrc string, arg interface{}, reply interface{}, chReq chan *S_ReqInfo) *S_ReqInfo {
 	svrcm := strings.Split(svrc, ".")
 	if len(svrcm) != 2 {
 		fslog.Panic("fsrpc: error service description, it must be: serviceName.methodName.")
@@ -216,17 +320,62 @@ func (c *S_Client) Go(svrc string, arg interface{}, reply interface{}, chReq cha
 		}
 	}
 	reqInfo.ReqCh = chReq
+	return reqInfo
+}
+
+// 异步调用服务器方法
+// 不携带请求 ID，服务器会为请求生成一个新的请求 ID；需要跨进程追踪请求时使用 CallContext
+// 注意：对于同一个 client，如果使用了 Go，就不能同时又使用 Call
+func (c *S_Client) Go(svrc string, arg interface{}, reply interface{}, chReq chan *S_ReqInfo) *S_ReqInfo {
+	reqInfo := newReqInfo(svrc, arg, reply, chReq)
 	c._send(reqInfo)
 	return reqInfo
 }
 
 // 同步调用服务器方法
+// 与 Go 一样不携带请求 ID，需要跨进程追踪请求时使用 CallContext
 // 注意：对于同一个 client，如果使用了 Call，就不能同时又使用 Go
 func (c *S_Client) Call(svrc string, arg interface{}, reply interface{}) error {
+	return c.chain(c._call)(context.Background(), svrc, arg, reply)
+}
+
+func (c *S_Client) _call(_ context.Context, svrc string, arg interface{}, reply interface{}) error {
 	reqInfo := <-c.Go(svrc, arg, reply, make(chan *S_ReqInfo, 1)).ReqCh
 	return reqInfo.Error
 }
 
+// 带 context 的同步调用
+// ctx 的截止时间会传给服务器，服务器收到已经超时的请求不会再调用服务方法
+// ctx 中通过 fslog.ContextWithRequestID 设置的请求 ID 也会传给服务器
+// ctx 超时返回 *S_TimeoutError，被取消返回 ctx.Err()，此时请求会从等待队列中移除，之后到达的回复将被丢弃
+func (c *S_Client) CallContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	return c.chain(c._callContext)(ctx, svrc, arg, reply)
+}
+
+func (c *S_Client) _callContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	reqInfo := newReqInfo(svrc, arg, reply, make(chan *S_ReqInfo, 1))
+	reqInfo.RequestID = fslog.RequestID(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		reqInfo.Deadline = deadline
+	}
+	if err := ctx.Err(); err != nil {
+		return reqInfo.ctxError(err)
+	}
+	c._send(reqInfo)
+
+	select {
+	case <-reqInfo.ReqCh:
+		return reqInfo.Error
+	case <-ctx.Done():
+		if !c._cancel(reqInfo) {
+			// 取消的同时请求已经返回
+			<-reqInfo.ReqCh
+			return reqInfo.Error
+		}
+		return reqInfo.ctxError(ctx.Err())
+	}
+}
+
 // 异步调用服务器方法
 // cb 必须包含两个参数：
 //    error 表示调用远程服务是否有错误
@@ -277,6 +426,11 @@ func (c *S_Client) AyncCall(svrc string, arg interface{}, cb interface{}, data i
 	return nil
 }
 
+// 设置调用统计，必须在拨号前设置
+func (c *S_Client) SetMetrics(metrics fsrpc.I_Metrics) {
+	c.metrics = metrics
+}
+
 // 关闭链接
 func (c *S_Client) Close() error {
 	c.mutex.Lock()
@@ -296,5 +450,6 @@ func NewClient(codec I_ClientCodec) *S_Client {
 	return &S_Client{
 		codec:   codec,
 		pending: make(map[uint64]*S_ReqInfo),
+		done:    make(chan struct{}),
 	}
 }
@@ -10,7 +10,9 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"time"
 )
 
 // -----------------------------------------------------------------------------
@@ -30,6 +32,30 @@ func (e T_ServiceError) Error() string {
 	return string(e)
 }
 
+// ---------------------------------------------------------
+// 请求超时错误
+// ---------------------------------------------------------
+// 请求超过截止时间仍未返回，或者到达服务器时已经超过截止时间
+// errors.Is(err, context.DeadlineExceeded) 为 true
+type S_TimeoutError struct {
+	ServiceName string
+	MethodName  string
+	Deadline    time.Time
+}
+
+func (this *S_TimeoutError) Error() string {
+	return fmt.Sprintf("fsrpc: request(%s.%s) timeout, deadline is %s",
+		this.ServiceName, this.MethodName, this.Deadline.Format("2006/01/02 15:04:05.999999"))
+}
+
+func (this *S_TimeoutError) Timeout() bool {
+	return true
+}
+
+func (this *S_TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
 // ---------------------------------------------------------
 // 发送请求错误
 // ---------------------------------------------------------
@@ -0,0 +1,68 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: client interceptors
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 客户端拦截器
+// 作用于 Call/CallContext 同步调用，Go/AyncCall 异步调用不经过拦截器
+// 拦截器按注册顺序嵌套调用，先注册的在外层，如：
+//   c.Use(client.AccessLogInterceptor(), tokenInterceptor)
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"fsky.pro/fslog"
+)
+
+// 发起调用，svrc 为 "服务名.方法名"
+type F_Invoker func(ctx context.Context, svrc string, arg interface{}, reply interface{}) error
+
+// 拦截器，next 为下一个拦截器或真正的调用
+type F_Interceptor func(ctx context.Context, svrc string, arg interface{}, reply interface{}, next F_Invoker) error
+
+// 拦截器列表
+type s_Interceptors struct {
+	interceptors []F_Interceptor
+}
+
+// 将拦截器和真正的调用串成一个调用函数
+func (this *s_Interceptors) chain(invoker F_Invoker) F_Invoker {
+	for i := len(this.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := this.interceptors[i], invoker
+		invoker = func(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+			return interceptor(ctx, svrc, arg, reply, next)
+		}
+	}
+	return invoker
+}
+
+// 注册拦截器，先注册的在外层
+// 注意：必须在发起调用前注册
+func (this *s_Interceptors) Use(interceptors ...F_Interceptor) {
+	this.interceptors = append(this.interceptors, interceptors...)
+}
+
+// -----------------------------------------------------------------------------
+// built-in interceptors
+// -----------------------------------------------------------------------------
+// 输出每个调用的日志，包括耗时和错误，ctx 中的请求 ID 和日志字段会一并输出
+func AccessLogInterceptor() F_Interceptor {
+	return func(ctx context.Context, svrc string, arg interface{}, reply interface{}, next F_Invoker) error {
+		start := time.Now()
+		err := next(ctx, svrc, arg, reply)
+		logger := fslog.FromContext(ctx).With("rpc", svrc, "cost", time.Since(start))
+		if err != nil {
+			logger.With("err", err).Warn("fsrpc: call")
+		} else {
+			logger.Info("fsrpc: call")
+		}
+		return err
+	}
+}
@@ -0,0 +1,386 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: auto reconnecting client pool
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 自动重连的客户端
+// 与同一个服务器保持 N 条链接，调用依次分配到已连接的链接上
+// 链接断开后以指数退避的间隔重新拨号，如：
+//   newCodec := func() client.I_ClientCodec { return gobcodec.NewClienCodec() }
+//   mc := client.NewManagedClient(newCodec, client.TCPDialer("127.0.0.1", 8080), 4)
+//   mc.OnStateChange = func(index int, state client.T_ConnState, err error) { ... }
+//   mc.Start()
+//   err := mc.CallContext(ctx, "Svc.Method_rpc", arg, &reply)
+
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"fsky.pro/fsrpc"
+)
+
+// 所有链接都不可用
+var ErrNoConnection = errors.New("fsrpc: no available connection")
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// -----------------------------------------------------------------------------
+// connection state
+// -----------------------------------------------------------------------------
+type T_ConnState int
+
+const (
+	StateConnecting   T_ConnState = iota // 正在拨号
+	StateConnected                       // 已连接
+	StateDisconnected                    // 拨号失败或链接断开，等待重连
+	StateClosed                          // 已关闭，不再重连
+)
+
+func (this T_ConnState) String() string {
+	switch this {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// -----------------------------------------------------------------------------
+// dialer
+// -----------------------------------------------------------------------------
+// 拨号函数，对新建的 client 调用 DialTCP/DialHTTPPath 等
+type F_Dialer func(*S_Client) error
+
+func TCPDialer(host string, port uint16) F_Dialer {
+	return func(c *S_Client) error {
+		return c.DialTCP(host, port)
+	}
+}
+
+func HTTPDialer(host string, port uint16, path string) F_Dialer {
+	return func(c *S_Client) error {
+		return c.DialHTTPPath(host, port, path)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// S_ManagedClient
+// -----------------------------------------------------------------------------
+type s_Conn struct {
+	index  int
+	state  T_ConnState
+	client *S_Client // 已连接的客户端，未连接时为 nil
+}
+
+type S_ManagedClient struct {
+	s_Interceptors
+	newCodec func() I_ClientCodec
+	dial     F_Dialer
+
+	MinBackoff time.Duration // 首次重连等待时间，默认 100 毫秒，不大于 0 时使用默认值
+	MaxBackoff time.Duration // 重连等待时间上限，默认 30 秒，不大于 0 时使用默认值，小于 MinBackoff 时等于 MinBackoff
+
+	// 链接状态改变时回调，index 为链接序号，err 为拨号失败或链接断开的原因
+	// 同一条链接的回调是按顺序调用的，不同链接的回调可能并发
+	OnStateChange func(index int, state T_ConnState, err error)
+
+	// 调用统计，设置后应用到每条链接的客户端上，必须在 Start 前设置
+	Metrics fsrpc.I_Metrics
+
+	mutex   sync.Mutex
+	conns   []*s_Conn
+	next    int           // 下一次调用从该链接开始查找
+	ready   chan struct{} // 有已连接的链接时关闭
+	started bool
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newCodec 为编码解码器创建函数，每条链接使用一个编码解码器
+// conns 为保持的链接数，小于 1 时为 1
+func NewManagedClient(newCodec func() I_ClientCodec, dial F_Dialer, conns int) *S_ManagedClient {
+	if conns < 1 {
+		conns = 1
+	}
+	mc := &S_ManagedClient{
+		newCodec:   newCodec,
+		dial:       dial,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		ready:      make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	for i := 0; i < conns; i++ {
+		mc.conns = append(mc.conns, &s_Conn{index: i, state: StateDisconnected})
+	}
+	return mc
+}
+
+// -------------------------------------------------------------------
+// S_ManagedClient inner methods
+// -------------------------------------------------------------------
+// 修改链接状态，已经关闭则返回 false
+func (this *S_ManagedClient) _setState(conn *s_Conn, client *S_Client, state T_ConnState, err error) bool {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return false
+	}
+	conn.state = state
+	conn.client = client
+
+	connected := false
+	for _, c := range this.conns {
+		if c.client != nil {
+			connected = true
+			break
+		}
+	}
+	select {
+	case <-this.ready:
+		if !connected {
+			this.ready = make(chan struct{})
+		}
+	default:
+		if connected {
+			close(this.ready)
+		}
+	}
+	this.mutex.Unlock()
+
+	if this.OnStateChange != nil {
+		this.OnStateChange(conn.index, state, err)
+	}
+	return true
+}
+
+// 在 [backoff/2, backoff) 之间随机等待，以免所有链接同时重连，关闭时返回 false
+func (this *S_ManagedClient) _wait(backoff time.Duration) bool {
+	wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-this.stop:
+		return false
+	}
+}
+
+// 保持一条链接，断开后重连
+func (this *S_ManagedClient) _keep(conn *s_Conn) {
+	defer this.wg.Done()
+	backoff := this.MinBackoff
+	for {
+		if !this._setState(conn, nil, StateConnecting, nil) {
+			return
+		}
+		client := NewClient(this.newCodec())
+		client.SetMetrics(this.Metrics)
+		err := this.dial(client)
+		if err == nil {
+			backoff = this.MinBackoff
+			if !this._setState(conn, client, StateConnected, nil) {
+				client.Close()
+				return
+			}
+			select {
+			case <-client.done:
+				err = client.doneErr
+			case <-this.stop:
+				client.Close()
+				return
+			}
+		}
+		if !this._setState(conn, nil, StateDisconnected, err) {
+			return
+		}
+		if !this._wait(backoff) {
+			return
+		}
+		backoff *= 2
+		if backoff > this.MaxBackoff {
+			backoff = this.MaxBackoff
+		}
+	}
+}
+
+// 依次选取一个已连接的客户端
+// 没有可用链接时，返回 nil 和一个有可用链接时关闭的通道
+func (this *S_ManagedClient) _pick() (*S_Client, <-chan struct{}, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.closed {
+		return nil, nil, _errShutdown
+	}
+	for i := 0; i < len(this.conns); i++ {
+		conn := this.conns[(this.next+i)%len(this.conns)]
+		if conn.client == nil {
+			continue
+		}
+		select {
+		case <-conn.client.done:
+			// 已经断开，等待重连
+			continue
+		default:
+		}
+		this.next = (conn.index + 1) % len(this.conns)
+		return conn.client, nil, nil
+	}
+	return nil, this.ready, ErrNoConnection
+}
+
+// -------------------------------------------------------------------
+// S_ManagedClient public methods
+// -------------------------------------------------------------------
+// 开始拨号，并在链接断开后自动重连
+func (this *S_ManagedClient) Start() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.started || this.closed {
+		return
+	}
+	this.started = true
+
+	// 不合法的退避时间会导致 _wait 中 rand.Int63n panic 或者不停重连
+	if this.MinBackoff <= 0 {
+		this.MinBackoff = defaultMinBackoff
+	}
+	if this.MaxBackoff <= 0 {
+		this.MaxBackoff = defaultMaxBackoff
+	}
+	if this.MaxBackoff < this.MinBackoff {
+		this.MaxBackoff = this.MinBackoff
+	}
+	for _, conn := range this.conns {
+		this.wg.Add(1)
+		go this._keep(conn)
+	}
+}
+
+// 所有链接的当前状态
+func (this *S_ManagedClient) States() []T_ConnState {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	states := make([]T_ConnState, len(this.conns))
+	for i, conn := range this.conns {
+		states[i] = conn.state
+	}
+	return states
+}
+
+// 异步调用服务器方法，没有可用链接时，返回的请求信息中 Error 为 ErrNoConnection
+func (this *S_ManagedClient) Go(svrc string, arg interface{}, reply interface{}, chReq chan *S_ReqInfo) *S_ReqInfo {
+	client, _, err := this._pick()
+	if err == nil {
+		return client.Go(svrc, arg, reply, chReq)
+	}
+	reqInfo := newReqInfo(svrc, arg, reply, chReq)
+	reqInfo.Error = err
+	reqInfo.send()
+	return reqInfo
+}
+
+// 同步调用服务器方法，没有可用链接时，直接返回 ErrNoConnection
+func (this *S_ManagedClient) Call(svrc string, arg interface{}, reply interface{}) error {
+	return this.chain(this._call)(context.Background(), svrc, arg, reply)
+}
+
+func (this *S_ManagedClient) _call(_ context.Context, svrc string, arg interface{}, reply interface{}) error {
+	client, _, err := this._pick()
+	if err != nil {
+		return err
+	}
+	return client.Call(svrc, arg, reply)
+}
+
+// 带 context 的同步调用，没有可用链接时，等待重连成功或 ctx 结束
+func (this *S_ManagedClient) CallContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	return this.chain(this._callContext)(ctx, svrc, arg, reply)
+}
+
+func (this *S_ManagedClient) _callContext(ctx context.Context, svrc string, arg interface{}, reply interface{}) error {
+	for {
+		client, ready, err := this._pick()
+		if client != nil {
+			return client.CallContext(ctx, svrc, arg, reply)
+		}
+		if ready == nil {
+			return err
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			reqInfo := newReqInfo(svrc, arg, reply, nil)
+			if deadline, ok := ctx.Deadline(); ok {
+				reqInfo.Deadline = deadline
+			}
+			return reqInfo.ctxError(ctx.Err())
+		}
+	}
+}
+
+// 发起流调用，没有可用链接时，等待重连成功或 ctx 结束
+// 流调用在选定的链接上进行，链接断开时 Recv 返回错误，需要重新发起流调用
+func (this *S_ManagedClient) Stream(ctx context.Context, svrc string, arg interface{}, reply interface{}) (*S_Stream, error) {
+	for {
+		client, ready, err := this._pick()
+		if client != nil {
+			return client.Stream(ctx, svrc, arg, reply)
+		}
+		if ready == nil {
+			return nil, err
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			reqInfo := newReqInfo(svrc, arg, reply, nil)
+			if deadline, ok := ctx.Deadline(); ok {
+				reqInfo.Deadline = deadline
+			}
+			return nil, reqInfo.ctxError(ctx.Err())
+		}
+	}
+}
+
+// 关闭所有链接，不再重连
+func (this *S_ManagedClient) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return _errShutdown
+	}
+	this.closed = true
+	close(this.stop)
+	this.mutex.Unlock()
+
+	this.wg.Wait()
+	for _, conn := range this.conns {
+		this.mutex.Lock()
+		conn.state = StateClosed
+		conn.client = nil
+		this.mutex.Unlock()
+		if this.OnStateChange != nil {
+			this.OnStateChange(conn.index, StateClosed, nil)
+		}
+	}
+	return nil
+}
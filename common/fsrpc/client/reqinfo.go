@@ -10,6 +10,7 @@
 package client
 
 import (
+	"context"
 	"time"
 )
 
@@ -25,6 +26,12 @@ type S_ReqInfo struct {
 	Reply       interface{}     // 请求回复参数
 	Error       error           // 请求失败或调用服务器服务函数返回错误
 	ReqCh       chan *S_ReqInfo // 请求返回后数据放入的通道
+	RequestID   string          // 请求追踪 ID，会传给服务器写入日志（只有带 ctx 的调用从 ctx 中获取，为空时服务器自行生成）
+	Deadline    time.Time       // 请求截止时间，零值表示没有截止时间
+
+	seq    uint64    // 请求序号，用于在 pending 中查找请求
+	stream *S_Stream // 流调用，普通调用为 nil
+	start  time.Time // 放入等待队列的时间，用于统计耗时
 }
 
 // 结束远程调用，将结构写入用户通道
@@ -46,3 +53,11 @@ func (this *S_ReqInfo) send() {
 		}()
 	}
 }
+
+// 将 context 的错误转换为请求错误
+func (this *S_ReqInfo) ctxError(err error) error {
+	if err == context.DeadlineExceeded {
+		return &S_TimeoutError{this.ServiceName, this.MethodName, this.Deadline}
+	}
+	return err
+}
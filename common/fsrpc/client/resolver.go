@@ -0,0 +1,164 @@
+/**
+@copyright: fantasysky 2016
+@brief: service endpoint resolvers
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 服务发现
+// 解析器根据服务名返回该服务的所有端点，S_BalancedClient 定时调用 Resolve 更新端点列表
+// 内置两种解析器：
+//   S_StaticResolver：固定的端点列表
+//   S_FileResolver：从文件读取端点列表，文件修改后自动重新加载，便于本地测试
+// 文件格式为每行一个服务，服务名后跟若干个 host:port，以空白分隔，# 开头为注释：
+//   # 服务名 端点...
+//   game  127.0.0.1:9001 127.0.0.1:9002
+//   login [::1]:9100
+
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// S_Endpoint
+// -----------------------------------------------------------------------------
+// 服务端点
+type S_Endpoint struct {
+	Host string
+	Port uint16
+}
+
+func (this S_Endpoint) String() string {
+	return net.JoinHostPort(this.Host, strconv.Itoa(int(this.Port)))
+}
+
+// 解析 host:port 格式的端点
+func ParseEndpoint(addr string) (ep S_Endpoint, err error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		err = fmt.Errorf("invalid port in endpoint %q", addr)
+		return
+	}
+	return S_Endpoint{host, uint16(p)}, nil
+}
+
+// 解析器
+type I_Resolver interface {
+	// 返回服务的所有端点
+	// 返回错误时，S_BalancedClient 保留原来的端点列表
+	Resolve(service string) ([]S_Endpoint, error)
+}
+
+// -----------------------------------------------------------------------------
+// S_StaticResolver
+// -----------------------------------------------------------------------------
+// 固定端点列表的解析器
+type S_StaticResolver struct {
+	mutex    sync.RWMutex
+	services map[string][]S_Endpoint
+}
+
+func NewStaticResolver() *S_StaticResolver {
+	return &S_StaticResolver{services: make(map[string][]S_Endpoint)}
+}
+
+// 设置服务的端点列表，可以在运行中修改
+func (this *S_StaticResolver) Set(service string, endpoints ...S_Endpoint) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.services[service] = append([]S_Endpoint(nil), endpoints...)
+}
+
+func (this *S_StaticResolver) Resolve(service string) ([]S_Endpoint, error) {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	endpoints, ok := this.services[service]
+	if !ok {
+		return nil, fmt.Errorf("fsrpc: service %q is not found", service)
+	}
+	return append([]S_Endpoint(nil), endpoints...), nil
+}
+
+// -----------------------------------------------------------------------------
+// S_FileResolver
+// -----------------------------------------------------------------------------
+// 从文件读取端点列表的解析器
+// 每次 Resolve 时检查文件的修改时间和大小，有变化则重新加载
+type S_FileResolver struct {
+	path string
+
+	mutex    sync.Mutex
+	modTime  time.Time
+	size     int64
+	services map[string][]S_Endpoint
+}
+
+func NewFileResolver(path string) *S_FileResolver {
+	return &S_FileResolver{path: path}
+}
+
+// 加载端点文件
+func (this *S_FileResolver) _load() (map[string][]S_Endpoint, error) {
+	file, err := os.Open(this.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	services := make(map[string][]S_Endpoint)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		endpoints := services[fields[0]]
+		for _, addr := range fields[1:] {
+			ep, err := ParseEndpoint(addr)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", this.path, line, err)
+			}
+			endpoints = append(endpoints, ep)
+		}
+		services[fields[0]] = endpoints
+	}
+	return services, scanner.Err()
+}
+
+func (this *S_FileResolver) Resolve(service string) ([]S_Endpoint, error) {
+	info, err := os.Stat(this.path)
+	if err != nil {
+		return nil, err
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.services == nil || !info.ModTime().Equal(this.modTime) || info.Size() != this.size {
+		services, err := this._load()
+		if err != nil {
+			return nil, err
+		}
+		this.services = services
+		this.modTime = info.ModTime()
+		this.size = info.Size()
+	}
+	endpoints, ok := this.services[service]
+	if !ok {
+		return nil, fmt.Errorf("fsrpc: service %q is not found in %s", service, this.path)
+	}
+	return append([]S_Endpoint(nil), endpoints...), nil
+}
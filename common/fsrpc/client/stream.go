@@ -0,0 +1,271 @@
+/**
+@copyright: fantasysky 2016
+@brief: 客户端流调用
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 流调用
+// 调用服务器的流方法（回复参数为 *server.S_Stream 的方法），服务器发送的多个消息通过 Recv 依次读取：
+//   stream, err := c.Stream(ctx, "Svc.Watch_rpc", arg, new(S_Event))
+//   defer stream.Close()
+//   for {
+//       var event S_Event
+//       if err := stream.Recv(&event); err == io.EOF {
+//           break // 服务方法正常返回
+//       } else if err != nil {
+//           return err
+//       }
+//   }
+// 双向流中，客户端通过 Send 发送后续消息（类型与请求参数相同），发送完毕后调用 CloseSend
+// 流调用不经过拦截器
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"fsky.pro/fslog"
+	"fsky.pro/fsrpc"
+)
+
+// 流已经关闭
+var ErrStreamClosed = errors.New("fsrpc: stream is closed")
+
+// 客户端读取服务器流消息太慢，接收缓冲已满，流被关闭，之后的流消息被丢弃
+var ErrStreamOverflow = errors.New("fsrpc: stream receive buffer overflow")
+
+// 客户端未读取的服务器流消息的缓冲数
+// 读取链接的协程不能等待调用 Recv，否则同一链接上的其他回复都会被阻塞
+// 因此缓冲满后关闭流并通知服务器取消流调用，Recv 读完缓冲中的消息后返回 ErrStreamOverflow
+const streamRecvBuffer = 64
+
+type S_Stream struct {
+	client  *S_Client
+	reqInfo *S_ReqInfo
+	ctx     context.Context
+	msgType reflect.Type       // 服务器流消息类型
+	msgs    chan reflect.Value // 服务器发送的流消息
+
+	closeOnce sync.Once
+	done      chan struct{} // 调用 Close、ctx 结束或者接收缓冲溢出后关闭
+
+	mutex    sync.Mutex
+	overflow bool // 接收缓冲已经溢出
+
+	ended bool  // 已经收到服务方法的返回
+	err   error // 服务方法返回的错误，正常返回为 io.EOF
+}
+
+// -------------------------------------------------------------------
+// S_Stream inner methods
+// -------------------------------------------------------------------
+// 读取一个服务器流消息，流已经关闭则丢弃
+func (this *S_Stream) _push(codec I_ClientCodec) error {
+	msgv := reflect.New(this.msgType)
+	if err := codec.ReadResponseReply(msgv.Interface()); err != nil {
+		return err
+	}
+	this.mutex.Lock()
+	overflow := this.overflow
+	this.mutex.Unlock()
+	if overflow {
+		return nil
+	}
+	select {
+	case this.msgs <- msgv:
+	case <-this.done:
+	default:
+		this.mutex.Lock()
+		this.overflow = true
+		this.mutex.Unlock()
+		// Close 会向服务器发送取消消息，不能在读取链接的协程中等待发送
+		go this.Close()
+		fslog.Warnf("fsrpc: stream('%s.%s') receive buffer overflow, close it", this.reqInfo.ServiceName, this.reqInfo.MethodName)
+	}
+	return nil
+}
+
+// 将流消息赋值给 msg
+func (this *S_Stream) _set(msg interface{}, msgv reflect.Value) error {
+	outv := reflect.ValueOf(msg)
+	if outv.Kind() != reflect.Ptr || outv.IsNil() || outv.Type() != msgv.Type() {
+		return errors.New("fsrpc: stream message receiver must be " + msgv.Type().String())
+	}
+	outv.Elem().Set(msgv.Elem())
+	return nil
+}
+
+// 收到服务方法的返回
+func (this *S_Stream) _end() {
+	this.ended = true
+	this.err = this.reqInfo.Error
+	if this.err == nil {
+		this.err = io.EOF
+	}
+	this.Close()
+}
+
+// -------------------------------------------------------------------
+// S_Stream public methods
+// -------------------------------------------------------------------
+// 读取一个服务器发送的流消息，msg 的类型必须与发起流调用时传入的 reply 相同
+// 服务方法正常返回后返回 io.EOF，返回错误时与 Call 的错误相同
+// ctx 超时返回 *S_TimeoutError，被取消返回 ctx.Err()，调用 Close 后返回 ErrStreamClosed
+// 接收缓冲溢出后，读完缓冲中的消息返回 ErrStreamOverflow
+func (this *S_Stream) Recv(msg interface{}) error {
+	select {
+	case msgv := <-this.msgs:
+		return this._set(msg, msgv)
+	default:
+	}
+	if this.ended {
+		return this.err
+	}
+
+	select {
+	case msgv := <-this.msgs:
+		return this._set(msg, msgv)
+	case <-this.reqInfo.ReqCh:
+		// 服务方法返回前发送的流消息已经全部放入 msgs
+		this._end()
+		select {
+		case msgv := <-this.msgs:
+			return this._set(msg, msgv)
+		default:
+		}
+		return this.err
+	case <-this.done:
+		this.mutex.Lock()
+		overflow := this.overflow
+		this.mutex.Unlock()
+		if overflow {
+			// 先读完溢出前缓冲的消息
+			select {
+			case msgv := <-this.msgs:
+				return this._set(msg, msgv)
+			default:
+			}
+			return ErrStreamOverflow
+		}
+		if err := this.ctx.Err(); err != nil {
+			return this.reqInfo.ctxError(err)
+		}
+		return ErrStreamClosed
+	}
+}
+
+// 向服务器发送一个流消息，类型与请求参数相同，服务器通过 S_Stream.Recv 读取
+func (this *S_Stream) Send(arg interface{}) error {
+	select {
+	case <-this.done:
+		return ErrStreamClosed
+	default:
+	}
+	return this.client._sendStream(this.reqInfo, fsrpc.StreamMsg, arg)
+}
+
+// 结束发送，服务器的 S_Stream.Recv 将返回 io.EOF
+func (this *S_Stream) CloseSend() error {
+	select {
+	case <-this.done:
+		return ErrStreamClosed
+	default:
+	}
+	return this.client._sendStream(this.reqInfo, fsrpc.StreamClose, fsrpc.EArg{})
+}
+
+// 关闭流，服务方法还没有返回时，通知服务器取消流调用
+// 之后到达的服务器流消息将被丢弃
+func (this *S_Stream) Close() error {
+	this.closeOnce.Do(func() {
+		close(this.done)
+		if this.client._cancel(this.reqInfo) {
+			if err := this.client._sendStream(this.reqInfo, fsrpc.StreamCancel, fsrpc.EArg{}); err != nil {
+				fslog.Warnf("fsrpc: cancel stream('%s.%s') fail: %v", this.reqInfo.ServiceName, this.reqInfo.MethodName, err)
+			}
+		}
+	})
+	return nil
+}
+
+// -------------------------------------------------------------------
+// S_Client stream methods
+// -------------------------------------------------------------------
+// 发送流消息，stream 为 fsrpc.StreamMsg 等
+func (c *S_Client) _sendStream(reqInfo *S_ReqInfo, stream uint32, arg interface{}) error {
+	c.mutex.Lock()
+	shutdown := c.shutdown || c.closing
+	c.mutex.Unlock()
+	if shutdown {
+		return _errShutdown
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.reqHeader = fsrpc.S_ReqHeader{
+		ServiceName: reqInfo.ServiceName,
+		MethodName:  reqInfo.MethodName,
+		ReqID:       reqInfo.seq,
+		Stream:      stream,
+	}
+	return c.codec.WriteRequest(&c.reqHeader, arg)
+}
+
+// 接收一个服务器流消息，流调用已经结束或者不是流调用则丢弃
+func (c *S_Client) _receiveStream(reqInfo *S_ReqInfo, header *fsrpc.S_RspHeader) {
+	var err error
+	if reqInfo == nil || reqInfo.stream == nil {
+		err = c.codec.ReadResponseReply(nil)
+	} else {
+		err = reqInfo.stream._push(c.codec)
+	}
+	if err != nil {
+		fslog.Errorf("fsrpc: read stream(%s.%s)'s message error: %s", header.ServiceName, header.MethodName, err.Error())
+	}
+}
+
+// 发起流调用，reply 为服务器流消息类型的指针（如 new(S_Event)），只用于确定消息类型
+// ctx 的截止时间和请求 ID 与 CallContext 一样传给服务器，ctx 结束时关闭流
+// 注意：返回的流必须调用 Close 或者读取到服务方法返回，否则会一直占用等待队列
+// 客户端需要及时调用 Recv，未读取的流消息超过 64 个时流被关闭（见 ErrStreamOverflow）
+func (c *S_Client) Stream(ctx context.Context, svrc string, arg interface{}, reply interface{}) (*S_Stream, error) {
+	msgType := reflect.TypeOf(reply)
+	if msgType == nil || msgType.Kind() != reflect.Ptr {
+		return nil, errors.New("fsrpc: stream reply must be a pointer")
+	}
+	reqInfo := newReqInfo(svrc, arg, nil, make(chan *S_ReqInfo, 1))
+	reqInfo.RequestID = fslog.RequestID(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		reqInfo.Deadline = deadline
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, reqInfo.ctxError(err)
+	}
+
+	stream := &S_Stream{
+		client:  c,
+		reqInfo: reqInfo,
+		ctx:     ctx,
+		msgType: msgType.Elem(),
+		msgs:    make(chan reflect.Value, streamRecvBuffer),
+		done:    make(chan struct{}),
+	}
+	reqInfo.stream = stream
+	c._send(reqInfo)
+
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.Close()
+			case <-stream.done:
+			}
+		}()
+	}
+	return stream, nil
+}
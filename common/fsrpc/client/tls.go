@@ -0,0 +1,83 @@
+/**
+@copyright: fantasysky 2016
+@brief: 客户端 TLS 配置
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 客户端 TLS 配置，只信任指定 CA 签发的服务器证书，服务器要求双向 TLS 时附带客户端证书，如：
+//   conf, err := client.NewClientTLSConfig(client.S_TLSOptions{
+//       CAFile:   "ca.pem",
+//       CertFile: "client.pem",
+//       KeyFile:  "client.key",
+//   })
+//   err = c.DialTLS("127.0.0.1", 9000, conf)
+//   mc := client.NewManagedClient(newCodec, client.TLSDialer("127.0.0.1", 9000, conf), 4)
+
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+
+	"fsky.pro/fsrpc"
+)
+
+// 客户端 TLS 参数
+type S_TLSOptions struct {
+	CAFile     string // 签发服务器证书的 CA（pem），只信任该 CA，为空则使用系统根证书
+	CertFile   string // 客户端证书（pem），服务器要求双向 TLS 时必须提供
+	KeyFile    string // 客户端私钥（pem）
+	ServerName string // 校验服务器证书使用的名称，为空则使用拨号的 host
+}
+
+// 根据参数创建客户端使用的 tls.Config
+func NewClientTLSConfig(opts S_TLSOptions) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+	if opts.CAFile != "" {
+		pool, err := fsrpc.LoadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = pool
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("fsrpc: tls client cert file and key file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// 拨号并完成握手，服务器证书校验失败时在拨号时返回错误
+// 注意：TLS 1.3 下服务器拒绝客户端证书的错误要在第一次读取时才返回
+func dialTLS(host string, port uint16, conf *tls.Config) (*tls.Conn, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	return tls.Dial("tcp", addr, conf)
+}
+
+// -----------------------------------------------------------------------------
+// dialer
+// -----------------------------------------------------------------------------
+func TLSDialer(host string, port uint16, conf *tls.Config) F_Dialer {
+	return func(c *S_Client) error {
+		return c.DialTLS(host, port, conf)
+	}
+}
+
+func HTTPSDialer(host string, port uint16, path string, conf *tls.Config) F_Dialer {
+	return func(c *S_Client) error {
+		return c.DialHTTPSPath(host, port, path, conf)
+	}
+}
@@ -0,0 +1,176 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: fsrpc debugging client
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// fsrpc 调试客户端，通过 jsoncodec 链接服务器（服务器必须使用 jsoncodec 编码）
+// 列出服务器上的所有服务和方法（服务器必须在启动服务前调用 svr.EnableReflection()）：
+//   fsrpccli -addr 127.0.0.1:9000 list
+//   fsrpccli -addr 127.0.0.1:9000 list Arith
+// 以 json 参数调用方法，回复以 json 格式输出：
+//   fsrpccli -addr 127.0.0.1:9000 call Arith.Add_rpc '[1, 2]'
+//   fsrpccli -addr 127.0.0.1:9000 -http /fsrpc -framing length call Arith.Add_rpc '[1, 2]'
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"fsky.pro/fsrpc"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/jsoncodec"
+	"fsky.pro/fsrpc/server"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fsrpccli [options] list [service]")
+	fmt.Fprintln(os.Stderr, "       fsrpccli [options] call service.method [json argument]")
+	fmt.Fprintln(os.Stderr, "options:")
+	flag.PrintDefaults()
+}
+
+func dial(addr string, httpPath string, framing jsoncodec.T_Framing) (*client.S_Client, error) {
+	ep, err := client.ParseEndpoint(addr)
+	if err != nil {
+		return nil, err
+	}
+	c := client.NewClient(jsoncodec.NewClientCodec(framing))
+	if httpPath != "" {
+		err = c.DialHTTPPath(ep.Host, ep.Port, httpPath)
+	} else {
+		err = c.DialTCP(ep.Host, ep.Port)
+	}
+	return c, err
+}
+
+// 输出服务描述
+func printService(w io.Writer, svrc server.S_ServiceDesc) {
+	fmt.Fprintf(w, "%s (%s)\n", svrc.Name, svrc.Type)
+	for _, method := range svrc.Methods {
+		kind := ""
+		if method.Stream {
+			kind = " [stream]"
+		}
+		fmt.Fprintf(w, "  %s.%s%s  calls=%d\n", svrc.Name, method.Name, kind, method.NumCalls)
+		fmt.Fprintf(w, "    arg:   %s", method.ArgType)
+		if method.ArgDesc != method.ArgType {
+			fmt.Fprintf(w, "  %s", method.ArgDesc)
+		}
+		fmt.Fprintln(w)
+		if !method.Stream {
+			fmt.Fprintf(w, "    reply: %s", method.ReplyType)
+			if method.ReplyDesc != method.ReplyType {
+				fmt.Fprintf(w, "  %s", method.ReplyDesc)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// 列出服务，结果输出到 w
+func list(ctx context.Context, c *client.S_Client, w io.Writer, args []string) error {
+	if len(args) > 0 {
+		var svrc server.S_ServiceDesc
+		if err := c.CallContext(ctx, server.ReflectionServiceName+".Service_rpc", args[0], &svrc); err != nil {
+			return err
+		}
+		printService(w, svrc)
+		return nil
+	}
+	var services []server.S_ServiceDesc
+	if err := c.CallContext(ctx, server.ReflectionServiceName+".Services_rpc", fsrpc.EArg{}, &services); err != nil {
+		return err
+	}
+	for _, svrc := range services {
+		printService(w, svrc)
+	}
+	return nil
+}
+
+// 调用方法，回复输出到 w
+func call(ctx context.Context, c *client.S_Client, w io.Writer, args []string) error {
+	if len(args) == 0 || !strings.Contains(args[0], ".") {
+		return errors.New("call requires service.method")
+	}
+	var arg json.RawMessage
+	if len(args) > 1 {
+		if !json.Valid([]byte(args[1])) {
+			return fmt.Errorf("invalid json argument: %s", args[1])
+		}
+		arg = json.RawMessage(args[1])
+	}
+	var reply json.RawMessage
+	if err := c.CallContext(ctx, args[0], arg, &reply); err != nil {
+		return err
+	}
+	if len(reply) == 0 {
+		fmt.Fprintln(w, "null")
+		return nil
+	}
+	buff := new(bytes.Buffer)
+	if err := json.Indent(buff, reply, "", "  "); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, buff.String())
+	return nil
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9000", "server address, host:port")
+	httpPath := flag.String("http", "", "dial with HTTP CONNECT on this path instead of TCP")
+	framing := flag.String("framing", "newline", "json framing of the server: newline or length")
+	timeout := flag.Duration("timeout", 10*time.Second, "call timeout")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var fr jsoncodec.T_Framing
+	switch *framing {
+	case "newline":
+		fr = jsoncodec.FramingNewline
+	case "length":
+		fr = jsoncodec.FramingLength
+	default:
+		fmt.Fprintf(os.Stderr, "fsrpccli: unknown framing %q\n", *framing)
+		os.Exit(2)
+	}
+
+	c, err := dial(*addr, *httpPath, fr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fsrpccli:", err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	switch flag.Arg(0) {
+	case "list":
+		err = list(ctx, c, os.Stdout, flag.Args()[1:])
+	case "call":
+		err = call(ctx, c, os.Stdout, flag.Args()[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fsrpccli:", err)
+		os.Exit(1)
+	}
+}
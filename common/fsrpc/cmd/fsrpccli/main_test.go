@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/jsoncodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type Arith int
+
+func (this *Arith) Add_rpc(arg [2]int, reply *int) error {
+	*reply = arg[0] + arg[1]
+	return nil
+}
+
+func startServer(t *testing.T, reflection bool) string {
+	svr := server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec {
+		return jsoncodec.NewServerCodec(rwc, jsoncodec.FramingNewline)
+	})
+	svr.Register(new(Arith))
+	if reflection {
+		if err := svr.EnableReflection(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go svr.Serve(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		svr.Shutdown(ctx)
+	})
+	return lis.Addr().String()
+}
+
+func TestListAndCall(t *testing.T) {
+	c, err := dial(startServer(t, true), "", jsoncodec.FramingNewline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	out := new(bytes.Buffer)
+	if err := call(ctx, c, out, []string{"Arith.Add_rpc", "[1, 2]"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "3\n" {
+		t.Fatalf("unexpected call output: %q", out.String())
+	}
+
+	out.Reset()
+	if err := list(ctx, c, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Arith (*main.Arith)", "Arith.Add_rpc  calls=1", "arg:   [2]int", "reply: *int", "_Reflection"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("list output has no %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := list(ctx, c, out, []string{"Arith"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "_Reflection") || !strings.Contains(out.String(), "Arith.Add_rpc") {
+		t.Fatalf("unexpected list output:\n%s", out.String())
+	}
+
+	if err := call(ctx, c, out, []string{"Arith.Add_rpc", "[1,"}); err == nil {
+		t.Fatal("expect invalid json error")
+	}
+	if err := call(ctx, c, out, []string{"Add_rpc"}); err == nil {
+		t.Fatal("expect service.method error")
+	}
+}
+
+func TestListWithoutReflection(t *testing.T) {
+	c, err := dial(startServer(t, false), "", jsoncodec.FramingNewline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := list(ctx, c, io.Discard, nil); err == nil {
+		t.Fatal("expect error when reflection is not enabled")
+	}
+}
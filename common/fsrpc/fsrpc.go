@@ -9,7 +9,10 @@
 // 服务器和客户端共同模块
 package fsrpc
 
-import "reflect"
+import (
+	"reflect"
+	"time"
+)
 
 // -------------------------------------------------------------------
 // 全局变量
@@ -17,6 +20,12 @@ import "reflect"
 const (
 	DefaultHTTPPath = "/fsrpc"                    // HTTP 默认访问路径
 	ConnectedText   = "200 Connected to Go FSRPC" // 链接成功消息串
+
+	// 请求到达服务器时已经超过截止时间，服务器以该消息作为调用失败信息回复
+	DeadlineExceededText = "fsrpc: request deadline exceeded"
+
+	// 服务器正在关闭，链接上新到达的请求以该消息作为调用失败信息回复
+	ShutdownText = "fsrpc: server is shutting down"
 )
 
 // -------------------------------------------------------------------
@@ -27,6 +36,9 @@ type S_ReqHeader struct {
 	ServiceName string // 请求服务对象名
 	MethodName  string // 请求的方法名称
 	ReqID       uint64 // 请求序号，用于匹配请求与回复之间的对应关系，只在客户端用到
+	RequestID   string // 请求追踪 ID，服务器处理请求时写入日志，为空则由服务器生成
+	Deadline    int64  // 请求截止时间（UnixNano），0 表示没有截止时间
+	Stream      uint32 // 流消息类型，普通请求为 StreamNone
 }
 
 // 回复头
@@ -36,8 +48,56 @@ type S_RspHeader struct {
 	ReqID       uint64 // 回复处理序列号，用于匹配请求与回复之间的对应关系，只在客户端用到
 	Error       string // 远程调用函数返回的错误信息
 	Fail        string // 调用失败错误
+	Stream      uint32 // 流消息类型，StreamMsg 表示服务器发送的流消息，流调用结束时与普通回复一样为 StreamNone
+}
+
+// 流消息类型
+// 流调用的第一个请求与普通请求一样，之后客户端与服务器以相同的 ReqID 互相发送流消息
+// 服务方法返回后，服务器以一个普通回复结束流调用
+const (
+	StreamNone   uint32 = iota // 普通请求或回复
+	StreamMsg                  // 流消息，请求头中为客户端发送的消息，回复头中为服务器发送的消息
+	StreamClose                // 客户端结束发送，只用于请求头
+	StreamCancel               // 客户端取消流调用，只用于请求头
+)
+
+// -------------------------------------------------------------------
+// 统计
+// -------------------------------------------------------------------
+// 调用结果
+type T_Result int
+
+const (
+	ResultOK    T_Result = iota // 调用成功
+	ResultError                 // 服务方法返回错误（回复头的 Error 不为空）
+	ResultFail                  // 调用失败（回复头的 Fail 不为空），客户端的链接断开、超时、取消等也算失败
+)
+
+func (this T_Result) String() string {
+	switch this {
+	case ResultOK:
+		return "ok"
+	case ResultError:
+		return "error"
+	case ResultFail:
+		return "fail"
+	}
+	return "unknown"
+}
+
+// 每个 "服务名.方法名" 的调用统计接口，服务器和客户端都可以设置
+// 服务器在读取到请求时调用 Begin，回复后调用 End
+// 服务器上不存在的服务或方法统一以 MetricsUnknown 统计，以免客户端随意构造的名称使统计项无限增长
+// 客户端在请求放入等待队列时调用 Begin，从等待队列移除时调用 End，所以客户端的 in-flight 数即等待队列的大小
+// 方法会被并发调用，实现必须是协程安全的
+type I_Metrics interface {
+	Begin(svrc string)
+	End(svrc string, result T_Result, cost time.Duration)
 }
 
+// 请求的服务或方法不存在时的统计名称
+const MetricsUnknown = "unknown"
+
 // -------------------------------------------------------------------
 // 空参数
 // -------------------------------------------------------------------
@@ -61,6 +61,10 @@ func (c *S_CobServerCodecc) WriteResponse(header *fsrpc.S_RspHeader, reply inter
 		}
 		return
 	}
+	// 调用失败时 reply 为 nil，gob 不能编码 nil，以空回复代替
+	if reply == nil {
+		reply = fsrpc.EReply{}
+	}
 	if err = c.enc.Encode(reply); err != nil {
 		// 理论上不会跑这里来，如果确实跑这里来了，需要刷新缓冲，关闭链接
 		if c.encBuff.Flush() == nil {
@@ -0,0 +1,99 @@
+package fsrpc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"fsky.pro/fsrpc"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/server"
+)
+
+type Boom struct{}
+
+func (this *Boom) Boom_rpc(arg int, reply *int) error {
+	panic("boom")
+}
+
+func (this *Boom) Echo_rpc(arg int, reply *int) error {
+	*reply = arg
+	return nil
+}
+
+func TestInterceptor(t *testing.T) {
+	var mutex sync.Mutex
+	order := []string{}
+	record := func(name string) {
+		mutex.Lock()
+		order = append(order, name)
+		mutex.Unlock()
+	}
+
+	svr := newGobServer()
+	svr.Use(
+		func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}, next server.F_Handler) (interface{}, error) {
+			record("outer")
+			return next(ctx, header, arg)
+		},
+		func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}, next server.F_Handler) (interface{}, error) {
+			record("auth")
+			switch arg.(int) {
+			case -1:
+				return nil, &server.S_FailError{Msg: "denied"}
+			case -2:
+				panic("auth panic")
+			case -3:
+				return next(ctx, header, nil)
+			case -4:
+				return next(ctx, header, "x")
+			}
+			return next(ctx, header, arg)
+		})
+	if err := svr.Register(new(Boom)); err != nil {
+		t.Fatal(err)
+	}
+	c := dialGob(t, serve(t, svr))
+	c.Use(func(ctx context.Context, svrc string, arg, reply interface{}, next client.F_Invoker) error {
+		record("client")
+		return next(ctx, svrc, arg, reply)
+	})
+
+	var r int
+	if err := c.CallContext(context.Background(), "Boom.Echo_rpc", 5, &r); err != nil || r != 5 {
+		t.Fatal(err, r)
+	}
+	mutex.Lock()
+	if strings.Join(order, ",") != "client,outer,auth" {
+		t.Fatal("unexpected interceptor order:", order)
+	}
+	mutex.Unlock()
+
+	var se client.S_ServerError
+	if err := c.Call("Boom.Echo_rpc", -1, &r); !errors.As(err, &se) || err.Error() != "denied" {
+		t.Fatal("expect denied, got", err)
+	}
+
+	// 没有注册 RecoveryInterceptor，服务方法和拦截器中的 panic 同样以调用失败回复
+	if err := c.Call("Boom.Boom_rpc", 1, &r); !errors.As(err, &se) || !strings.Contains(err.Error(), "panic: boom") {
+		t.Fatal("expect recovered panic, got", err)
+	}
+	if err := c.Call("Boom.Echo_rpc", -2, &r); !errors.As(err, &se) || !strings.Contains(err.Error(), "panic: auth panic") {
+		t.Fatal("expect recovered panic, got", err)
+	}
+
+	// 拦截器将参数替换为 nil 时以零值调用，替换为其他类型时调用失败
+	if err := c.Call("Boom.Echo_rpc", -3, &r); err != nil || r != 0 {
+		t.Fatal(err, r)
+	}
+	if err := c.Call("Boom.Echo_rpc", -4, &r); !errors.As(err, &se) || !strings.Contains(err.Error(), "must be int") {
+		t.Fatal("expect argument type error, got", err)
+	}
+
+	// 链接仍然可用
+	if err := c.Call("Boom.Echo_rpc", 7, &r); err != nil || r != 7 {
+		t.Fatal(err, r)
+	}
+}
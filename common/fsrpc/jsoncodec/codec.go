@@ -0,0 +1,299 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: 实现 json 数据打包和解包
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// json 数据打包和解包，便于非 Go 程序（脚本、lua 等）调用服务
+// 每个请求和回复都是一个 json 对象，方法名为 "服务名.方法名"：
+//
+//	请求：{"method":"Arith.Add_rpc","id":1,"request_id":"","deadline":0,"arg":[1,2]}
+//	回复：{"method":"Arith.Add_rpc","id":1,"error":"","fail":"","reply":3}
+//
+// 流调用的流消息带有 "stream" 字段，取值见 fsrpc.StreamMsg 等
+// fail 不为空表示调用失败（客户端得到 client.S_ServerError），error 不为空表示服务方法返回错误（client.T_ServiceError）
+// 支持两种分帧方式：
+//
+//	FramingNewline：每个 json 对象占一行
+//	FramingLength：每个 json 对象前加 4 字节大端长度
+package jsoncodec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"fsky.pro/fsrpc"
+)
+
+// 分帧方式
+type T_Framing int
+
+const (
+	FramingNewline T_Framing = iota // 以换行分隔
+	FramingLength                   // 以 4 字节大端长度为前缀
+)
+
+// 单个 json 对象的最大长度
+const maxFrameSize = 64 * 1024 * 1024
+
+// 请求
+type s_Request struct {
+	Method    string          `json:"method"`
+	ID        uint64          `json:"id"`
+	RequestID string          `json:"request_id,omitempty"`
+	Deadline  int64           `json:"deadline,omitempty"`
+	Stream    uint32          `json:"stream,omitempty"`
+	Arg       json.RawMessage `json:"arg,omitempty"`
+}
+
+// 回复
+type s_Response struct {
+	Method string          `json:"method"`
+	ID     uint64          `json:"id"`
+	Error  string          `json:"error,omitempty"`
+	Fail   string          `json:"fail,omitempty"`
+	Stream uint32          `json:"stream,omitempty"`
+	Reply  json.RawMessage `json:"reply,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// inner functions
+// -----------------------------------------------------------------------------
+// 读取一个 json 对象
+type s_FrameReader struct {
+	framing T_Framing
+	reader  *bufio.Reader
+	dec     *json.Decoder
+}
+
+func newFrameReader(r io.Reader, framing T_Framing) *s_FrameReader {
+	reader := bufio.NewReader(r)
+	fr := &s_FrameReader{framing: framing, reader: reader}
+	if framing == FramingNewline {
+		fr.dec = json.NewDecoder(reader)
+	}
+	return fr
+}
+
+func (this *s_FrameReader) read(v interface{}) error {
+	if this.framing == FramingNewline {
+		return this.dec.Decode(v)
+	}
+	var size uint32
+	if err := binary.Read(this.reader, binary.BigEndian, &size); err != nil {
+		return err
+	}
+	if size > maxFrameSize {
+		return fmt.Errorf("json frame size %d is too large", size)
+	}
+	buff := make([]byte, size)
+	if _, err := io.ReadFull(this.reader, buff); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(buff, v)
+}
+
+// 写入一个 json 对象，并刷新缓冲
+func writeFrame(w *bufio.Writer, framing T_Framing, v interface{}) error {
+	buff, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if framing == FramingNewline {
+		buff = append(buff, '\n')
+	} else if err = binary.Write(w, binary.BigEndian, uint32(len(buff))); err != nil {
+		return err
+	}
+	if _, err = w.Write(buff); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// 将请求或回复的参数编码为 json，nil 和空参数编码为空
+func marshalBody(body interface{}, isEmpty func(interface{}) bool) (json.RawMessage, error) {
+	if body == nil || isEmpty(body) {
+		return nil, nil
+	}
+	return json.Marshal(body)
+}
+
+// 将 json 解码到参数中，不需要参数或者没有传入参数时忽略
+func unmarshalBody(raw json.RawMessage, body interface{}, isEmpty func(interface{}) bool) error {
+	if body == nil || isEmpty(body) || len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, body)
+}
+
+// -----------------------------------------------------------------------------
+// S_JsonServerCodec
+// -----------------------------------------------------------------------------
+type S_JsonServerCodec struct {
+	rwc     io.ReadWriteCloser
+	framing T_Framing
+	reader  *s_FrameReader
+	writer  *bufio.Writer
+	arg     json.RawMessage // 读取请求头时一并读出的请求参数
+	closed  bool
+}
+
+func NewServerCodec(rwc io.ReadWriteCloser, framing T_Framing) *S_JsonServerCodec {
+	return &S_JsonServerCodec{
+		rwc:     rwc,
+		framing: framing,
+		reader:  newFrameReader(rwc, framing),
+		writer:  bufio.NewWriter(rwc),
+	}
+}
+
+// ------------------------------------------------------------------
+// 读取请求消息头
+func (c *S_JsonServerCodec) ReadRequestHeader(header *fsrpc.S_ReqHeader) error {
+	var req s_Request
+	if err := c.reader.read(&req); err != nil {
+		return err
+	}
+	index := strings.LastIndex(req.Method, ".")
+	if index < 0 {
+		header.MethodName = req.Method
+	} else {
+		header.ServiceName = req.Method[:index]
+		header.MethodName = req.Method[index+1:]
+	}
+	header.ReqID = req.ID
+	header.RequestID = req.RequestID
+	header.Deadline = req.Deadline
+	header.Stream = req.Stream
+	c.arg = req.Arg
+	return nil
+}
+
+// 读取请求消息体
+func (c *S_JsonServerCodec) ReadRequestArg(header *fsrpc.S_ReqHeader, arg interface{}) error {
+	raw := c.arg
+	c.arg = nil
+	return unmarshalBody(raw, arg, fsrpc.IsEmptyArg)
+}
+
+// 回复客户端
+func (c *S_JsonServerCodec) WriteResponse(header *fsrpc.S_RspHeader, reply interface{}) error {
+	rsp := s_Response{
+		Method: header.ServiceName + "." + header.MethodName,
+		ID:     header.ReqID,
+		Error:  header.Error,
+		Fail:   header.Fail,
+		Stream: header.Stream,
+	}
+	var err error
+	if rsp.Reply, err = marshalBody(reply, fsrpc.IsEmptyReply); err != nil {
+		// 回复参数无法编码，以调用失败回复
+		rsp.Reply = nil
+		rsp.Fail = "fsrpc: encode json's response reply fail: " + err.Error()
+	}
+	if err = writeFrame(c.writer, c.framing, &rsp); err != nil {
+		c.Close()
+		return errors.New("write json's response fail: " + err.Error())
+	}
+	return nil
+}
+
+// 关闭IO
+func (c *S_JsonServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
+
+// -----------------------------------------------------------------------------
+// S_JsonClientCodec
+// -----------------------------------------------------------------------------
+type S_JsonClientCodec struct {
+	rwc     io.ReadWriteCloser
+	framing T_Framing
+	reader  *s_FrameReader
+	writer  *bufio.Writer
+	reply   json.RawMessage // 读取回复头时一并读出的回复参数
+}
+
+func NewClientCodec(framing T_Framing) *S_JsonClientCodec {
+	return &S_JsonClientCodec{framing: framing}
+}
+
+// ------------------------------------------------------------------
+// 初始化
+func (c *S_JsonClientCodec) Initialize(rwc io.ReadWriteCloser) {
+	c.rwc = rwc
+	c.reader = newFrameReader(rwc, c.framing)
+	c.writer = bufio.NewWriter(rwc)
+}
+
+// 写入请求数据
+func (c *S_JsonClientCodec) WriteRequest(header *fsrpc.S_ReqHeader, arg interface{}) error {
+	body, err := marshalBody(arg, fsrpc.IsEmptyArg)
+	if err != nil {
+		return errors.New("fsrpc: encode json's request argument fail: " + err.Error())
+	}
+	req := s_Request{
+		Method:    header.ServiceName + "." + header.MethodName,
+		ID:        header.ReqID,
+		RequestID: header.RequestID,
+		Deadline:  header.Deadline,
+		Stream:    header.Stream,
+		Arg:       body,
+	}
+	if err = writeFrame(c.writer, c.framing, &req); err != nil {
+		c.writer.Reset(c.rwc)
+		return errors.New("fsrpc: write json's request fail: " + err.Error())
+	}
+	return nil
+}
+
+// 读取回复数据头
+func (c *S_JsonClientCodec) ReadResponseHeader(header *fsrpc.S_RspHeader) error {
+	var rsp s_Response
+	if err := c.reader.read(&rsp); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return errors.New("fsrpc: decode json's response header fail: " + err.Error())
+	}
+	if index := strings.LastIndex(rsp.Method, "."); index >= 0 {
+		header.ServiceName = rsp.Method[:index]
+		header.MethodName = rsp.Method[index+1:]
+	}
+	header.ReqID = rsp.ID
+	header.Error = rsp.Error
+	header.Fail = rsp.Fail
+	header.Stream = rsp.Stream
+	c.reply = rsp.Reply
+	return nil
+}
+
+// 读取回复数据体
+func (c *S_JsonClientCodec) ReadResponseReply(reply interface{}) error {
+	raw := c.reply
+	c.reply = nil
+	if err := unmarshalBody(raw, reply, fsrpc.IsEmptyReply); err != nil {
+		return errors.New("fsrpc: decode json's response body fail: " + err.Error())
+	}
+	return nil
+}
+
+// 关闭链接
+func (c *S_JsonClientCodec) Close() error {
+	return c.rwc.Close()
+}
@@ -0,0 +1,134 @@
+package fsrpc_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/jsoncodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type DivArg struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type Calc struct{}
+
+func (this *Calc) Div_rpc(arg DivArg, reply *int) error {
+	if arg.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = arg.A / arg.B
+	return nil
+}
+
+func serveJson(t *testing.T, framing jsoncodec.T_Framing) uint16 {
+	svr := server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec {
+		return jsoncodec.NewServerCodec(rwc, framing)
+	})
+	if err := svr.Register(new(Calc)); err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, svr)
+}
+
+// 不经过 client 直接连接 json 服务器，测试结束时关闭
+func dialJsonRaw(t *testing.T, framing jsoncodec.T_Framing) net.Conn {
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(int(serveJson(t, framing))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	return conn
+}
+
+func TestJsonCodec(t *testing.T) {
+	for _, framing := range []jsoncodec.T_Framing{jsoncodec.FramingNewline, jsoncodec.FramingLength} {
+		c := dial(t, jsoncodec.NewClientCodec(framing), serveJson(t, framing))
+		var r int
+		if err := c.Call("Calc.Div_rpc", DivArg{7, 2}, &r); err != nil || r != 3 {
+			t.Fatal(framing, err, r)
+		}
+		if err := c.Call("Calc.Div_rpc", DivArg{7, 0}, &r); err != client.T_ServiceError("divide by zero") {
+			t.Fatal(framing, "expect T_ServiceError, got", err)
+		}
+		if _, ok := c.Call("Calc.Mul_rpc", DivArg{7, 2}, &r).(client.S_ServerError); !ok {
+			t.Fatal(framing, "expect S_ServerError for unknown method")
+		}
+	}
+}
+
+// 非 Go 程序直接按行写入 json 请求
+func TestJsonCodecRawNewline(t *testing.T) {
+	conn := dialJsonRaw(t, jsoncodec.FramingNewline)
+	conn.Write([]byte(`{"method":"Calc.Div_rpc","id":7,"arg":{"a":9,"b":3}}` + "\n" +
+		`{"method":"Calc.Div_rpc","id":8,"arg":{"a":9,"b":0}}` + "\n" +
+		`{"method":"Calc.Nope_rpc","id":9}` + "\n"))
+
+	type rsp struct {
+		Method string          `json:"method"`
+		ID     uint64          `json:"id"`
+		Error  string          `json:"error"`
+		Fail   string          `json:"fail"`
+		Reply  json.RawMessage `json:"reply"`
+	}
+	reader := bufio.NewReader(conn)
+	rsps := map[uint64]rsp{}
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r rsp
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err, string(line))
+		}
+		rsps[r.ID] = r
+	}
+	if r := rsps[7]; r.Method != "Calc.Div_rpc" || string(r.Reply) != "3" || r.Error != "" || r.Fail != "" {
+		t.Fatalf("unexpected response: %+v", r)
+	}
+	if r := rsps[8]; r.Error != "divide by zero" || r.Fail != "" {
+		t.Fatalf("unexpected response: %+v", r)
+	}
+	if r := rsps[9]; r.Fail == "" || r.Error != "" {
+		t.Fatalf("unexpected response: %+v", r)
+	}
+}
+
+// 以 4 字节大端长度为前缀的请求
+func TestJsonCodecRawLength(t *testing.T) {
+	conn := dialJsonRaw(t, jsoncodec.FramingLength)
+
+	req := []byte(`{"method":"Calc.Div_rpc","id":1,"arg":{"a":8,"b":2}}`)
+	frame := make([]byte, 4+len(req))
+	binary.BigEndian.PutUint32(frame, uint32(len(req)))
+	copy(frame[4:], req)
+	conn.Write(frame)
+
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		t.Fatal(err)
+	}
+	var rsp struct {
+		ID    uint64 `json:"id"`
+		Reply int    `json:"reply"`
+	}
+	if err := json.Unmarshal(body, &rsp); err != nil || rsp.ID != 1 || rsp.Reply != 4 {
+		t.Fatal(err, string(body))
+	}
+}
@@ -0,0 +1,85 @@
+package fsrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/gobcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+// 在 addr 上启动 Arith 服务器
+func serveArith(t *testing.T, addr string) *server.S_Server {
+	svr := newGobServer()
+	svr.Register(newArith())
+	serveOn(t, svr, addr)
+	return svr
+}
+
+// 等待连接状态变为 want
+func waitState(t *testing.T, states <-chan client.T_ConnState, want client.T_ConnState) {
+	waitFor(t, "state "+want.String(), func() bool {
+		for {
+			select {
+			case state := <-states:
+				if state == want {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+}
+
+func TestManagedClientReconnect(t *testing.T) {
+	// 先占用一个端口再释放，服务器稍后在该端口上启动
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().(*net.TCPAddr)
+	lis.Close()
+
+	newCodec := func() client.I_ClientCodec { return gobcodec.NewClienCodec() }
+	mc := client.NewManagedClient(newCodec, client.TCPDialer("127.0.0.1", uint16(addr.Port)), 1)
+	mc.MinBackoff = -time.Second
+	mc.MaxBackoff = time.Millisecond
+	states := make(chan client.T_ConnState, 64)
+	mc.OnStateChange = func(index int, state client.T_ConnState, err error) {
+		states <- state
+	}
+	mc.Start()
+	defer mc.Close()
+	if mc.MinBackoff != 100*time.Millisecond || mc.MaxBackoff != mc.MinBackoff {
+		t.Fatalf("backoff is not clamped: %v, %v", mc.MinBackoff, mc.MaxBackoff)
+	}
+
+	var r int
+	if err := mc.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != client.ErrNoConnection {
+		t.Fatal("expect ErrNoConnection, got", err)
+	}
+	waitState(t, states, client.StateDisconnected)
+
+	// 服务器启动后，CallContext 等待重连成功
+	svr := serveArith(t, addr.String())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := mc.CallContext(ctx, "Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+
+	// 服务器重启后重新连上
+	svr.Shutdown(context.Background())
+	waitState(t, states, client.StateDisconnected)
+	serveArith(t, addr.String())
+	if err := mc.CallContext(ctx, "Arith.Add_rpc", [2]int{3, 4}, &r); err != nil || r != 7 {
+		t.Fatal(err, r)
+	}
+	if s := mc.States(); len(s) != 1 || s[0] != client.StateConnected {
+		t.Fatal("unexpected states:", s)
+	}
+}
@@ -0,0 +1,225 @@
+/**
+@copyright: fantasysky 2016
+@brief: fsrpc 调用统计
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// fsrpc 调用统计的默认实现，以 Prometheus 文本格式输出，如：
+//
+//	reg := metrics.NewRegistry()
+//	svr.SetMetrics(reg.Server())
+//	c.SetMetrics(reg.Client())
+//	httpService.AddHandler("/metrics", reg.ServeHTTP)
+//
+// 输出的指标（side 为 server 或 client，method 为 "服务名.方法名"，服务器上不存在的服务或方法为 "unknown"）：
+//
+//	fsrpc_requests_total{side,method,result}  请求数，result 为 ok、error、fail
+//	fsrpc_in_flight_requests{side,method}     正在处理（服务器）或者等待回复（客户端）的请求数
+//	fsrpc_client_pending_requests             客户端等待队列的总大小
+//	fsrpc_request_duration_seconds{side,method} 请求耗时直方图
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsky.pro/fsrpc"
+)
+
+// 默认的耗时直方图分桶（秒）
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	SideServer = "server"
+	SideClient = "client"
+)
+
+type s_Key struct {
+	side   string
+	method string
+}
+
+// 单个方法的统计
+type s_MethodMetrics struct {
+	results  [3]uint64 // 以 fsrpc.T_Result 为下标
+	inFlight int64
+	buckets  []uint64 // 每个分桶的计数（不累加）
+	sum      float64
+	count    uint64
+}
+
+// -----------------------------------------------------------------------------
+// S_Registry
+// -----------------------------------------------------------------------------
+type S_Registry struct {
+	buckets []float64
+
+	mutex   sync.Mutex
+	methods map[s_Key]*s_MethodMetrics
+}
+
+// 以默认分桶新建统计
+func NewRegistry() *S_Registry {
+	return NewRegistryBuckets(DefaultBuckets)
+}
+
+// 以指定的分桶（秒，升序）新建统计
+func NewRegistryBuckets(buckets []float64) *S_Registry {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &S_Registry{
+		buckets: buckets,
+		methods: make(map[s_Key]*s_MethodMetrics),
+	}
+}
+
+// -------------------------------------------------------------------
+// S_Registry inner methods
+// -------------------------------------------------------------------
+// 获取方法统计，不存在则创建，必须在锁内调用
+func (this *S_Registry) _method(side string, svrc string) *s_MethodMetrics {
+	key := s_Key{side, svrc}
+	m := this.methods[key]
+	if m == nil {
+		m = &s_MethodMetrics{buckets: make([]uint64, len(this.buckets))}
+		this.methods[key] = m
+	}
+	return m
+}
+
+func (this *S_Registry) _begin(side string, svrc string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this._method(side, svrc).inFlight++
+}
+
+func (this *S_Registry) _end(side string, svrc string, result fsrpc.T_Result, cost time.Duration) {
+	seconds := cost.Seconds()
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	m := this._method(side, svrc)
+	m.inFlight--
+	if result >= 0 && int(result) < len(m.results) {
+		m.results[result]++
+	}
+	m.sum += seconds
+	m.count++
+	if index := sort.SearchFloat64s(this.buckets, seconds); index < len(this.buckets) {
+		m.buckets[index]++
+	}
+}
+
+// -------------------------------------------------------------------
+// S_Registry public methods
+// -------------------------------------------------------------------
+// 服务器使用的统计接口
+func (this *S_Registry) Server() fsrpc.I_Metrics {
+	return &s_Recorder{this, SideServer}
+}
+
+// 客户端使用的统计接口，多个客户端可以共用
+func (this *S_Registry) Client() fsrpc.I_Metrics {
+	return &s_Recorder{this, SideClient}
+}
+
+// 以 Prometheus 文本格式输出所有统计
+func (this *S_Registry) WriteText(w io.Writer) error {
+	this.mutex.Lock()
+	keys := make([]s_Key, 0, len(this.methods))
+	methods := make(map[s_Key]s_MethodMetrics, len(this.methods))
+	for key, m := range this.methods {
+		keys = append(keys, key)
+		copied := *m
+		copied.buckets = append([]uint64(nil), m.buckets...)
+		methods[key] = copied
+	}
+	this.mutex.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].side != keys[j].side {
+			return keys[i].side < keys[j].side
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP fsrpc_requests_total Total number of finished fsrpc requests.")
+	fmt.Fprintln(bw, "# TYPE fsrpc_requests_total counter")
+	for _, key := range keys {
+		m := methods[key]
+		for result, n := range m.results {
+			fmt.Fprintf(bw, "fsrpc_requests_total{%s,result=%q} %d\n", labels(key), fsrpc.T_Result(result).String(), n)
+		}
+	}
+
+	fmt.Fprintln(bw, "# HELP fsrpc_in_flight_requests Number of requests being handled by the server or waiting for replies on the client.")
+	fmt.Fprintln(bw, "# TYPE fsrpc_in_flight_requests gauge")
+	var pending int64
+	for _, key := range keys {
+		m := methods[key]
+		fmt.Fprintf(bw, "fsrpc_in_flight_requests{%s} %d\n", labels(key), m.inFlight)
+		if key.side == SideClient {
+			pending += m.inFlight
+		}
+	}
+
+	fmt.Fprintln(bw, "# HELP fsrpc_client_pending_requests Total size of the pending maps of all clients.")
+	fmt.Fprintln(bw, "# TYPE fsrpc_client_pending_requests gauge")
+	fmt.Fprintf(bw, "fsrpc_client_pending_requests %d\n", pending)
+
+	fmt.Fprintln(bw, "# HELP fsrpc_request_duration_seconds Latency of fsrpc requests.")
+	fmt.Fprintln(bw, "# TYPE fsrpc_request_duration_seconds histogram")
+	for _, key := range keys {
+		m := methods[key]
+		var cumulative uint64
+		for i, bound := range this.buckets {
+			cumulative += m.buckets[i]
+			fmt.Fprintf(bw, "fsrpc_request_duration_seconds_bucket{%s,le=%q} %d\n", labels(key), formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(bw, "fsrpc_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels(key), m.count)
+		fmt.Fprintf(bw, "fsrpc_request_duration_seconds_sum{%s} %s\n", labels(key), formatFloat(m.sum))
+		fmt.Fprintf(bw, "fsrpc_request_duration_seconds_count{%s} %d\n", labels(key), m.count)
+	}
+	return bw.Flush()
+}
+
+// http 处理函数，可以挂到 fshttp 服务上：service.AddHandler("/metrics", reg.ServeHTTP)
+func (this *S_Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	this.WriteText(w)
+}
+
+// -----------------------------------------------------------------------------
+// inner
+// -----------------------------------------------------------------------------
+// 实现 fsrpc.I_Metrics
+type s_Recorder struct {
+	registry *S_Registry
+	side     string
+}
+
+func (this *s_Recorder) Begin(svrc string) {
+	this.registry._begin(this.side, svrc)
+}
+
+func (this *s_Recorder) End(svrc string, result fsrpc.T_Result, cost time.Duration) {
+	this.registry._end(this.side, svrc, result, cost)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(key s_Key) string {
+	return `side="` + key.side + `",method="` + labelEscaper.Replace(key.method) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,91 @@
+package fsrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/metrics"
+)
+
+type Flaky struct{}
+
+func (this *Flaky) Ok_rpc(arg int, reply *int) error {
+	*reply = arg
+	return nil
+}
+
+func (this *Flaky) Bad_rpc(arg int, reply *int) error {
+	return errors.New("bad")
+}
+
+func (this *Flaky) Boom_rpc(arg int, reply *int) error {
+	panic("boom")
+}
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	svr := newGobServer()
+	svr.SetMetrics(reg.Server())
+	svr.Register(new(Flaky))
+	svr.Register(newArith())
+	c := dialGob(t, serve(t, svr))
+	c.SetMetrics(reg.Client())
+
+	var r int
+	c.Call("Flaky.Ok_rpc", 1, &r)
+	c.Call("Flaky.Ok_rpc", 1, &r)
+	c.Call("Flaky.Bad_rpc", 1, &r)
+	c.Call("Flaky.Boom_rpc", 1, &r)
+	c.Call("Flaky.Nope_rpc", 1, &r)
+	c.Call("Nope.Ok_rpc", 1, &r)
+	c.Call("Nope2.Ok_rpc", 1, &r)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	c.CallContext(ctx, "Arith.Slow_rpc", 100, &r)
+	cancel()
+	// 等待服务器处理完超时的请求
+	time.Sleep(200 * time.Millisecond)
+
+	text := new(bytes.Buffer)
+	if err := reg.WriteText(text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`fsrpc_requests_total{side="server",method="Flaky.Ok_rpc",result="ok"} 2`,
+		`fsrpc_requests_total{side="server",method="Flaky.Bad_rpc",result="error"} 1`,
+		`fsrpc_requests_total{side="server",method="Flaky.Boom_rpc",result="fail"} 1`,
+		`fsrpc_requests_total{side="server",method="Arith.Slow_rpc",result="error"} 1`,
+		`fsrpc_requests_total{side="server",method="unknown",result="fail"} 3`,
+		`fsrpc_requests_total{side="client",method="Flaky.Bad_rpc",result="error"} 1`,
+		`fsrpc_requests_total{side="client",method="Flaky.Boom_rpc",result="fail"} 1`,
+		`fsrpc_requests_total{side="client",method="Arith.Slow_rpc",result="fail"} 1`,
+		`fsrpc_in_flight_requests{side="server",method="Flaky.Ok_rpc"} 0`,
+		`fsrpc_in_flight_requests{side="server",method="unknown"} 0`,
+		`fsrpc_client_pending_requests 0`,
+		`fsrpc_request_duration_seconds_count{side="server",method="Flaky.Ok_rpc"} 2`,
+		`fsrpc_request_duration_seconds_bucket{side="server",method="Flaky.Ok_rpc",le="+Inf"} 2`,
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	// 不存在的服务和方法不单独统计
+	for _, name := range []string{"Flaky.Nope_rpc", "Nope.Ok_rpc", "Nope2.Ok_rpc"} {
+		if strings.Contains(text.String(), `side="server",method="`+name+`"`) {
+			t.Errorf("unknown method %s has its own series", name)
+		}
+	}
+	if t.Failed() {
+		t.Log(text.String())
+	}
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || w.Body.String() != text.String() {
+		t.Fatal("ServeHTTP output differs from WriteText")
+	}
+}
@@ -16,6 +16,9 @@ func toPBReqHeader(req *fsrpc.S_ReqHeader) *S_ReqHeader {
 		ServiceName: req.ServiceName,
 		MethodName:  req.MethodName,
 		ReqID:       req.ReqID,
+		RequestID:   req.RequestID,
+		Deadline:    req.Deadline,
+		Stream:      req.Stream,
 	}
 }
 
@@ -27,6 +30,7 @@ func toPBRspHeader(rsp *fsrpc.S_RspHeader) *S_RspHeader {
 		ReqID:       rsp.ReqID,
 		Fail:        rsp.Fail,
 		Error:       rsp.Error,
+		Stream:      rsp.Stream,
 	}
 }
 
@@ -36,6 +40,9 @@ func (pbReq *S_ReqHeader) toReqHeader(req *fsrpc.S_ReqHeader) {
 	req.ServiceName = pbReq.ServiceName
 	req.MethodName = pbReq.MethodName
 	req.ReqID = pbReq.ReqID
+	req.RequestID = pbReq.RequestID
+	req.Deadline = pbReq.Deadline
+	req.Stream = pbReq.Stream
 }
 
 // 将 pb 格式回复头转换为 rpc 内核格式
@@ -43,4 +50,5 @@ func (pbRsp *S_RspHeader) toRspHeader(rsp *fsrpc.S_RspHeader) {
 	rsp.ReqID = pbRsp.ReqID
 	rsp.Fail = pbRsp.Fail
 	rsp.Error = pbRsp.Error
+	rsp.Stream = pbRsp.Stream
 }
@@ -23,6 +23,9 @@ type S_ReqHeader struct {
 	ServiceName          string   `protobuf:"bytes,1,opt,name=ServiceName,proto3" json:"ServiceName,omitempty"`
 	MethodName           string   `protobuf:"bytes,2,opt,name=MethodName,proto3" json:"MethodName,omitempty"`
 	ReqID                uint64   `protobuf:"varint,3,opt,name=ReqID,proto3" json:"ReqID,omitempty"`
+	RequestID            string   `protobuf:"bytes,4,opt,name=RequestID,proto3" json:"RequestID,omitempty"`
+	Deadline             int64    `protobuf:"varint,5,opt,name=Deadline,proto3" json:"Deadline,omitempty"`
+	Stream               uint32   `protobuf:"varint,6,opt,name=Stream,proto3" json:"Stream,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -73,6 +76,27 @@ func (m *S_ReqHeader) GetReqID() uint64 {
 	return 0
 }
 
+func (m *S_ReqHeader) GetRequestID() string {
+	if m != nil {
+		return m.RequestID
+	}
+	return ""
+}
+
+func (m *S_ReqHeader) GetDeadline() int64 {
+	if m != nil {
+		return m.Deadline
+	}
+	return 0
+}
+
+func (m *S_ReqHeader) GetStream() uint32 {
+	if m != nil {
+		return m.Stream
+	}
+	return 0
+}
+
 // 回复头
 type S_RspHeader struct {
 	ServiceName          string   `protobuf:"bytes,1,opt,name=ServiceName,proto3" json:"ServiceName,omitempty"`
@@ -80,6 +104,7 @@ type S_RspHeader struct {
 	ReqID                uint64   `protobuf:"varint,3,opt,name=ReqID,proto3" json:"ReqID,omitempty"`
 	Fail                 string   `protobuf:"bytes,4,opt,name=Fail,proto3" json:"Fail,omitempty"`
 	Error                string   `protobuf:"bytes,5,opt,name=Error,proto3" json:"Error,omitempty"`
+	Stream               uint32   `protobuf:"varint,6,opt,name=Stream,proto3" json:"Stream,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -144,6 +169,13 @@ func (m *S_RspHeader) GetError() string {
 	return ""
 }
 
+func (m *S_RspHeader) GetStream() uint32 {
+	if m != nil {
+		return m.Stream
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*S_ReqHeader)(nil), "pbcodec.S_ReqHeader")
 	proto.RegisterType((*S_RspHeader)(nil), "pbcodec.S_RspHeader")
@@ -152,15 +184,19 @@ func init() {
 func init() { proto.RegisterFile("header.proto", fileDescriptor_header_60c28db0f1185bb8) }
 
 var fileDescriptor_header_60c28db0f1185bb8 = []byte{
-	// 158 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xc9, 0x48, 0x4d, 0x4c,
-	0x49, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2f, 0x48, 0x4a, 0xce, 0x4f, 0x49,
-	0x4d, 0x56, 0x4a, 0xe6, 0xe2, 0x0c, 0x4a, 0x2d, 0xf4, 0x00, 0xcb, 0x09, 0x29, 0x70, 0x71, 0x07,
-	0xa7, 0x16, 0x95, 0x65, 0x26, 0xa7, 0xfa, 0x25, 0xe6, 0xa6, 0x4a, 0x30, 0x2a, 0x30, 0x6a, 0x70,
-	0x06, 0x21, 0x0b, 0x09, 0xc9, 0x71, 0x71, 0xf9, 0xa6, 0x96, 0x64, 0xe4, 0xa7, 0x80, 0x15, 0x30,
-	0x81, 0x15, 0x20, 0x89, 0x08, 0x89, 0x70, 0xb1, 0x06, 0xa5, 0x16, 0x7a, 0xba, 0x48, 0x30, 0x2b,
-	0x30, 0x6a, 0xb0, 0x04, 0x41, 0x38, 0x4a, 0xbd, 0x8c, 0x5c, 0x9c, 0x41, 0xc5, 0x05, 0xb4, 0xb5,
-	0x45, 0x48, 0x88, 0x8b, 0xc5, 0x2d, 0x31, 0x33, 0x47, 0x82, 0x05, 0xac, 0x1e, 0xcc, 0x06, 0xa9,
-	0x74, 0x2d, 0x2a, 0xca, 0x2f, 0x92, 0x60, 0x05, 0x0b, 0x42, 0x38, 0x49, 0x6c, 0xe0, 0x40, 0x30,
-	0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0xd3, 0x2e, 0x07, 0x1b, 0x14, 0x01, 0x00, 0x00,
+	// 214 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x90, 0x41, 0x4a, 0xc5, 0x30,
+	0x10, 0x86, 0x89, 0xaf, 0xaf, 0xda, 0x79, 0xba, 0x19, 0x44, 0x82, 0x88, 0x84, 0xae, 0xb2, 0x72,
+	0xe3, 0x15, 0xaa, 0xd8, 0x85, 0x2e, 0xa6, 0x07, 0x90, 0xb4, 0x19, 0x68, 0xa1, 0x35, 0x6d, 0x1a,
+	0xbd, 0x92, 0x47, 0xf0, 0x7a, 0x62, 0x5a, 0xb4, 0x1b, 0x97, 0x6f, 0x37, 0xdf, 0x3f, 0xff, 0xc0,
+	0xc7, 0xc0, 0x79, 0xcb, 0xc6, 0xb2, 0xbf, 0x1b, 0xbd, 0x0b, 0x0e, 0x4f, 0xc7, 0xba, 0x71, 0x96,
+	0x9b, 0xfc, 0x4b, 0xc0, 0xa1, 0x7a, 0x25, 0x9e, 0x9e, 0xe2, 0x1a, 0x15, 0x1c, 0x2a, 0xf6, 0x1f,
+	0x5d, 0xc3, 0x2f, 0x66, 0x60, 0x29, 0x94, 0xd0, 0x19, 0x6d, 0x23, 0xbc, 0x05, 0x78, 0xe6, 0xd0,
+	0x3a, 0x1b, 0x0b, 0x27, 0xb1, 0xb0, 0x49, 0xf0, 0x12, 0xf6, 0xc4, 0x53, 0x59, 0xc8, 0x9d, 0x12,
+	0x3a, 0xa1, 0x05, 0xf0, 0x06, 0x32, 0xe2, 0xe9, 0x9d, 0xe7, 0x50, 0x16, 0x32, 0x89, 0x47, 0x7f,
+	0x01, 0x5e, 0xc3, 0x59, 0xc1, 0xc6, 0xf6, 0xdd, 0x1b, 0xcb, 0xbd, 0x12, 0x7a, 0x47, 0xbf, 0x8c,
+	0x57, 0x90, 0x56, 0xc1, 0xb3, 0x19, 0x64, 0xaa, 0x84, 0xbe, 0xa0, 0x95, 0xf2, 0xcf, 0xc5, 0x7c,
+	0x1e, 0x8f, 0x6c, 0x8e, 0x90, 0x3c, 0x9a, 0xae, 0x5f, 0xa5, 0xe3, 0xfc, 0xd3, 0x7c, 0xf0, 0xde,
+	0xf9, 0x28, 0x9b, 0xd1, 0x02, 0xff, 0x99, 0xd6, 0x69, 0xfc, 0xf9, 0xfd, 0xf7, 0x00, 0xe7, 0xb4,
+	0x73, 0x35, 0x83, 0x01, 0x00, 0x00,
 }
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/golang/protobuf/proto"
 	"io"
 
@@ -61,6 +62,24 @@ func _writeBuffer(w io.Writer, buff []byte) error {
 	return err
 }
 
+// 编码 protobuf 消息，v 不是 protobuf 消息时返回错误，而不是 panic
+func _marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("type %T is not a protobuf message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// 解码 protobuf 消息，v 不是 protobuf 消息时返回错误，而不是 panic
+func _unmarshal(buff []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("type %T is not a protobuf message", v)
+	}
+	return proto.Unmarshal(buff, msg)
+}
+
 // -----------------------------------------------------------------------------
 // S_PBServerCodec
 // -----------------------------------------------------------------------------
@@ -117,13 +136,29 @@ func (c *S_PBServerCodec) ReadRequestArg(header *fsrpc.S_ReqHeader, arg interfac
 		}
 		return nil
 	}
-	return proto.Unmarshal(buff, arg.(proto.Message))
+	return _unmarshal(buff, arg)
 }
 
 // 回复客户端
 func (c *S_PBServerCodec) WriteResponse(header *fsrpc.S_RspHeader, reply interface{}) error {
-	// 写入回复头
 	pbRsp := toPBRspHeader(header)
+
+	// 先编码回复结果数据，调用失败或者无返回值时为空
+	body := []byte{}
+	if reply != nil && !fsrpc.IsEmptyReply(reply) {
+		var err error
+		if body, err = _marshal(reply); err != nil {
+			// 流消息无法编码，由 S_Stream.Send 返回错误
+			if header.Stream != fsrpc.StreamNone {
+				return errors.New("encode protobuf's stream message fail: " + err.Error())
+			}
+			// 回复参数无法编码，以调用失败回复
+			body = []byte{}
+			pbRsp.Fail = "fsrpc: encode protobuf's response reply fail: " + err.Error()
+		}
+	}
+
+	// 写入回复头
 	buff, err := proto.Marshal(pbRsp)
 	if err != nil {
 		c.Close()
@@ -134,18 +169,8 @@ func (c *S_PBServerCodec) WriteResponse(header *fsrpc.S_RspHeader, reply interfa
 		return err
 	}
 
-	// 调用失败或者无返回值
-	if reply == nil || fsrpc.IsEmptyReply(reply) {
-		buff = []byte{}
-	} else {
-		// 写入回复结果数据
-		buff, err = proto.Marshal(reply.(proto.Message))
-		if err != nil {
-			c.Close()
-			return err
-		}
-	}
-	if err = _writeBuffer(c.writer, buff); err != nil {
+	// 写入回复结果数据
+	if err = _writeBuffer(c.writer, body); err != nil {
 		c.Close()
 		return err
 	}
@@ -153,6 +178,11 @@ func (c *S_PBServerCodec) WriteResponse(header *fsrpc.S_RspHeader, reply interfa
 	return nil
 }
 
+// 只能编码 protobuf 消息，server.S_Server.EnableReflection 据此拒绝注册反射服务
+func (c *S_PBServerCodec) ProtobufOnly() bool {
+	return true
+}
+
 // 关闭IO
 func (c *S_PBServerCodec) Close() error {
 	if c.closed {
@@ -183,6 +213,18 @@ func (c *S_PBClientCodec) Initialize(rwc io.ReadWriteCloser) {
 
 // 写入请求数据
 func (c *S_PBClientCodec) WriteRequest(header *fsrpc.S_ReqHeader, arg interface{}) error {
+	// 先编码请求内容，编码失败时不写入任何数据
+	var body []byte
+	var err error
+	if arg == nil || fsrpc.IsEmptyArg(arg) {
+		body = []byte{}
+	} else {
+		body, err = _marshal(arg)
+	}
+	if err != nil {
+		return errors.New("encode protobuf's request argument fail: " + err.Error())
+	}
+
 	// 写入请求头
 	pbHeader := toPBReqHeader(header)
 	bheader, err := proto.Marshal(pbHeader)
@@ -194,15 +236,6 @@ func (c *S_PBClientCodec) WriteRequest(header *fsrpc.S_ReqHeader, arg interface{
 	}
 
 	// 写入请求内容
-	var body []byte
-	if arg == nil || fsrpc.IsEmptyArg(arg) {
-		body = []byte{}
-	} else {
-		body, err = proto.Marshal(arg.(proto.Message))
-	}
-	if err != nil {
-		return errors.New("encode protobuf's request argument fail: " + err.Error())
-	}
 	if err = _writeBuffer(c.writer, body); err != nil {
 		c.writer.Reset(c.rwc)
 		return errors.New("write argument fail: " + err.Error())
@@ -238,7 +271,7 @@ func (c *S_PBClientCodec) ReadResponseReply(reply interface{}) error {
 		return nil
 	}
 
-	return proto.Unmarshal(buff, reply.(proto.Message))
+	return _unmarshal(buff, reply)
 }
 
 // 关闭链接
@@ -0,0 +1,66 @@
+package fsrpc_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/pbcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type PBEcho struct{}
+
+func (this *PBEcho) Echo_rpc(arg *pbcodec.S_ReqHeader, reply *pbcodec.S_ReqHeader) error {
+	*reply = pbcodec.S_ReqHeader{ServiceName: arg.ServiceName, ReqID: arg.ReqID}
+	return nil
+}
+
+func (this *PBEcho) Int_rpc(arg *pbcodec.S_ReqHeader, reply *int) error {
+	*reply = 1
+	return nil
+}
+
+func (this *PBEcho) Sum_rpc(arg [2]int, reply *pbcodec.S_ReqHeader) error {
+	return nil
+}
+
+// 非 protobuf 类型的参数或回复返回错误，而不是 panic
+func TestPBCodecNonProtobuf(t *testing.T) {
+	svr := server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec { return pbcodec.NewServerCodec(rwc) })
+	if err := svr.Register(new(PBEcho)); err != nil {
+		t.Fatal(err)
+	}
+	c := dial(t, pbcodec.NewClienCodec(), serve(t, svr))
+
+	reply := new(pbcodec.S_ReqHeader)
+	if err := c.Call("PBEcho.Echo_rpc", &pbcodec.S_ReqHeader{ServiceName: "x", ReqID: 3}, reply); err != nil ||
+		reply.ServiceName != "x" || reply.ReqID != 3 {
+		t.Fatal(err, reply)
+	}
+
+	// 客户端请求参数不是 protobuf 消息，不发送请求
+	if err := c.Call("PBEcho.Sum_rpc", [2]int{1, 2}, reply); err == nil {
+		t.Fatal("expect encode error")
+	}
+
+	// 服务方法的请求参数不是 protobuf 消息
+	var se client.S_ServerError
+	if err := c.Call("PBEcho.Sum_rpc", &pbcodec.S_ReqHeader{}, reply); !errors.As(err, &se) {
+		t.Fatal("expect server error, got", err)
+	}
+
+	// 服务方法的回复参数不是 protobuf 消息
+	var n int
+	err := c.Call("PBEcho.Int_rpc", &pbcodec.S_ReqHeader{}, &n)
+	if !errors.As(err, &se) || !strings.Contains(err.Error(), "encode protobuf's response reply fail") {
+		t.Fatal("expect server error, got", err)
+	}
+
+	// 链接仍然可用
+	if err := c.Call("PBEcho.Echo_rpc", &pbcodec.S_ReqHeader{ReqID: 4}, reply); err != nil || reply.ReqID != 4 {
+		t.Fatal(err, reply)
+	}
+}
@@ -0,0 +1,88 @@
+package fsrpc_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"fsky.pro/fsrpc"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/pbcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+func TestReflectionDisabled(t *testing.T) {
+	_, c := startArith(t)
+	var services []server.S_ServiceDesc
+	err := c.Call(server.ReflectionServiceName+".Services_rpc", fsrpc.EArg{}, &services)
+	var se client.S_ServerError
+	if !errors.As(err, &se) {
+		t.Fatal("reflection should not be registered by default, got", err)
+	}
+}
+
+func TestReflection(t *testing.T) {
+	svr := newGobServer()
+	svr.Register(newArith())
+	svr.Register(&Streamer{})
+	if err := svr.EnableReflection(); err != nil {
+		t.Fatal(err)
+	}
+	c := dialGob(t, serve(t, svr))
+
+	var r int
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil {
+		t.Fatal(err)
+	}
+
+	var services []server.S_ServiceDesc
+	if err := c.Call(server.ReflectionServiceName+".Services_rpc", fsrpc.EArg{}, &services); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, svrc := range services {
+		names = append(names, svrc.Name)
+	}
+	if strings.Join(names, ",") != "Arith,Streamer,_Reflection" {
+		t.Fatal("unexpected services:", names)
+	}
+
+	var arith server.S_ServiceDesc
+	if err := c.Call(server.ReflectionServiceName+".Service_rpc", "Arith", &arith); err != nil {
+		t.Fatal(err)
+	}
+	methods := map[string]server.S_MethodDesc{}
+	for _, method := range arith.Methods {
+		methods[method.Name] = method
+	}
+	add := methods["Add_rpc"]
+	if add.ArgType != "[2]int" || add.ReplyType != "*int" || add.WithCtx || add.Stream || add.NumCalls != 1 {
+		t.Fatalf("unexpected Add_rpc description: %+v", add)
+	}
+	if slow := methods["Slow_rpc"]; !slow.WithCtx {
+		t.Fatalf("unexpected Slow_rpc description: %+v", slow)
+	}
+
+	var streamer server.S_ServiceDesc
+	if err := c.Call(server.ReflectionServiceName+".Service_rpc", "Streamer", &streamer); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range streamer.Methods {
+		if !method.Stream {
+			t.Fatalf("unexpected stream method description: %+v", method)
+		}
+	}
+
+	var none server.S_ServiceDesc
+	if _, ok := c.Call(server.ReflectionServiceName+".Service_rpc", "None", &none).(client.T_ServiceError); !ok {
+		t.Fatal("expect service error for unknown service")
+	}
+}
+
+func TestReflectionProtobufOnly(t *testing.T) {
+	svr := server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec { return pbcodec.NewServerCodec(rwc) })
+	if err := svr.EnableReflection(); err == nil {
+		t.Fatal("pbcodec server should refuse reflection")
+	}
+}
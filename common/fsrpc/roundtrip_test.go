@@ -0,0 +1,173 @@
+package fsrpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"fsky.pro/fslog"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/gobcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type Arith struct {
+	slowErr chan error
+}
+
+func (this *Arith) Add_rpc(arg [2]int, reply *int) error {
+	*reply = arg[0] + arg[1]
+	return nil
+}
+
+func (this *Arith) RequestID_rpc(ctx context.Context, _ int, reply *string) error {
+	*reply = fslog.RequestID(ctx)
+	return nil
+}
+
+func (this *Arith) Slow_rpc(ctx context.Context, d int, reply *int) error {
+	select {
+	case <-time.After(time.Duration(d) * time.Millisecond):
+		*reply = d
+		return nil
+	case <-ctx.Done():
+		select {
+		case this.slowErr <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	}
+}
+
+func newGobServer() *server.S_Server {
+	return server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec { return gobcodec.NewServerCodec(rwc) })
+}
+
+func newArith() *Arith {
+	return &Arith{slowErr: make(chan error, 1)}
+}
+
+// 在 addr 上启动服务器，测试结束时关闭，返回监听端口
+func serveOn(t *testing.T, svr *server.S_Server, addr string) uint16 {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go svr.Serve(lis)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		svr.Shutdown(ctx)
+	})
+	return uint16(lis.Addr().(*net.TCPAddr).Port)
+}
+
+// 在随机端口上启动服务器，测试结束时关闭
+func serve(t *testing.T, svr *server.S_Server) uint16 {
+	return serveOn(t, svr, "127.0.0.1:0")
+}
+
+// 以 codec 连接服务器，测试结束时关闭
+func dial(t *testing.T, codec client.I_ClientCodec, port uint16) *client.S_Client {
+	c := client.NewClient(codec)
+	if err := c.DialTCP("127.0.0.1", port); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func dialGob(t *testing.T, port uint16) *client.S_Client {
+	return dial(t, gobcodec.NewClienCodec(), port)
+}
+
+func startArith(t *testing.T) (*Arith, *client.S_Client) {
+	arith := newArith()
+	svr := newGobServer()
+	if err := svr.Register(arith); err != nil {
+		t.Fatal(err)
+	}
+	return arith, dialGob(t, serve(t, svr))
+}
+
+// 等待条件成立，3 秒内不成立则失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait for %s timeout", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCallContextRequestID(t *testing.T) {
+	_, c := startArith(t)
+	var id string
+	ctx := fslog.ContextWithRequestID(context.Background(), "req-1")
+	if err := c.CallContext(ctx, "Arith.RequestID_rpc", 0, &id); err != nil || id != "req-1" {
+		t.Fatal(err, id)
+	}
+}
+
+func TestCallContextDeadline(t *testing.T) {
+	arith, c := startArith(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var r int
+	start := time.Now()
+	err := c.CallContext(ctx, "Arith.Slow_rpc", 2000, &r)
+	var te *client.S_TimeoutError
+	if !errors.As(err, &te) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect *client.S_TimeoutError, got %v", err)
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Fatalf("call returned after %v", cost)
+	}
+
+	// 截止时间传给了服务器，服务方法的 ctx 同样超时
+	select {
+	case err := <-arith.slowErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("server ctx:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server ctx has no deadline")
+	}
+
+	// 已经超时的 ctx 不再发送请求
+	if err := c.CallContext(ctx, "Arith.Slow_rpc", 1, &r); !errors.As(err, &te) {
+		t.Fatalf("expect *client.S_TimeoutError, got %v", err)
+	}
+
+	// 链接仍然可用
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+}
+
+func TestCallContextCancel(t *testing.T) {
+	_, c := startArith(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	var r int
+	err := c.CallContext(ctx, "Arith.Slow_rpc", 200, &r)
+	var te *client.S_TimeoutError
+	if !errors.Is(err, context.Canceled) || errors.As(err, &te) {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+
+	// 被取消请求的回复到达后被丢弃，不影响之后的请求
+	time.Sleep(250 * time.Millisecond)
+	if err := c.Call("Arith.Add_rpc", [2]int{3, 4}, &r); err != nil || r != 7 {
+		t.Fatal(err, r)
+	}
+	if err := c.CallContext(context.Background(), "Arith.Slow_rpc", 1, &r); err != nil || r != 1 {
+		t.Fatal(err, r)
+	}
+}
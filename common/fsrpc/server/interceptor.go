@@ -0,0 +1,102 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: server interceptors
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 服务器拦截器
+// 拦截器按注册顺序嵌套调用，先注册的在外层，NewServer 默认在最外层安装 RecoveryInterceptor，如：
+//   svr.Use(server.AccessLogInterceptor(), authInterceptor)
+// 调用顺序为：recovery -> access log -> auth -> 服务方法
+// 拦截器可以不调用 next 而直接返回，如鉴权失败时返回 &server.S_FailError{...}
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"fsky.pro/fslog"
+	"fsky.pro/fsrpc"
+)
+
+// 调用服务方法，arg 为解码后的请求参数，返回回复参数和服务方法返回的错误
+type F_Handler func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}) (reply interface{}, err error)
+
+// 拦截器，next 为下一个拦截器或服务方法
+type F_Interceptor func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}, next F_Handler) (reply interface{}, err error)
+
+// 调用失败错误
+// 处理函数返回该错误时，以调用失败（而不是服务方法返回错误）回复客户端，客户端得到 client.S_ServerError
+type S_FailError struct {
+	Msg string
+}
+
+func (this *S_FailError) Error() string {
+	return this.Msg
+}
+
+// 将拦截器和服务方法串成一个处理函数
+func chainInterceptors(interceptors []F_Interceptor, handler F_Handler) F_Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}) (interface{}, error) {
+			return interceptor(ctx, header, arg, next)
+		}
+	}
+	return handler
+}
+
+// 调用服务方法的处理函数
+// 拦截器可能替换请求参数：传入 nil 时以参数类型的零值调用服务方法，类型不符时以调用失败回复客户端
+func serviceHandler(svrc *s_Service) F_Handler {
+	return func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}) (interface{}, error) {
+		argType := svrc.methods[header.MethodName].argType
+		argv := reflect.ValueOf(arg)
+		if arg == nil {
+			argv = reflect.New(argType).Elem()
+		} else if !argv.Type().AssignableTo(argType) {
+			return nil, &S_FailError{fmt.Sprintf("fsrpc: argument of %s.%s must be %s, but got %s",
+				header.ServiceName, header.MethodName, argType, argv.Type())}
+		}
+		return svrc.call(ctx, argv, header)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// built-in interceptors
+// -----------------------------------------------------------------------------
+// 捕获服务方法中的 panic，输出调用栈，并以调用失败回复客户端
+// NewServer 默认已经安装，不需要再通过 Use 注册
+func RecoveryInterceptor() F_Interceptor {
+	return func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}, next F_Handler) (reply interface{}, err error) {
+		defer func() {
+			if e := recover(); e != nil {
+				fslog.FromContext(ctx).Tracef("fsrpc: service %s.%s panic: %v", header.ServiceName, header.MethodName, e)
+				reply = nil
+				err = &S_FailError{fmt.Sprintf("fsrpc: service %s.%s panic: %v", header.ServiceName, header.MethodName, e)}
+			}
+		}()
+		return next(ctx, header, arg)
+	}
+}
+
+// 输出每个请求的访问日志，包括耗时和错误
+func AccessLogInterceptor() F_Interceptor {
+	return func(ctx context.Context, header *fsrpc.S_ReqHeader, arg interface{}, next F_Handler) (interface{}, error) {
+		start := time.Now()
+		reply, err := next(ctx, header, arg)
+		logger := fslog.FromContext(ctx).With("cost", time.Since(start))
+		if err != nil {
+			logger.With("err", err).Warn("fsrpc: access")
+		} else {
+			logger.Info("fsrpc: access")
+		}
+		return reply, err
+	}
+}
@@ -0,0 +1,155 @@
+/**
+@copyright: fantasysky 2016
+@brief: 服务反射
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 内置的 "_Reflection" 服务，列出服务器上注册的所有服务和方法，便于调试
+// 该服务会暴露服务器的所有服务和参数结构，默认不注册，需要时在启动服务前调用：
+//   err := svr.EnableReflection()
+// 客户端调用，如：
+//   var services []server.S_ServiceDesc
+//   err := c.Call("_Reflection.Services_rpc", fsrpc.EArg{}, &services)
+// 回复参数不是 protobuf 消息，所以 pbcodec 的服务器不能注册该服务，gob 和 json 编码可以
+// 命令行工具见 fsrpc/cmd/fsrpccli
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"fsky.pro/fsrpc"
+)
+
+// 反射服务名称
+const ReflectionServiceName = "_Reflection"
+
+// 方法描述
+type S_MethodDesc struct {
+	Name      string // 方法名称
+	ArgType   string // 请求参数类型
+	ArgDesc   string // 请求参数结构描述，如：{Name string; Scores []int}
+	ReplyType string // 回复参数类型，流方法为 *server.S_Stream
+	ReplyDesc string // 回复参数结构描述
+	WithCtx   bool   // 是否带 context 参数
+	Stream    bool   // 是否是流方法
+	NumCalls  uint64 // 被调用的次数
+}
+
+// 服务描述
+type S_ServiceDesc struct {
+	Name    string // 服务名称
+	Type    string // 服务对象类型
+	Methods []S_MethodDesc
+}
+
+// -----------------------------------------------------------------------------
+// inner functions
+// -----------------------------------------------------------------------------
+// 描述类型的结构，结构体展开为字段列表，visiting 用于防止递归类型无限展开
+func describeType(t reflect.Type, visiting map[reflect.Type]bool) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + describeType(t.Elem(), visiting)
+	case reflect.Slice:
+		return "[]" + describeType(t.Elem(), visiting)
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), describeType(t.Elem(), visiting))
+	case reflect.Map:
+		return "map[" + describeType(t.Key(), visiting) + "]" + describeType(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return t.String()
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		fields := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			// 跳过私有字段和 protobuf 生成的内部字段
+			if field.PkgPath != "" || strings.HasPrefix(field.Name, "XXX_") {
+				continue
+			}
+			fields = append(fields, field.Name+" "+describeType(field.Type, visiting))
+		}
+		if len(fields) == 0 {
+			return t.String()
+		}
+		return "{" + strings.Join(fields, "; ") + "}"
+	}
+	return t.String()
+}
+
+func describeService(svrc *s_Service) S_ServiceDesc {
+	desc := S_ServiceDesc{Name: svrc.name, Type: svrc.rcvrType.String()}
+	for name, method := range svrc.methods {
+		method.Lock()
+		numCalls := method.numCalls
+		method.Unlock()
+		desc.Methods = append(desc.Methods, S_MethodDesc{
+			Name:      name,
+			ArgType:   method.argType.String(),
+			ArgDesc:   describeType(method.argType, map[reflect.Type]bool{}),
+			ReplyType: method.replyType.String(),
+			ReplyDesc: describeType(method.replyType, map[reflect.Type]bool{}),
+			WithCtx:   method.withCtx,
+			Stream:    method.stream,
+			NumCalls:  numCalls,
+		})
+	}
+	sort.Slice(desc.Methods, func(i, j int) bool { return desc.Methods[i].Name < desc.Methods[j].Name })
+	return desc
+}
+
+// -----------------------------------------------------------------------------
+// S_Reflection
+// -----------------------------------------------------------------------------
+// 反射服务，通过 S_Server.EnableReflection 以 "_Reflection" 注册
+type S_Reflection struct {
+	server *S_Server
+}
+
+// 注册 "_Reflection" 服务
+// 编码解码器只能编码 protobuf 消息（实现了 I_ProtobufOnly）时返回错误
+// 注意：必须在启动服务前调用
+func (s *S_Server) EnableReflection() error {
+	// 在一个不使用的管道上创建编码解码器，检查其能否编码反射服务的回复参数
+	rwc, peer := net.Pipe()
+	codec := s.codecer(rwc)
+	pbOnly, ok := codec.(I_ProtobufOnly)
+	codec.Close()
+	peer.Close()
+	if ok && pbOnly.ProtobufOnly() {
+		return errors.New("fsrpc: reflection service can't be served by a protobuf only codec")
+	}
+	return s.RegisterByName(&S_Reflection{server: s}, ReflectionServiceName)
+}
+
+// 列出所有注册的服务，按服务名排序
+func (this *S_Reflection) Services_rpc(_ fsrpc.EArg, reply *[]S_ServiceDesc) error {
+	services := []S_ServiceDesc{}
+	this.server.services.Range(func(_, svrc interface{}) bool {
+		services = append(services, describeService(svrc.(*s_Service)))
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	*reply = services
+	return nil
+}
+
+// 获取指定服务的描述
+func (this *S_Reflection) Service_rpc(name string, reply *S_ServiceDesc) error {
+	svrc, ok := this.server.services.Load(name)
+	if !ok {
+		return fmt.Errorf("service %q is not exists", name)
+	}
+	*reply = describeService(svrc.(*s_Service))
+	return nil
+}
@@ -9,13 +9,17 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"reflect"
+	"strconv"
 	"sync"
+	"time"
 
 	"fsky.pro/fslog"
 	"fsky.pro/fsrpc"
@@ -33,13 +37,33 @@ type S_Server struct {
 
 	services sync.Map // 服务对象列表
 
+	interceptors []F_Interceptor // 拦截器，只能在启动服务前注册
+	metrics      fsrpc.I_Metrics // 调用统计，为 nil 则不统计
+
 	reqLock sync.Mutex
 	freeReq *S_ReqCache // 空闲请求列表
 
 	rspLock sync.Mutex
 	freeRsp *S_RspCache // 空闲回复列表
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}  // 正在监听的 listener
+	httpSvrs  map[*http.Server]struct{}  // 正在运行的 HTTP 服务
+	conns     map[*s_ServerConn]struct{} // 已接受的链接
+	connGone  chan struct{}              // 有链接关闭时通知 Shutdown
+	shutdown  bool                       // 是否已经调用了 Shutdown
+}
+
+// 链接信息
+type s_ServerConn struct {
+	rwc     io.Closer
+	active  int                  // 正在处理的请求数
+	streams map[uint64]*S_Stream // 正在进行的流调用
 }
 
+// 调用 Shutdown 后，Serve/ServeTCP/ServeHTTP 返回该错误
+var ErrServerClosed = errors.New("fsrpc: server closed")
+
 // 请求头缓存
 type S_ReqCache struct {
 	header fsrpc.S_ReqHeader
@@ -103,6 +127,56 @@ func (s *S_Server) _freeRspHeader(rsp *S_RspCache) {
 	s.freeRsp = rsp
 }
 
+// -------------------------------------------------------------------
+// 记录链接，已经关闭服务则返回 nil
+func (s *S_Server) _trackConn(rwc io.Closer) *s_ServerConn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.shutdown {
+		return nil
+	}
+	sc := &s_ServerConn{rwc: rwc, streams: make(map[uint64]*S_Stream)}
+	s.conns[sc] = struct{}{}
+	return sc
+}
+
+func (s *S_Server) _untrackConn(sc *s_ServerConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, sc)
+	select {
+	case s.connGone <- struct{}{}:
+	default:
+	}
+}
+
+// 开始处理一个请求，正在关闭服务则返回 false
+func (s *S_Server) _beginRequest(sc *s_ServerConn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.shutdown {
+		return false
+	}
+	sc.active++
+	return true
+}
+
+// 请求处理完毕，正在关闭服务时，没有正在处理的请求则关闭链接
+func (s *S_Server) _endRequest(sc *s_ServerConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sc.active--
+	if s.shutdown && sc.active == 0 {
+		sc.rwc.Close()
+	}
+}
+
+func (s *S_Server) _isShutdown() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.shutdown
+}
+
 // -------------------------------------------------------------------
 // 获取请求头信息
 // 获取请求对应的 service 和 method
@@ -114,6 +188,10 @@ func (s *S_Server) _readReqHeader(codec S_ServerCodec) (req *S_ReqCache, ok bool
 	if err == io.EOF {
 		return
 	}
+	// 关闭服务时关闭链接引起的错误
+	if err != nil && s._isShutdown() {
+		return
+	}
 	if err == io.ErrUnexpectedEOF {
 		fslog.Error("fsrpc: read request header fail: " + err.Error())
 		return
@@ -121,8 +199,10 @@ func (s *S_Server) _readReqHeader(codec S_ServerCodec) (req *S_ReqCache, ok bool
 
 	// 解码错误，通常不会出现这种错误
 	// 除非 fsrpc.S_ReqHeader 结构改了，而服务器和客户端版本不一样，不同时改
+	// 或者链接已经被关闭，这时数据流已经无法继续解释，需要断开链接
 	if err != nil {
-		err = errors.New("fsrpc: server can't decode request: " + err.Error())
+		fslog.Error("fsrpc: server can't decode request: " + err.Error())
+		return
 	}
 	ok = true
 	return
@@ -134,6 +214,8 @@ func (s *S_Server) _readReqHeader(codec S_ServerCodec) (req *S_ReqCache, ok bool
 func (s *S_Server) _sendResponse(sendMutex *sync.Mutex, codec S_ServerCodec, reqHeader *fsrpc.S_ReqHeader, reply interface{}, fail error, err error) {
 	rsp := s._getRspHeader()
 	rspHeader := &rsp.header
+	rspHeader.ServiceName = reqHeader.ServiceName
+	rspHeader.MethodName = reqHeader.MethodName
 	rspHeader.ReqID = reqHeader.ReqID
 	if fail != nil {
 		rspHeader.Fail = fail.Error()
@@ -150,11 +232,91 @@ func (s *S_Server) _sendResponse(sendMutex *sync.Mutex, codec S_ServerCodec, req
 	s._freeRspHeader(rsp)
 }
 
+// 发送一个流消息
+func (s *S_Server) _sendStreamMsg(sendMutex *sync.Mutex, codec S_ServerCodec, reqHeader *fsrpc.S_ReqHeader, msg interface{}) error {
+	rsp := s._getRspHeader()
+	rspHeader := &rsp.header
+	rspHeader.ServiceName = reqHeader.ServiceName
+	rspHeader.MethodName = reqHeader.MethodName
+	rspHeader.ReqID = reqHeader.ReqID
+	rspHeader.Stream = fsrpc.StreamMsg
+	sendMutex.Lock()
+	err := codec.WriteResponse(rspHeader, msg)
+	sendMutex.Unlock()
+	s._freeRspHeader(rsp)
+	return err
+}
+
+// 开始一个流调用，返回携带流对象的 context
+func (s *S_Server) _openStream(ctx context.Context, sc *s_ServerConn, sendMutex *sync.Mutex, codec S_ServerCodec,
+	header *fsrpc.S_ReqHeader, method *s_MethodInfo) (context.Context, *S_Stream) {
+	stream := &S_Stream{
+		header: *header,
+		method: method,
+		recv:   make(chan reflect.Value, streamRecvBuffer),
+	}
+	ctx, stream.cancel = context.WithCancel(ctx)
+	stream.ctx = contextWithStream(ctx, stream)
+	stream.send = func(msg interface{}) error {
+		return s._sendStreamMsg(sendMutex, codec, &stream.header, msg)
+	}
+	s.mutex.Lock()
+	sc.streams[header.ReqID] = stream
+	s.mutex.Unlock()
+	return stream.ctx, stream
+}
+
+// 结束流调用，之后到达的客户端流消息将被丢弃
+func (s *S_Server) _closeStream(sc *s_ServerConn, stream *S_Stream) {
+	s.mutex.Lock()
+	delete(sc.streams, stream.header.ReqID)
+	s.mutex.Unlock()
+	stream._end()
+}
+
+// 正在关闭服务时，拒绝链接上新到达的请求，没有正在处理的请求则关闭链接
+func (s *S_Server) _rejectRequest(sc *s_ServerConn, sendMutex *sync.Mutex, codec S_ServerCodec, header *fsrpc.S_ReqHeader) {
+	codec.ReadRequestArg(header, nil)
+	s._sendResponse(sendMutex, codec, header, nil, errors.New(fsrpc.ShutdownText), nil)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if sc.active == 0 {
+		sc.rwc.Close()
+	}
+}
+
+// 处理客户端发送的流消息
+func (s *S_Server) _handleStreamMsg(sc *s_ServerConn, codec S_ServerCodec, header *fsrpc.S_ReqHeader) {
+	s.mutex.Lock()
+	stream := sc.streams[header.ReqID]
+	s.mutex.Unlock()
+	if stream == nil || header.Stream != fsrpc.StreamMsg {
+		codec.ReadRequestArg(header, nil)
+		if stream == nil {
+			return
+		}
+		switch header.Stream {
+		case fsrpc.StreamClose:
+			stream._closeRecv()
+		case fsrpc.StreamCancel:
+			stream.cancel()
+		}
+		return
+	}
+	argv, err := stream.method._getArgValue(codec, header)
+	if err != nil {
+		fslog.Error("fsrpc: " + err.Error())
+		return
+	}
+	stream._push(argv)
+}
+
 // 处理服务请求
-func (s *S_Server) _handleService(wg *sync.WaitGroup, sendMutex *sync.Mutex, codec S_ServerCodec, req *S_ReqCache) {
+func (s *S_Server) _handleService(wg *sync.WaitGroup, sc *s_ServerConn, sendMutex *sync.Mutex, codec S_ServerCodec, req *S_ReqCache) {
 	var argv reflect.Value // 调用服务的传入参数
 	var err error = nil    // 调用服务的返回值
 	header := &req.header
+	start := time.Now()
 
 	// 根据服务名称，加载服务对象
 	svrc, ok := s.services.Load(header.ServiceName)
@@ -173,23 +335,88 @@ func (s *S_Server) _handleService(wg *sync.WaitGroup, sendMutex *sync.Mutex, cod
 		fslog.Error("fsrpc: " + err.Error())
 	}
 
+	// 服务和方法都存在时才以 "服务名.方法名" 统计
+	label := fsrpc.MetricsUnknown
+	if ok && svrc.(*s_Service).method(header.MethodName) != nil {
+		label = header.ServiceName + "." + header.MethodName
+	}
+	if s.metrics != nil {
+		s.metrics.Begin(label)
+	}
+
+	// 请求 ID 由客户端传入，没有则生成一个，服务方法可以通过 context 获取
+	reqID := header.RequestID
+	if reqID == "" {
+		reqID = fslog.NewRequestID()
+	}
+	ctx := fslog.ContextWithRequestID(context.Background(), reqID)
+	ctx = fslog.ContextWithFields(ctx, "rpc", header.ServiceName+"."+header.MethodName)
+
+	// 客户端设置了截止时间，则服务方法可以通过 ctx.Done() 放弃已经超时的处理
+	cancel := context.CancelFunc(func() {})
+	if header.Deadline != 0 {
+		ctx, cancel = context.WithDeadline(ctx, time.Unix(0, header.Deadline))
+	}
+
+	// 流方法，在读取下一个请求前登记流对象，以便接收客户端随后发送的流消息
+	var stream *S_Stream
+	if err == nil {
+		if method := svrc.(*s_Service).method(header.MethodName); method.stream {
+			ctx, stream = s._openStream(ctx, sc, sendMutex, codec, header, method)
+		}
+	}
+
 	go func() {
-		wg.Done()
+		defer wg.Done()
+		defer s._endRequest(sc)
+		defer cancel()
+		// 请求到达时已经超时，则不再调用服务
+		if err == nil && ctx.Err() == context.DeadlineExceeded {
+			err = errors.New(fsrpc.DeadlineExceededText)
+		}
 		// 只有没有任何错误时，才调用服务
+		result := fsrpc.ResultOK
 		if err == nil {
-			reply, err := svrc.(*s_Service).call(argv, header)
-			s._sendResponse(sendMutex, codec, header, reply, nil, err)
+			handler := chainInterceptors(s.interceptors, serviceHandler(svrc.(*s_Service)))
+			reply, err := handler(ctx, header, argv.Interface())
+			if stream != nil {
+				s._closeStream(sc, stream)
+			}
+			var fail *S_FailError
+			if errors.As(err, &fail) {
+				result = fsrpc.ResultFail
+				s._sendResponse(sendMutex, codec, header, nil, fail, nil)
+			} else {
+				if err != nil {
+					result = fsrpc.ResultError
+				}
+				s._sendResponse(sendMutex, codec, header, reply, nil, err)
+			}
 		} else {
+			if stream != nil {
+				s._closeStream(sc, stream)
+			}
 			// 任何错误都需要回复客户端
+			result = fsrpc.ResultFail
 			s._sendResponse(sendMutex, codec, header, nil, err, nil)
 		}
+		if s.metrics != nil {
+			s.metrics.End(label, result, time.Since(start))
+		}
 		s._freeReqHeader(req)
 	}()
 }
 
 // 对单个链接服务
 // 每接受一个链接则启动一个 goroutine
+// 链接上的所有请求回复完毕后才关闭链接
 func (s *S_Server) _serveConn(conn io.ReadWriteCloser) {
+	sc := s._trackConn(conn)
+	if sc == nil {
+		conn.Close()
+		return
+	}
+	defer s._untrackConn(sc)
 	codec := s.codecer(conn)
 	sendMutex := new(sync.Mutex) // Response 缓冲锁
 	wg := new(sync.WaitGroup)
@@ -201,10 +428,18 @@ func (s *S_Server) _serveConn(conn io.ReadWriteCloser) {
 			s._freeReqHeader(req)
 			// 如果读取头失败，则意味着服务器和客户端的 fsrpc 版本不一致，链接将会断开
 			break
+		} else if req.header.Stream != fsrpc.StreamNone {
+			// 客户端发送的流消息，交给对应的流调用
+			s._handleStreamMsg(sc, codec, &req.header)
+			s._freeReqHeader(req)
+		} else if !s._beginRequest(sc) {
+			// 正在关闭服务，不再处理新请求，以免链接一直无法关闭
+			s._rejectRequest(sc, sendMutex, codec, &req.header)
+			s._freeReqHeader(req)
 		} else {
 			wg.Add(1)
 			// 调用请求方法，并将调用结果写入客户端 Response 缓冲
-			s._handleService(wg, sendMutex, codec, req)
+			s._handleService(wg, sc, sendMutex, codec, req)
 		}
 	}
 	wg.Wait()
@@ -224,6 +459,7 @@ func (s *S_Server) RegisterByName(rcvr interface{}, name string) (err error) {
 	service, err := newService(rcvr, name)
 	if err != nil {
 		fslog.Error("fsrpc: " + err.Error())
+		return
 	}
 	_, loaded := s.services.LoadOrStore(service.name, service)
 	if loaded {
@@ -233,6 +469,19 @@ func (s *S_Server) RegisterByName(rcvr interface{}, name string) (err error) {
 	return
 }
 
+// 设置调用统计，如 metrics.NewRegistry().Server()
+// 注意：必须在启动服务前设置
+func (s *S_Server) SetMetrics(metrics fsrpc.I_Metrics) {
+	s.metrics = metrics
+}
+
+// 注册拦截器，先注册的在外层
+// NewServer 已经在最外层安装了 RecoveryInterceptor，服务方法和拦截器中的 panic 都会以调用失败回复客户端
+// 注意：必须在启动服务前注册
+func (s *S_Server) Use(interceptors ...F_Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
 // ------------------------------------------------------------------
 // HTTP 服务启动参数
 type S_HttpServeArg struct {
@@ -240,36 +489,104 @@ type S_HttpServeArg struct {
 	DebugPath string // HTTP debug 访问路径（只有 HTTP 协议有）
 
 	// TLS 的 pem 和 key 文件
-	certFile string
-	keyFile  string
+	CertFile string
+	KeyFile  string
+
+	// TLS 配置，不为 nil 时忽略 CertFile 和 KeyFile，如双向 TLS 使用 NewServerTLS(...).Config()
+	TLSConfig *tls.Config
 }
 
-// 启动 TCP 协议服务（host 格式为：地址:端口）
-func (s *S_Server) ServeTCP(host string, port uint16) {
-	addr := fmt.Sprintf("%s:%d", host, port)
-	lis, err := net.Listen("tcp", addr)
+// 获取 TLS 配置，不使用 TLS 返回 nil
+func (this *S_HttpServeArg) tlsConfig() (*tls.Config, error) {
+	if this == nil {
+		return nil, nil
+	}
+	if this.TLSConfig != nil {
+		return this.TLSConfig, nil
+	}
+	if this.CertFile == "" || this.KeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(this.CertFile, this.KeyFile)
 	if err != nil {
-		fslog.Error("fsrpc: can't start tcp serve for " + host)
+		return nil, err
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+// 在指定的监听上启动服务，直到监听被关闭或者调用了 Shutdown
+// 调用 Shutdown 后返回 ErrServerClosed
+func (s *S_Server) Serve(lis net.Listener) error {
+	s.mutex.Lock()
+	if s.shutdown {
+		s.mutex.Unlock()
+		lis.Close()
+		return ErrServerClosed
 	}
-	defer lis.Close()
+	s.listeners[lis] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.listeners, lis)
+		s.mutex.Unlock()
+		lis.Close()
+	}()
+
+	var delay time.Duration // 临时错误时，等待一段时间再继续接受链接
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
-			fslog.Errorf("fsrpc: tcp accept connection error: %s\n", err.Error())
+			if s._isShutdown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(interface{ Temporary() bool }); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				fslog.Errorf("fsrpc: accept connection error: %v; retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			fslog.Errorf("fsrpc: accept connection error: %v", err)
+			return err
 		}
+		delay = 0
 		go s._serveConn(conn)
 	}
 }
 
-// 启动 HTTP 协议服务
-func (s *S_Server) ServeHTTP(host string, port uint16, arg *S_HttpServeArg) {
-	rpcPath := fsrpc.DefaultHTTPPath
-	if arg != nil {
-		rpcPath = arg.RpcPath
+// 启动 TCP 协议服务
+func (s *S_Server) ServeTCP(host string, port uint16) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fslog.Errorf("fsrpc: can't start tcp serve for %s: %v", addr, err)
+		return err
 	}
+	return s.Serve(lis)
+}
+
+// 在指定的监听上启动 TLS 服务，tlsConf 可以使用 NewServerTLS(...).Config()
+func (s *S_Server) ServeTLS(lis net.Listener, tlsConf *tls.Config) error {
+	return s.Serve(tls.NewListener(lis, tlsConf))
+}
+
+// 启动 TLS 协议服务
+func (s *S_Server) ServeTCPTLS(host string, port uint16, tlsConf *tls.Config) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fslog.Errorf("fsrpc: can't start tls serve for %s: %v", addr, err)
+		return err
+	}
+	return s.ServeTLS(lis, tlsConf)
+}
 
-	// handle rpc
-	http.HandleFunc(rpcPath, func(w http.ResponseWriter, req *http.Request) {
+// 处理 HTTP CONNECT 请求的 handler，可以挂到自己的 http.ServeMux 上
+func (s *S_Server) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req.Method != "CONNECT" {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -284,12 +601,109 @@ func (s *S_Server) ServeHTTP(host string, port uint16, arg *S_HttpServeArg) {
 			s._serveConn(conn)
 		}
 	})
+}
+
+// 启动 HTTP 协议服务
+// 使用独立的 http.ServeMux，不会注册到 http.DefaultServeMux 上
+func (s *S_Server) ServeHTTP(host string, port uint16, arg *S_HttpServeArg) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fslog.Errorf("fsrpc: can't start http serve for %s: %v", addr, err)
+		return err
+	}
+	return s.ServeHTTPListener(lis, arg)
+}
+
+// 在指定的监听上启动 HTTP 协议服务
+// arg 中设置了证书或者 TLSConfig 时，使用 HTTPS
+func (s *S_Server) ServeHTTPListener(lis net.Listener, arg *S_HttpServeArg) error {
+	tlsConf, err := arg.tlsConfig()
+	if err != nil {
+		lis.Close()
+		fslog.Errorf("fsrpc: load http serve's tls certificate fail: %v", err)
+		return err
+	}
+	rpcPath := fsrpc.DefaultHTTPPath
+	if arg != nil && arg.RpcPath != "" {
+		rpcPath = arg.RpcPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(rpcPath, s.HTTPHandler())
+	hs := &http.Server{
+		Handler: mux,
+		// CONNECT 之后需要接管链接，不能使用 HTTP/2
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+	}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
-	if arg != nil && arg.certFile != "" && arg.keyFile != "" {
-		http.ListenAndServeTLS(addr, arg.certFile, arg.keyFile, nil)
+	s.mutex.Lock()
+	if s.shutdown {
+		s.mutex.Unlock()
+		lis.Close()
+		return ErrServerClosed
+	}
+	s.httpSvrs[hs] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.httpSvrs, hs)
+		s.mutex.Unlock()
+	}()
+
+	if tlsConf != nil {
+		err = hs.Serve(tls.NewListener(lis, tlsConf))
 	} else {
-		http.ListenAndServe(addr, nil)
+		err = hs.Serve(lis)
+	}
+	if err == http.ErrServerClosed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// 关闭服务：
+//
+//	1 停止接受新链接
+//	2 关闭空闲的链接，有请求正在处理的链接等请求回复完毕后关闭
+//	  这些链接上新到达的请求不再处理，以 fsrpc.ShutdownText 作为调用失败回复
+//	  正在进行的流调用被取消（流方法的 ctx 结束），以免长时间运行的流方法使服务无法关闭
+//	3 所有链接关闭后返回 nil；ctx 结束时强制关闭剩余的链接，并返回 ctx.Err()
+func (s *S_Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.shutdown = true
+	for lis := range s.listeners {
+		lis.Close()
+	}
+	for hs := range s.httpSvrs {
+		hs.Close()
+	}
+	for sc := range s.conns {
+		if sc.active == 0 {
+			sc.rwc.Close()
+		}
+		for _, stream := range sc.streams {
+			stream.cancel()
+		}
+	}
+	s.mutex.Unlock()
+
+	for {
+		s.mutex.Lock()
+		remain := len(s.conns)
+		s.mutex.Unlock()
+		if remain == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			s.mutex.Lock()
+			for sc := range s.conns {
+				sc.rwc.Close()
+			}
+			s.mutex.Unlock()
+			return ctx.Err()
+		case <-s.connGone:
+		}
 	}
 }
 
@@ -297,6 +711,16 @@ func (s *S_Server) ServeHTTP(host string, port uint16, arg *S_HttpServeArg) {
 // package public methods
 // ---------------------------------------------------------------------------------------
 // 新建一个服务
+// 需要 "_Reflection" 服务时调用 EnableReflection，见 reflection.go
 func NewServer(codecer F_CodecCreator) *S_Server {
-	return &S_Server{codecer: codecer}
+	s := &S_Server{
+		codecer:   codecer,
+		listeners: make(map[net.Listener]struct{}),
+		httpSvrs:  make(map[*http.Server]struct{}),
+		conns:     make(map[*s_ServerConn]struct{}),
+		connGone:  make(chan struct{}, 1),
+	}
+	// panic 不能使整个进程退出，恢复拦截器始终在最外层
+	s.interceptors = []F_Interceptor{RecoveryInterceptor()}
+	return s
 }
@@ -30,3 +30,9 @@ type S_ServerCodec interface {
 
 // 编码解码器生成函数
 type F_CodecCreator func(io.ReadWriteCloser) S_ServerCodec
+
+// 只能编码 protobuf 消息的编码解码器（如 pbcodec）实现该接口
+// 这类编码解码器不能回复内置服务（如 "_Reflection"）的 Go 结构回复参数
+type I_ProtobufOnly interface {
+	ProtobufOnly() bool
+}
@@ -9,6 +9,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -16,7 +17,7 @@ import (
 
 	"fsky.pro/fserror"
 	"fsky.pro/fslog"
-	"fsky.pro/fsky"
+	"fsky.pro/fsreflect"
 
 	. "fsky.pro/fsrpc"
 )
@@ -24,13 +25,19 @@ import (
 // -----------------------------------------------------------------------------
 // 可调用方法信息
 // 可远程调用的方法：
-//   1 方法名称必须以 "_rpc" 结尾
-//   2 必须有且只有两个参数（第一个参数为请求参数；第二个参数为作为返回结果）
-//   3 方法必须有且只有一个返回值，并且返回类型为 error
+//
+//	1 方法名称必须以 "_rpc" 结尾
+//	2 必须有且只有两个参数（第一个参数为请求参数；第二个参数为作为返回结果）
+//	  可以在请求参数前加一个 context.Context 参数，其中携带了请求 ID，可通过 fslog.FromContext 输出日志
+//	  第二个参数为 *S_Stream 时为流方法，通过 S_Stream 发送多个回复（见 stream.go）
+//	3 方法必须有且只有一个返回值，并且返回类型为 error
+//
 // -----------------------------------------------------------------------------
 type s_MethodInfo struct {
 	sync.Mutex
 	method    reflect.Method // 请求方法
+	withCtx   bool           // 第一个参数是否为 context.Context
+	stream    bool           // 是否是流方法（回复参数为 *S_Stream）
 	argType   reflect.Type   // 方法参数类型
 	replyType reflect.Type   // 回复客户端类型
 	numCalls  uint64         // 客户端请求次数
@@ -96,21 +103,38 @@ func (svrc *s_Service) getArgValue(codec S_ServerCodec, req *S_ReqHeader) (argv
 	return
 }
 
+// 获取请求的方法信息，方法不存在时返回 nil
+func (svrc *s_Service) method(name string) *s_MethodInfo {
+	return svrc.methods[name]
+}
+
 // 根据请求调用服务
-func (svrc *s_Service) call(argv reflect.Value, req *S_ReqHeader) (reply interface{}, err error) {
+func (svrc *s_Service) call(ctx context.Context, argv reflect.Value, req *S_ReqHeader) (reply interface{}, err error) {
 	// 获取参数时，已经验证过一次，所以这里一定存在，不需要判断第二个返回值
 	methodInfo, _ := svrc.methods[req.MethodName]
 
-	// 构建返回值参数
-	replyv := methodInfo._newReply()
+	// 构建返回值参数，流方法传入流对象，没有回复参数
+	var replyv reflect.Value
+	if methodInfo.stream {
+		replyv = reflect.ValueOf(streamFromContext(ctx))
+	} else {
+		replyv = methodInfo._newReply()
+	}
 
 	// 调用服务器函数
 	methodInfo.Lock()
 	methodInfo.numCalls++
 	methodInfo.Unlock()
 	fun := methodInfo.method.Func
-	rets := fun.Call([]reflect.Value{svrc.rcvr, argv, replyv})
-	reply = replyv.Interface()
+	var rets []reflect.Value
+	if methodInfo.withCtx {
+		rets = fun.Call([]reflect.Value{svrc.rcvr, reflect.ValueOf(ctx), argv, replyv})
+	} else {
+		rets = fun.Call([]reflect.Value{svrc.rcvr, argv, replyv})
+	}
+	if !methodInfo.stream {
+		reply = replyv.Interface()
+	}
 	errInter := rets[0].Interface()
 	if errInter != nil {
 		err = errInter.(error)
@@ -121,8 +145,12 @@ func (svrc *s_Service) call(argv reflect.Value, req *S_ReqHeader) (reply interfa
 // -----------------------------------------------------------------------------
 // inner functions
 // -----------------------------------------------------------------------------
+var rtypeContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+var rtypeStream = reflect.TypeOf((*S_Stream)(nil))
+
 // 获取注册服务的所有远程可调用方法
 func _takeMethods(rcvrType reflect.Type) (methods map[string]*s_MethodInfo) {
+	methods = make(map[string]*s_MethodInfo)
 	for n := 0; n < rcvrType.NumMethod(); n++ {
 		method := rcvrType.Method(n)
 		mtype := method.Type
@@ -139,21 +167,25 @@ func _takeMethods(rcvrType reflect.Type) (methods map[string]*s_MethodInfo) {
 			continue
 		}
 
-		// 必须有且只有三个参数（第一个参数为处理器对象的指针）
-		if mtype.NumIn() != 3 {
+		// 第一个参数为处理器对象的指针，如果第二个参数是 context.Context，则其后再跟两个参数
+		withCtx := mtype.NumIn() == 4 && mtype.In(1) == rtypeContext
+		argIndex := 1
+		if withCtx {
+			argIndex = 2
+		} else if mtype.NumIn() != 3 {
 			fslog.Errorf("fsrpc: rpc method %q must be only contain 3 arguments!\n", mname)
 			continue
 		}
 
-		// 第二个参数必须是一个可访问或内建类型参数
-		argType := mtype.In(1)
-		if !fsky.IsExposedOrBuiltinType(argType) {
+		// 请求参数必须是一个可访问或内建类型参数
+		argType := mtype.In(argIndex)
+		if !fsreflect.IsExposedOrBuiltinType(argType) {
 			fslog.Errorf("fsrpc: argument type of method %q is not exposed: %q\n", mname, argType)
 			continue
 		}
 
-		// 第三个参数必须是一个指针
-		replyType := mtype.In(2)
+		// 回复参数必须是一个指针
+		replyType := mtype.In(argIndex + 1)
 		if replyType.Kind() != reflect.Ptr {
 			fslog.Errorf("fsrpc: argument type of method %q is not a pointer but %q\n", mname, replyType)
 			continue
@@ -171,9 +203,10 @@ func _takeMethods(rcvrType reflect.Type) (methods map[string]*s_MethodInfo) {
 			continue
 		}
 
-		methods = make(map[string]*s_MethodInfo)
 		methods[mname] = &s_MethodInfo{
 			method:    method,
+			withCtx:   withCtx,
+			stream:    replyType == rtypeStream,
 			argType:   argType,
 			replyType: replyType,
 			numCalls:  0,
@@ -201,7 +234,7 @@ func newService(rcvr interface{}, sname string) (svr *s_Service, err error) {
 	svrName := reflect.Indirect(svr.rcvr).Type().Name()
 
 	// 处理器类型必须是 public
-	if !fsky.IsExposed(svrName) {
+	if !fsreflect.IsExposed(svrName) {
 		err = fmt.Errorf("service %q must be public!", svrName)
 		return
 	}
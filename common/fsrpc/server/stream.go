@@ -0,0 +1,160 @@
+/**
+@copyright: fantasysky 2016
+@brief: 服务器端流调用
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 流调用
+// 服务方法的第二个参数（回复参数）为 *server.S_Stream 时，该方法为流方法，如：
+//   func (*Svc) Watch_rpc(ctx context.Context, arg *S_WatchArg, stream *server.S_Stream) error
+// 服务方法可以通过 stream.Send 向客户端发送任意多个消息，方法返回后流调用结束
+// 双向流中，客户端后续发送的消息类型与请求参数类型相同，通过 stream.Recv 读取
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"fsky.pro/fsrpc"
+)
+
+// 流已经结束（服务方法已经返回）
+var ErrStreamClosed = errors.New("fsrpc: stream is closed")
+
+// 服务方法读取客户端流消息太慢，接收缓冲已满，之后的流消息被丢弃
+var ErrStreamOverflow = errors.New("fsrpc: stream receive buffer overflow")
+
+// 服务器未处理的客户端流消息的缓冲数
+// 读取链接的协程不能等待服务方法调用 Recv，否则同一链接上的其他请求都会被阻塞
+// 因此缓冲满后不再接收该流的消息，Recv 读完缓冲中的消息后返回 ErrStreamOverflow
+const streamRecvBuffer = 64
+
+type S_Stream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	header fsrpc.S_ReqHeader
+	method *s_MethodInfo               // 流方法，客户端流消息类型与其请求参数类型相同
+	send   func(msg interface{}) error // 向客户端发送流消息
+	recv   chan reflect.Value          // 客户端发送的流消息
+
+	recvMutex  sync.Mutex
+	recvClosed bool  // recv 已经关闭，之后到达的流消息被丢弃
+	recvErr    error // recv 关闭的原因，为 nil 表示客户端结束发送
+
+	mutex sync.Mutex
+	ended bool // 服务方法已经返回
+}
+
+type s_StreamKey struct{}
+
+// 服务方法调用 ctx 中携带流对象，以便经过拦截器后传给服务方法
+func contextWithStream(ctx context.Context, stream *S_Stream) context.Context {
+	return context.WithValue(ctx, s_StreamKey{}, stream)
+}
+
+func streamFromContext(ctx context.Context) *S_Stream {
+	stream, _ := ctx.Value(s_StreamKey{}).(*S_Stream)
+	return stream
+}
+
+// -------------------------------------------------------------------
+// S_Stream inner methods
+// -------------------------------------------------------------------
+// 放入一个客户端流消息，流已经结束或者客户端已经结束发送则丢弃
+// 缓冲已满则关闭接收，不等待服务方法读取
+func (this *S_Stream) _push(argv reflect.Value) {
+	this.recvMutex.Lock()
+	defer this.recvMutex.Unlock()
+	if this.recvClosed || this.ctx.Err() != nil {
+		return
+	}
+	select {
+	case this.recv <- argv:
+	default:
+		this._closeRecvLocked(ErrStreamOverflow)
+	}
+}
+
+// 客户端结束发送
+func (this *S_Stream) _closeRecv() {
+	this.recvMutex.Lock()
+	defer this.recvMutex.Unlock()
+	this._closeRecvLocked(nil)
+}
+
+func (this *S_Stream) _closeRecvLocked(err error) {
+	if this.recvClosed {
+		return
+	}
+	this.recvClosed = true
+	this.recvErr = err
+	close(this.recv)
+}
+
+// 服务方法返回，之后的 Send 返回 ErrStreamClosed
+func (this *S_Stream) _end() {
+	this.mutex.Lock()
+	this.ended = true
+	this.mutex.Unlock()
+	this.cancel()
+}
+
+// -------------------------------------------------------------------
+// S_Stream public methods
+// -------------------------------------------------------------------
+// 流调用的 context，客户端取消流调用或者超过截止时间时结束
+func (this *S_Stream) Context() context.Context {
+	return this.ctx
+}
+
+// 向客户端发送一个流消息
+// 客户端已经取消流调用时返回 ctx.Err()，服务方法返回后调用则返回 ErrStreamClosed
+func (this *S_Stream) Send(msg interface{}) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.ended {
+		return ErrStreamClosed
+	}
+	if err := this.ctx.Err(); err != nil {
+		return err
+	}
+	return this.send(msg)
+}
+
+// 读取一个客户端发送的流消息，msg 必须是请求参数类型的指针
+// 客户端结束发送后返回 io.EOF，接收缓冲溢出后返回 ErrStreamOverflow
+// 客户端取消流调用或者超过截止时间时返回 ctx.Err()
+func (this *S_Stream) Recv(msg interface{}) error {
+	msgv := reflect.ValueOf(msg)
+	if msgv.Kind() != reflect.Ptr || msgv.IsNil() {
+		return errors.New("fsrpc: stream message receiver must be a non-nil pointer")
+	}
+	select {
+	case argv, ok := <-this.recv:
+		if !ok {
+			this.recvMutex.Lock()
+			defer this.recvMutex.Unlock()
+			if this.recvErr != nil {
+				return this.recvErr
+			}
+			return io.EOF
+		}
+		if argv.Kind() == reflect.Ptr && msgv.Type() == argv.Type() {
+			argv = argv.Elem()
+		}
+		if !argv.Type().AssignableTo(msgv.Elem().Type()) {
+			return errors.New("fsrpc: stream message type must be " + this.method.argType.String() +
+				", but not " + msgv.Elem().Type().String())
+		}
+		msgv.Elem().Set(argv)
+		return nil
+	case <-this.ctx.Done():
+		return this.ctx.Err()
+	}
+}
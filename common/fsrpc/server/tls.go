@@ -0,0 +1,175 @@
+/**
+@copyright: fantasysky 2016
+@brief: 服务器 TLS 配置
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 服务器 TLS 配置，支持双向 TLS（校验客户端证书），证书文件修改后自动重新加载，如：
+//   stls, err := server.NewServerTLS(server.S_TLSConfig{
+//       CertFile:     "server.pem",
+//       KeyFile:      "server.key",
+//       ClientCAFile: "ca.pem", // 不为空则要求客户端证书
+//   })
+//   go svr.ServeTCPTLS("", 9000, stls.Config())
+//   go svr.ServeHTTP("", 9001, &server.S_HttpServeArg{TLSConfig: stls.Config()})
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"fsky.pro/fslog"
+	"fsky.pro/fsrpc"
+)
+
+// TLS 参数
+type S_TLSConfig struct {
+	CertFile     string // 服务器证书（pem）
+	KeyFile      string // 服务器私钥（pem）
+	ClientCAFile string // 签发客户端证书的 CA（pem），不为空时要求客户端提供证书并校验
+
+	// 检查证书文件是否修改的最小间隔，默认 10 秒，小于 0 则不自动重新加载
+	// 检查在 TLS 握手时进行，不另起协程
+	ReloadInterval time.Duration
+}
+
+type S_ServerTLS struct {
+	conf S_TLSConfig
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time // 已加载的文件的修改时间
+
+	reloadLock sync.Mutex
+	checked    time.Time // 上次检查文件修改的时间
+}
+
+// 加载证书文件，文件无效时返回错误
+func NewServerTLS(conf S_TLSConfig) (*S_ServerTLS, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, errors.New("fsrpc: tls cert file and key file are required")
+	}
+	if conf.ReloadInterval == 0 {
+		conf.ReloadInterval = 10 * time.Second
+	}
+	stls := &S_ServerTLS{conf: conf}
+	if err := stls.Reload(); err != nil {
+		return nil, err
+	}
+	return stls, nil
+}
+
+// -------------------------------------------------------------------
+// S_ServerTLS inner methods
+// -------------------------------------------------------------------
+func (this *S_ServerTLS) _files() []string {
+	files := []string{this.conf.CertFile, this.conf.KeyFile}
+	if this.conf.ClientCAFile != "" {
+		files = append(files, this.conf.ClientCAFile)
+	}
+	return files
+}
+
+// 距上次检查超过 ReloadInterval 并且文件有修改时，重新加载
+func (this *S_ServerTLS) _checkReload() {
+	if this.conf.ReloadInterval < 0 {
+		return
+	}
+	this.reloadLock.Lock()
+	defer this.reloadLock.Unlock()
+	if time.Since(this.checked) < this.conf.ReloadInterval {
+		return
+	}
+	this.checked = time.Now()
+
+	this.mutex.RLock()
+	modTimes := this.modTimes
+	this.mutex.RUnlock()
+	changed := false
+	for _, file := range this._files() {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(modTimes[file]) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := this.Reload(); err != nil {
+		fslog.Errorf("fsrpc: reload tls certificate fail, keep using the old one: %v", err)
+	} else {
+		fslog.Infof("fsrpc: tls certificate %s is reloaded", this.conf.CertFile)
+	}
+}
+
+func (this *S_ServerTLS) _getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	this._checkReload()
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.cert, nil
+}
+
+// 每次握手以当前加载的证书生成配置
+func (this *S_ServerTLS) _getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	this._checkReload()
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*this.cert},
+	}
+	if this.clientCAs != nil {
+		conf.ClientCAs = this.clientCAs
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return conf, nil
+}
+
+// -------------------------------------------------------------------
+// S_ServerTLS public methods
+// -------------------------------------------------------------------
+// 重新加载证书文件，加载失败时继续使用原来的证书
+func (this *S_ServerTLS) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range this._files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(this.conf.CertFile, this.conf.KeyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if this.conf.ClientCAFile != "" {
+		if clientCAs, err = fsrpc.LoadCertPool(this.conf.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.cert = &cert
+	this.clientCAs = clientCAs
+	this.modTimes = modTimes
+	return nil
+}
+
+// 服务器使用的 tls.Config，每次握手时使用最新加载的证书
+func (this *S_ServerTLS) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetCertificate:     this._getCertificate,
+		GetConfigForClient: this._getConfigForClient,
+	}
+}
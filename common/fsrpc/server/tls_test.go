@@ -0,0 +1,185 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/gobcodec"
+	"fsky.pro/fsrpc/server"
+)
+
+type Arith int
+
+func (this *Arith) Add_rpc(arg [2]int, reply *int) error {
+	*reply = arg[0] + arg[1]
+	return nil
+}
+
+func callTimeout(c *client.S_Client, r *int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return c.CallContext(ctx, "Arith.Add_rpc", [2]int{1, 2}, r)
+}
+
+type certKey struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func genCert(t *testing.T, dir, name string, parent *certKey, isCA bool, client bool) *certKey {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if isCA {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	kder, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600)
+	return &certKey{cert, key}
+}
+
+// 测试双向 TLS、CA 固定和证书重新加载，证书在测试中生成
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := genCert(t, dir, "ca", nil, true, false)
+	genCert(t, dir, "server", ca, false, false)
+	genCert(t, dir, "client", ca, false, true)
+	other := genCert(t, dir, "otherca", nil, true, false)
+	genCert(t, dir, "rogue", other, false, true)
+	p := func(n string) string { return filepath.Join(dir, n) }
+
+	stls, err := server.NewServerTLS(server.S_TLSConfig{
+		CertFile: p("server.pem"), KeyFile: p("server.key"), ClientCAFile: p("ca.pem"),
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr := server.NewServer(func(rwc io.ReadWriteCloser) server.S_ServerCodec { return gobcodec.NewServerCodec(rwc) })
+	svr.Register(new(Arith))
+	lis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go svr.ServeTLS(lis, stls.Config())
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+	hlis, _ := net.Listen("tcp", "127.0.0.1:0")
+	go svr.ServeHTTPListener(hlis, &server.S_HttpServeArg{TLSConfig: stls.Config()})
+	hport := uint16(hlis.Addr().(*net.TCPAddr).Port)
+
+	good, err := client.NewClientTLSConfig(client.S_TLSOptions{CAFile: p("ca.pem"), CertFile: p("client.pem"), KeyFile: p("client.key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(gobcodec.NewClienCodec())
+	if err := c.DialTLS("127.0.0.1", port, good); err != nil {
+		t.Fatal(err)
+	}
+	var r int
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+	c.Close()
+
+	hc := client.NewClient(gobcodec.NewClienCodec())
+	if err := hc.DialHTTPSPath("127.0.0.1", hport, fsrpc.DefaultHTTPPath, good); err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.Call("Arith.Add_rpc", [2]int{3, 4}, &r); err != nil || r != 7 {
+		t.Fatal(err, r)
+	}
+	hc.Close()
+
+	mc := client.NewManagedClient(func() client.I_ClientCodec { return gobcodec.NewClienCodec() }, client.TLSDialer("127.0.0.1", port, good), 2)
+	mc.Start()
+	time.Sleep(100 * time.Millisecond)
+	if err := mc.Call("Arith.Add_rpc", [2]int{5, 5}, &r); err != nil || r != 10 {
+		t.Fatal(err, r)
+	}
+	mc.Close()
+
+	// 没有客户端证书
+	noCert, _ := client.NewClientTLSConfig(client.S_TLSOptions{CAFile: p("ca.pem")})
+	bad := client.NewClient(gobcodec.NewClienCodec())
+	if err := bad.DialTLS("127.0.0.1", port, noCert); err == nil {
+		if err := callTimeout(bad, &r); err == nil {
+			t.Fatal("client without cert should be rejected")
+		}
+		bad.Close()
+	}
+
+	// 其他 CA 签发的客户端证书
+	rogue, _ := client.NewClientTLSConfig(client.S_TLSOptions{CAFile: p("ca.pem"), CertFile: p("rogue.pem"), KeyFile: p("rogue.key")})
+	bad = client.NewClient(gobcodec.NewClienCodec())
+	if err := bad.DialTLS("127.0.0.1", port, rogue); err == nil {
+		if err := callTimeout(bad, &r); err == nil {
+			t.Fatal("client with rogue cert should be rejected")
+		}
+		bad.Close()
+	}
+
+	// 客户端只信任其他 CA
+	pinned, _ := client.NewClientTLSConfig(client.S_TLSOptions{CAFile: p("otherca.pem"), CertFile: p("client.pem"), KeyFile: p("client.key")})
+	if err := client.NewClient(gobcodec.NewClienCodec()).DialTLS("127.0.0.1", port, pinned); err == nil {
+		t.Fatal("server cert not signed by pinned ca should fail")
+	}
+
+	// 证书轮换
+	time.Sleep(20 * time.Millisecond)
+	newSvr := genCert(t, dir, "server", ca, false, false)
+	future := time.Now().Add(time.Second)
+	os.Chtimes(p("server.pem"), future, future)
+	os.Chtimes(p("server.key"), future, future)
+	time.Sleep(20 * time.Millisecond)
+	var seen *x509.Certificate
+	seenConf := good.Clone()
+	seenConf.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+		seen, _ = x509.ParseCertificate(raw[0])
+		return nil
+	}
+	c = client.NewClient(gobcodec.NewClienCodec())
+	if err := c.DialTLS("127.0.0.1", port, seenConf); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil {
+		t.Fatal(err)
+	}
+	if seen == nil || seen.SerialNumber.Cmp(newSvr.cert.SerialNumber) != 0 {
+		t.Fatal("server cert is not reloaded")
+	}
+	c.Close()
+}
@@ -0,0 +1,139 @@
+package fsrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc"
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/server"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	svr := newGobServer()
+	svr.Register(newArith())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- svr.Serve(lis) }()
+	port := uint16(lis.Addr().(*net.TCPAddr).Port)
+
+	busy := dialGob(t, port)
+	idle := dialGob(t, port)
+	var r int
+	if err := idle.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil {
+		t.Fatal(err)
+	}
+	var sr int
+	slow := busy.Go("Arith.Slow_rpc", 300, &sr, make(chan *client.S_ReqInfo, 1))
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- svr.Shutdown(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+
+	// 正在关闭时，链接上新到达的请求被拒绝
+	err = busy.Call("Arith.Add_rpc", [2]int{1, 2}, &r)
+	if err != client.S_ServerError(fsrpc.ShutdownText) {
+		t.Fatal("expect shutdown rejection, got", err)
+	}
+
+	// 正在处理的请求正常回复
+	if req := <-slow.ReqCh; req.Error != nil || sr != 300 {
+		t.Fatal(req.Error, sr)
+	}
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown is not finished after in-flight request replied")
+	}
+	if cost := time.Since(start); cost < 200*time.Millisecond {
+		t.Fatalf("shutdown returned after %v, before in-flight request finished", cost)
+	}
+	if err := <-serveErr; err != server.ErrServerClosed {
+		t.Fatal("expect ErrServerClosed, got", err)
+	}
+	if err := idle.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err == nil {
+		t.Fatal("idle connection should be closed")
+	}
+	if err := svr.Serve(lis); err != server.ErrServerClosed {
+		t.Fatal("expect ErrServerClosed, got", err)
+	}
+}
+
+func TestShutdownForce(t *testing.T) {
+	svr := newGobServer()
+	svr.Register(newArith())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 测试自己强制关闭服务，不使用 serve（serve 在测试结束时还会等待正在处理的请求）
+	go svr.Serve(lis)
+	c := dialGob(t, uint16(lis.Addr().(*net.TCPAddr).Port))
+	slow := c.Go("Arith.Slow_rpc", 5000, new(int), make(chan *client.S_ReqInfo, 1))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := svr.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal("expect context.DeadlineExceeded, got", err)
+	}
+	select {
+	case req := <-slow.ReqCh:
+		if req.Error == nil {
+			t.Fatal("in-flight request should fail after forced close")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("in-flight request is not failed after forced close")
+	}
+}
+
+// 关闭服务时取消正在进行的流调用，不等待流方法自行结束
+func TestShutdownStream(t *testing.T) {
+	streamer := newStreamer()
+	svr := newGobServer()
+	svr.Register(streamer)
+	c := dialGob(t, serve(t, svr))
+	st, err := c.Stream(context.Background(), "Streamer.Forever_rpc", 0, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			var v int
+			if err := st.Recv(&v); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := svr.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-streamer.stopped; err != context.Canceled {
+		t.Fatal("expect context.Canceled, got", err)
+	}
+	select {
+	case err := <-recvErr:
+		if err == nil {
+			t.Fatal("stream should fail after shutdown")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream is not ended after shutdown")
+	}
+}
@@ -0,0 +1,263 @@
+package fsrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"fsky.pro/fsrpc/client"
+	"fsky.pro/fsrpc/server"
+)
+
+type Streamer struct {
+	start   chan struct{} // Hold_rpc 收到信号后才开始读取流消息
+	stopped chan error    // Forever_rpc 返回的错误
+}
+
+func (this *Streamer) Count_rpc(n int, stream *server.S_Stream) error {
+	for i := 0; i < n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+	}
+	if n < 0 {
+		return errors.New("bad count")
+	}
+	return nil
+}
+
+func (this *Streamer) Echo_rpc(prefix string, stream *server.S_Stream) error {
+	for {
+		var s string
+		err := stream.Recv(&s)
+		if err == io.EOF {
+			return stream.Send(prefix + "done")
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(prefix + s); err != nil {
+			return err
+		}
+	}
+}
+
+func (this *Streamer) Hold_rpc(_ int, stream *server.S_Stream) error {
+	<-this.start
+	n := 0
+	for {
+		var v int
+		if err := stream.Recv(&v); err == io.EOF {
+			return stream.Send(n)
+		} else if err != nil {
+			stream.Send(n)
+			return err
+		}
+		n++
+	}
+}
+
+func (this *Streamer) Forever_rpc(ctx context.Context, _ int, stream *server.S_Stream) error {
+	for i := 0; ; i++ {
+		if err := stream.Send(i); err != nil {
+			this.stopped <- err
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newStreamer() *Streamer {
+	return &Streamer{start: make(chan struct{}), stopped: make(chan error, 1)}
+}
+
+func startStreamer(t *testing.T) (*Streamer, *client.S_Client) {
+	streamer := newStreamer()
+	svr := newGobServer()
+	if err := svr.Register(streamer); err != nil {
+		t.Fatal(err)
+	}
+	if err := svr.Register(newArith()); err != nil {
+		t.Fatal(err)
+	}
+	return streamer, dialGob(t, serve(t, svr))
+}
+
+func recvStrings(t *testing.T, st *client.S_Stream) []string {
+	var out []string
+	for {
+		var s string
+		if err := st.Recv(&s); err == io.EOF {
+			return out
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s)
+	}
+}
+
+func TestStreamServerSend(t *testing.T) {
+	_, c := startStreamer(t)
+	// 服务器连续发送，不超过客户端的接收缓冲
+	st, err := c.Stream(context.Background(), "Streamer.Count_rpc", 60, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		var v int
+		if err := st.Recv(&v); err == io.EOF {
+			break
+		} else if err != nil || v != n {
+			t.Fatal(err, v, n)
+		}
+		n++
+	}
+	if n != 60 {
+		t.Fatal("received", n)
+	}
+
+	st, err = c.Stream(context.Background(), "Streamer.Count_rpc", -1, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	if err := st.Recv(&v); err != client.T_ServiceError("bad count") {
+		t.Fatal("expect service error, got", err)
+	}
+}
+
+func TestStreamBidirectional(t *testing.T) {
+	_, c := startStreamer(t)
+	st, err := c.Stream(context.Background(), "Streamer.Echo_rpc", "> ", new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Send("a")
+	st.Send("b")
+	st.CloseSend()
+	if out := fmt.Sprint(recvStrings(t, st)); out != "[> a > b > done]" {
+		t.Fatal(out)
+	}
+}
+
+// 客户端结束发送后继续发送，服务器丢弃这些消息，不能 panic
+func TestStreamSendAfterCloseSend(t *testing.T) {
+	streamer, c := startStreamer(t)
+	st, err := c.Stream(context.Background(), "Streamer.Hold_rpc", 0, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Send(1)
+	st.CloseSend()
+	st.Send(2)
+	st.CloseSend()
+	var r int
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+	close(streamer.start)
+
+	var n int
+	if err := st.Recv(&n); err != nil || n != 1 {
+		t.Fatal(err, n)
+	}
+	if err := st.Recv(&n); err != io.EOF {
+		t.Fatal("expect io.EOF, got", err)
+	}
+}
+
+// 服务方法不读取时，客户端流消息不能阻塞链接上的其他请求
+func TestStreamRecvOverflow(t *testing.T) {
+	streamer, c := startStreamer(t)
+	st, err := c.Stream(context.Background(), "Streamer.Hold_rpc", 0, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := st.Send(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var r int
+	if err := c.CallContext(ctx, "Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+	close(streamer.start)
+
+	var n int
+	if err := st.Recv(&n); err != nil || n != 64 {
+		t.Fatal(err, n)
+	}
+	if err := st.Recv(&n); err != client.T_ServiceError(server.ErrStreamOverflow.Error()) {
+		t.Fatal("expect overflow error, got", err)
+	}
+}
+
+// 客户端不读取时，服务器流消息不能阻塞链接上的其他回复，缓冲溢出后流被关闭
+func TestStreamClientOverflow(t *testing.T) {
+	streamer, c := startStreamer(t)
+	st, err := c.Stream(context.Background(), "Streamer.Forever_rpc", 0, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	time.Sleep(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var r int
+	if err := c.CallContext(ctx, "Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+	select {
+	case <-streamer.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("server stream is not cancelled after overflow")
+	}
+
+	for i := 0; i < 64; i++ {
+		var v int
+		if err := st.Recv(&v); err != nil || v != i {
+			t.Fatal(err, v, i)
+		}
+	}
+	var v int
+	if err := st.Recv(&v); err != client.ErrStreamOverflow {
+		t.Fatal("expect overflow error, got", err)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	streamer, c := startStreamer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	st, err := c.Stream(ctx, "Streamer.Forever_rpc", 0, new(int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var v int
+		if err := st.Recv(&v); err != nil {
+			var te *client.S_TimeoutError
+			if !errors.As(err, &te) {
+				t.Fatal("expect timeout, got", err)
+			}
+			break
+		}
+	}
+	select {
+	case <-streamer.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("server stream is not cancelled")
+	}
+
+	var r int
+	if err := c.Call("Arith.Add_rpc", [2]int{1, 2}, &r); err != nil || r != 3 {
+		t.Fatal(err, r)
+	}
+}
@@ -0,0 +1,28 @@
+/**
+@copyright: fantasysky 2016
+@brief: TLS 公共函数
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package fsrpc
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// 读取 pem 文件中的所有证书，用于校验对方证书的 CA
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("fsrpc: no certificate is found in %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,187 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: asynchronous log writer
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 异步日志输出
+// 日志先写入一个固定大小的环形缓冲区，由后台 goroutine 依次交给真正的输出函数
+// 缓冲区满时根据溢出策略阻塞或丢弃日志
+
+package fslog
+
+import (
+	"sync"
+	"time"
+)
+
+// 缓冲区满时的处理策略
+type T_Overflow int
+
+const (
+	OverflowBlock      T_Overflow = iota // 阻塞等待，不丢弃日志
+	OverflowDropOldest                   // 丢弃缓冲区中最旧的日志
+	OverflowDropNewest                   // 丢弃当前要写入的日志
+)
+
+type s_AsyncLog struct {
+	t      time.Time
+	lv     T_Level
+	msg    []byte
+	fields T_Fields
+}
+
+// -----------------------------------------------------------------------------
+// AsyncWriter
+// -----------------------------------------------------------------------------
+type S_AsyncWriter struct {
+	writer   F_Writer
+	overflow T_Overflow
+
+	// 有日志被丢弃时，在后台 goroutine 中回调，参数为上次回调以来丢弃的数量
+	OnDropped func(uint64)
+
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	queue    []s_AsyncLog
+	head     int
+	count    int
+	writing  bool   // 后台 goroutine 是否正在输出
+	closed   bool   // 是否已经关闭
+	dropped  uint64 // 未回调 OnDropped 的丢弃数量
+	total    uint64 // 累计丢弃数量
+	done     chan struct{}
+}
+
+// size 为缓冲区能容纳的日志条数，小于 1 时为 1
+func NewAsyncWriter(writer F_Writer, size int, overflow T_Overflow) *S_AsyncWriter {
+	if size < 1 {
+		size = 1
+	}
+	w := &S_AsyncWriter{
+		writer:   writer,
+		overflow: overflow,
+		queue:    make([]s_AsyncLog, size),
+		done:     make(chan struct{}),
+	}
+	w.notEmpty = sync.NewCond(&w.mutex)
+	w.notFull = sync.NewCond(&w.mutex)
+	w.idle = sync.NewCond(&w.mutex)
+	go w.loop()
+	return w
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+func (this *S_AsyncWriter) loop() {
+	this.mutex.Lock()
+	for {
+		for this.count == 0 && this.dropped == 0 && !this.closed {
+			this.notEmpty.Wait()
+		}
+		dropped := this.dropped
+		this.dropped = 0
+		if dropped > 0 && this.OnDropped != nil {
+			this.mutex.Unlock()
+			this.OnDropped(dropped)
+			this.mutex.Lock()
+		}
+		if this.count == 0 {
+			if this.closed {
+				break
+			}
+			continue
+		}
+
+		log := this.queue[this.head]
+		this.queue[this.head] = s_AsyncLog{}
+		this.head = (this.head + 1) % len(this.queue)
+		this.count--
+		this.writing = true
+		this.notFull.Signal()
+		this.mutex.Unlock()
+
+		this.writer(log.t, log.lv, log.msg, log.fields)
+
+		this.mutex.Lock()
+		this.writing = false
+		if this.count == 0 {
+			this.idle.Broadcast()
+		}
+	}
+	this.idle.Broadcast()
+	this.mutex.Unlock()
+	close(this.done)
+}
+
+// 调用前必须已经上锁
+func (this *S_AsyncWriter) drop() {
+	this.dropped++
+	this.total++
+	this.notEmpty.Signal()
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 与 F_Writer 签名一致，关闭后直接同步输出
+func (this *S_AsyncWriter) Write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
+	this.mutex.Lock()
+	for !this.closed && this.count == len(this.queue) {
+		switch this.overflow {
+		case OverflowDropNewest:
+			this.drop()
+			this.mutex.Unlock()
+			return
+		case OverflowDropOldest:
+			this.queue[this.head] = s_AsyncLog{}
+			this.head = (this.head + 1) % len(this.queue)
+			this.count--
+			this.drop()
+		default:
+			this.notFull.Wait()
+		}
+	}
+	if this.closed {
+		this.mutex.Unlock()
+		this.writer(t, lv, msg, fields)
+		return
+	}
+	this.queue[(this.head+this.count)%len(this.queue)] = s_AsyncLog{t, lv, msg, fields}
+	this.count++
+	this.notEmpty.Signal()
+	this.mutex.Unlock()
+}
+
+// 等待缓冲区中的日志全部输出完毕
+func (this *S_AsyncWriter) Flush() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for this.count > 0 || this.writing {
+		this.idle.Wait()
+	}
+}
+
+// 输出缓冲区中剩余的日志，并结束后台 goroutine
+// 关闭后再写入的日志会同步输出
+func (this *S_AsyncWriter) Close() {
+	this.mutex.Lock()
+	this.closed = true
+	this.notEmpty.Broadcast()
+	this.notFull.Broadcast()
+	this.mutex.Unlock()
+	<-this.done
+}
+
+// 累计丢弃的日志数量
+func (this *S_AsyncWriter) Dropped() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.total
+}
@@ -16,8 +16,9 @@ import (
 )
 
 type S_ChanLog struct {
-	Level string
-	Msg   []byte
+	Level  string
+	Msg    []byte
+	Fields T_Fields // 结构化日志字段，非结构化日志为 nil
 }
 
 type S_ChanLogger struct {
@@ -30,14 +31,15 @@ type S_ChanLogger struct {
 // filePrefix 为 log 文件名前缀
 func NewChanLogger(ch chan *S_ChanLog) *S_ChanLogger {
 	logger := &S_ChanLogger{chMsg: ch}
+	logger.S_Logger = NewFieldsLogger(logger.write)
 	return logger
 }
 
 // -------------------------------------------------------------------
 // private
 // -------------------------------------------------------------------
-func (this *S_ChanLogger) write(t time.Time, level string, msg []byte) {
-	this.chMsg <- &S_ChanLog{level, msg}
+func (this *S_ChanLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
+	this.chMsg <- &S_ChanLog{string(lv), msg, fields}
 }
 
 // -------------------------------------------------------------------
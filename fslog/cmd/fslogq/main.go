@@ -0,0 +1,158 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: query fslog log files
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 查询 fslog 输出的日志文件，如：
+//   fslogq -dir ./logs -prefix server -since "2024-01-11 10:00" -until "2024-01-11 10:05" -level error -g 1234
+//   fslogq -level warn+ -e "db (down|timeout)" server_2024-01-11.log server_2024-01-11.log.1.gz
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fslog"
+	"fslog/fslogq"
+)
+
+// 时间参数支持的格式
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05.999999",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+}
+
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// 以逗号分隔的级别，"warn+" 表示 warn 及以上的级别
+func parseLevels(value string) ([]string, error) {
+	levels := []string{}
+	for _, lv := range strings.Split(value, ",") {
+		lv = strings.TrimSpace(lv)
+		if lv == "" {
+			continue
+		}
+		if strings.HasSuffix(lv, "+") {
+			lvs, err := fslog.LevelsFrom(strings.TrimSuffix(lv, "+"))
+			if err != nil {
+				return nil, err
+			}
+			levels = append(levels, lvs...)
+		} else {
+			levels = append(levels, strings.ToUpper(lv))
+		}
+	}
+	return levels, nil
+}
+
+// 以逗号分隔的 goroutine id，可以带 "G-" 前缀
+func parseGoIDs(value string) ([]uint64, error) {
+	goids := []uint64{}
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimPrefix(strings.TrimSpace(id), "G-")
+		if id == "" {
+			continue
+		}
+		goid, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid goroutine id %q", id)
+		}
+		goids = append(goids, goid)
+	}
+	return goids, nil
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "fslogq:", err)
+	os.Exit(2)
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory of log files")
+	prefix := flag.String("prefix", "", "prefix of log files, query all prefix_YYYY-MM-DD.log[.N][.gz] in -dir")
+	since := flag.String("since", "", "start time (inclusive), e.g. \"2006-01-02 15:04:05\"")
+	until := flag.String("until", "", "end time (exclusive), e.g. \"2006-01-02 15:04:05\"")
+	level := flag.String("level", "", "comma separated log levels, \"warn+\" means warn and above")
+	goid := flag.String("g", "", "comma separated goroutine ids")
+	expr := flag.String("e", "", "regular expression to match the log text")
+	utc := flag.Bool("utc", false, "times in log files and arguments are UTC")
+	withFile := flag.Bool("f", false, "print file name and line number before each log")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] [log files...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	location := time.Local
+	if *utc {
+		location = time.UTC
+	}
+	var err error
+	filter := &fslogq.S_Filter{}
+	if filter.Since, err = parseTime(*since, location); err != nil {
+		exit(err)
+	}
+	if filter.Until, err = parseTime(*until, location); err != nil {
+		exit(err)
+	}
+	if filter.Levels, err = parseLevels(*level); err != nil {
+		exit(err)
+	}
+	if filter.GoIDs, err = parseGoIDs(*goid); err != nil {
+		exit(err)
+	}
+	if *expr != "" {
+		if filter.Pattern, err = regexp.Compile(*expr); err != nil {
+			exit(err)
+		}
+	}
+
+	var files []fslogq.S_LogFile
+	if flag.NArg() > 0 {
+		files = fslogq.PathFiles(flag.Args()...)
+	} else if *prefix != "" {
+		if files, err = fslogq.DayFiles(*dir, *prefix); err != nil {
+			exit(err)
+		}
+	} else {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err = fslogq.Query(files, filter, location, func(entry *fslogq.S_Entry) bool {
+		if *withFile {
+			fmt.Printf("%s:%d: ", entry.File, entry.Line)
+		}
+		fmt.Println(entry.Text)
+		return true
+	})
+	if err != nil {
+		exit(err)
+	}
+}
@@ -0,0 +1,198 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: context aware logging
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 通过 context.Context 携带请求 ID 和日志字段，如：
+//   ctx = fslog.ContextWithRequestID(ctx, reqID)
+//   ctx = fslog.ContextWithFields(ctx, "uid", 123)
+//   fslog.InfoCtx(ctx, "login")              // 输出：... login reqid=xxx uid=123
+//   fslog.FromContext(ctx).Warnf("slow %v", cost)
+
+package fslog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// 请求 ID 在日志字段中的 key
+const RequestIDKey = "reqid"
+
+type t_CtxKey struct{}
+
+// context 中保存的日志信息
+type s_CtxInfo struct {
+	reqID  string
+	fields T_Fields
+}
+
+func ctxInfo(ctx context.Context) *s_CtxInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(t_CtxKey{}).(*s_CtxInfo)
+	return info
+}
+
+// context 中携带的所有日志字段，请求 ID 排在最前面
+func ctxFields(ctx context.Context) T_Fields {
+	info := ctxInfo(ctx)
+	if info == nil {
+		return nil
+	}
+	if info.reqID == "" {
+		return info.fields
+	}
+	return T_Fields{{RequestIDKey, info.reqID}}.With(info.fields)
+}
+
+// -----------------------------------------------------------------------------
+// context
+// -----------------------------------------------------------------------------
+// 生成一个随机的请求 ID（16 个十六进制字符）
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// 返回携带请求 ID 的 context
+func ContextWithRequestID(ctx context.Context, reqID string) context.Context {
+	info := &s_CtxInfo{reqID: reqID}
+	if old := ctxInfo(ctx); old != nil {
+		info.fields = old.fields
+	}
+	return context.WithValue(ctx, t_CtxKey{}, info)
+}
+
+// 返回在原有基础上追加了日志字段的 context
+func ContextWithFields(ctx context.Context, kvs ...any) context.Context {
+	info := &s_CtxInfo{}
+	if old := ctxInfo(ctx); old != nil {
+		*info = *old
+	}
+	info.fields = info.fields.With(Fields(kvs...))
+	return context.WithValue(ctx, t_CtxKey{}, info)
+}
+
+// 获取 context 中的请求 ID，没有则返回空字符串
+func RequestID(ctx context.Context) string {
+	if info := ctxInfo(ctx); info != nil {
+		return info.reqID
+	}
+	return ""
+}
+
+// 返回绑定了 context 中请求 ID 和日志字段的 logger
+func FromContext(ctx context.Context) *S_FieldLogger {
+	return newFieldLogger(nil, ctxFields(ctx))
+}
+
+// -----------------------------------------------------------------------------
+// package interfaces
+// -----------------------------------------------------------------------------
+func DebugCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "DEBUG", sprint(arg, args...), ctxFields(ctx))
+}
+
+func DebugfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "DEBUG", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func InfoCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "INFO", sprint(arg, args...), ctxFields(ctx))
+}
+
+func InfofCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "INFO", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func NoticCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "NOTIC", sprint(arg, args...), ctxFields(ctx))
+}
+
+func NoticfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "NOTIC", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func WarnCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "WARN", sprint(arg, args...), ctxFields(ctx))
+}
+
+func WarnfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "WARN", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func ErrorCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "ERROR", sprint(arg, args...), ctxFields(ctx))
+}
+
+func ErrorfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "ERROR", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func HackCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "HACK", sprint(arg, args...), ctxFields(ctx))
+}
+
+func HackfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "HACK", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func IllegCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "ILLEG", sprint(arg, args...), ctxFields(ctx))
+}
+
+func IllegfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "ILLEG", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func CriticalCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "CRIT", sprint(arg, args...), ctxFields(ctx))
+}
+
+func CriticalfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "CRIT", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func TraceCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "TRACE", sprint(arg, args...), ctxFields(ctx))
+}
+
+func TracefCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "TRACE", fmt.Sprintf(msg, args...), ctxFields(ctx))
+}
+
+func PanicCtx(ctx context.Context, arg any, args ...any) {
+	msg := sprint(arg, args...)
+	logger.Outputw(1, "PANIC", msg, ctxFields(ctx))
+	logger.Flush()
+	panic(msg)
+}
+
+func PanicfCtx(ctx context.Context, msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	logger.Outputw(1, "PANIC", msg, ctxFields(ctx))
+	logger.Flush()
+	panic(msg)
+}
+
+func FatalCtx(ctx context.Context, arg any, args ...any) {
+	logger.Outputw(1, "FATAL", sprint(arg, args...), ctxFields(ctx))
+	logger.Flush()
+	os.Exit(1)
+}
+
+func FatalfCtx(ctx context.Context, msg string, args ...any) {
+	logger.Outputw(1, "FATAL", fmt.Sprintf(msg, args...), ctxFields(ctx))
+	logger.Flush()
+	os.Exit(1)
+}
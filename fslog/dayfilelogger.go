@@ -13,11 +13,17 @@ package fslog
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fsky.pro/fstime"
@@ -67,11 +73,23 @@ type S_DayfileLogger struct {
 	prefix      string
 	logPath     string
 	file        *os.File
+	fileSize    int64
 	nextDayTime time.Time
+	splitTime   time.Time // 重新打开已有 log 文件的时间，不为零时在下一条日志前写入分隔行
 
 	newLogCmd   *s_NewLogCmd
 	newLogCB    func(string, error)
+	rotateCB    func(string)
 	newLinkFile string
+
+	maxSize  int64         // 单个 log 文件最大尺寸，0 表示不限制
+	maxFiles int           // 最多保留的旧 log 文件数量，0 表示不限制
+	maxAge   time.Duration // 旧 log 文件最长保留时间，0 表示不限制
+	compress bool          // 是否 gzip 压缩旧 log 文件
+	closed   bool          // 已经调用了 Close，之后的日志不再写入
+
+	rotateMutex sync.Mutex     // 保证压缩和清理旧文件串行执行
+	rotateWG    sync.WaitGroup // 等待压缩和清理结束
 }
 
 // NewDayfileLogger，新建 DayfileLogger
@@ -87,8 +105,11 @@ func NewDayfileLogger(root string, filePrefix string) *S_DayfileLogger {
 		nextDayTime: fstime.Dawn(time.Now()).AddDate(0, 0, 1),
 		newLogCmd:   newLogCmd(""),
 	}
-	logger.S_Logger = NewLogger(logger.write)
-	logger.logPath, logger.file, _ = logger.newLogFile(time.Now())
+	logger.S_Logger = NewFieldsLogger(logger.write)
+	logPath, file, err := logger.newLogFile(time.Now())
+	if err == nil {
+		logger.setLogFile(logPath, file)
+	}
 	return logger
 }
 
@@ -113,6 +134,7 @@ func (this *S_DayfileLogger) newLogFile(t time.Time) (string, *os.File, error) {
 	logPath := this.getLogFilePath(t)
 	exists := fileExists(logPath)
 	if !exists {
+		this.splitTime = time.Time{}
 		file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE, 0666)
 		if this.newLogCB != nil {
 			this.newLogCB(logPath, err)
@@ -132,24 +154,101 @@ func (this *S_DayfileLogger) newLogFile(t time.Time) (string, *os.File, error) {
 		err = fmt.Errorf("open log file %q fail, %v", logPath, err)
 		return logPath, file, err
 	}
-	file.WriteString("\n")
-	splitter := fmt.Sprintf("%[1]s %[2]s %[1]s", strings.Repeat("-", 50), t.Format("15:04:05"))
-	file.WriteString(splitter)
-	file.WriteString("\n")
+	// 分隔行在写入第一条日志时写入，那时才能确定日志格式
+	this.splitTime = t
 	return logPath, file, nil
 }
 
+// 重新打开已经存在的 log 文件后，在第一条日志前写入分隔行
+// 分隔行是纯文本，非文本格式（如 JSON lines）的 log 文件不写入，以免破坏文件格式
+func (this *S_DayfileLogger) writeSplitter() {
+	if this.splitTime.IsZero() {
+		return
+	}
+	t := this.splitTime
+	this.splitTime = time.Time{}
+	if _, ok := this.formatter.(*S_TextFormatter); !ok {
+		return
+	}
+	splitter := fmt.Sprintf("\n%[1]s %[2]s %[1]s\n", strings.Repeat("-", 50), t.Format("15:04:05"))
+	if n, err := this.file.WriteString(splitter); err == nil {
+		this.fileSize += int64(n)
+	}
+}
+
+// 设置当前写入的 log 文件
+func (this *S_DayfileLogger) setLogFile(logPath string, file *os.File) {
+	this.logPath = logPath
+	this.file = file
+	this.fileSize = 0
+	if info, err := file.Stat(); err == nil {
+		this.fileSize = info.Size()
+	}
+}
+
+// 写入 msg 后是否会超过文件最大尺寸
+// 空文件总是可以写入，以免单条日志大于最大尺寸时不断切分
+func (this *S_DayfileLogger) isFull(size int) bool {
+	if this.maxSize <= 0 || this.fileSize == 0 {
+		return false
+	}
+	return this.fileSize+int64(size) > this.maxSize
+}
+
+// 当天 log 文件超过尺寸时，将其重命名为 prefix_YYYY-MM-DD.log.N
+// N 为当天已有切分文件的最大序号加 1，因此序号越大文件越新
+func (this *S_DayfileLogger) backupLogFile() (string, error) {
+	index := 0
+	matches, _ := filepath.Glob(this.logPath + ".*")
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(match, this.logPath+"."), ".gz")
+		if n, err := strconv.Atoi(suffix); err == nil && n > index {
+			index = n
+		}
+	}
+	bakPath := fmt.Sprintf("%s.%d", this.logPath, index+1)
+	if err := os.Rename(this.logPath, bakPath); err != nil {
+		return "", fmt.Errorf("rename log file %q to %q fail, %v", this.logPath, bakPath, err)
+	}
+	return bakPath, nil
+}
+
 // 父类中的 send 函数中已经 lock，因此这里不需要再上锁了
-func (this *S_DayfileLogger) write(t time.Time, lv T_Level, msg []byte) {
+func (this *S_DayfileLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
+	if this.closed {
+		return
+	}
 	now := this.nowTime()
-	if this.file != nil && now.Before(this.nextDayTime) {
-		if _, err := this.file.Write(msg); err == nil {
+	sameDay := now.Before(this.nextDayTime)
+	if this.file != nil && sameDay && !this.isFull(len(msg)) {
+		this.writeSplitter()
+		if n, err := this.file.Write(msg); err == nil {
+			this.fileSize += int64(n)
 			return
 		}
 	}
+
+	// 切分出来的旧 log 文件
+	oldPath, bakPath := "", ""
 	if this.file != nil {
 		this.file.Close()
+		this.file = nil
+		if !sameDay {
+			oldPath = this.logPath
+		} else if this.isFull(len(msg)) {
+			var err error
+			bakPath, err = this.backupLogFile()
+			if err != nil {
+				os.Stderr.WriteString(now.Format("[ERROR]|2006/01/02 15:04:05.999999 "))
+				os.Stderr.WriteString(err.Error())
+				os.Stderr.WriteString("\n")
+			} else if this.rotateCB != nil {
+				this.rotateCB(bakPath)
+			}
+			oldPath = bakPath
+		}
 	}
+
 	logPath, file, err := this.newLogFile(t)
 	if err != nil {
 		os.Stdout.Write(msg)
@@ -157,11 +256,122 @@ func (this *S_DayfileLogger) write(t time.Time, lv T_Level, msg []byte) {
 		os.Stderr.WriteString(err.Error())
 		os.Stderr.WriteString("\n")
 	} else {
-		file.Write(msg)
-		this.logPath = logPath
-		this.file = file
+		this.setLogFile(logPath, file)
+		this.writeSplitter()
+		if n, err := file.Write(msg); err == nil {
+			this.fileSize += int64(n)
+		}
 	}
 	this.nextDayTime = fstime.Dawn(time.Now().AddDate(0, 0, 1).Add(time.Hour))
+
+	if oldPath != "" || this.maxFiles > 0 || this.maxAge > 0 {
+		this.rotateWG.Add(1)
+		go this.afterRotate(oldPath, this.logPath, this.compress, this.maxFiles, this.maxAge)
+	}
+}
+
+// ---------------------------------------------------------
+// 切分出新 log 文件后，压缩旧文件，并清理过期文件
+// oldPath 为切分出来的旧文件，currPath 为当前正在写入的文件
+// 其余参数为切分时的配置，以免在锁外读取
+func (this *S_DayfileLogger) afterRotate(oldPath, currPath string, compress bool, maxFiles int, maxAge time.Duration) {
+	defer this.rotateWG.Done()
+	this.rotateMutex.Lock()
+	defer this.rotateMutex.Unlock()
+
+	if oldPath != "" && compress && fileExists(oldPath) {
+		if err := gzipFile(oldPath); err != nil {
+			this.Errorf("compress log file %q fail, %v", oldPath, err)
+		}
+	}
+	this.removeExpiredFiles(currPath, maxFiles, maxAge)
+}
+
+// 删除超过保留数量或保留时长的旧 log 文件
+func (this *S_DayfileLogger) removeExpiredFiles(currPath string, maxFiles int, maxAge time.Duration) {
+	if maxFiles <= 0 && maxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(this.dir)
+	if err != nil {
+		this.Errorf("read log directory %q fail, %v", this.dir, err)
+		return
+	}
+
+	ptn := regexp.MustCompile("^" + regexp.QuoteMeta(this.prefix) + `_\d{4}-\d{2}-\d{2}\.log(\.\d+)?(\.gz)?$`)
+	type s_LogFile struct {
+		path    string
+		modTime time.Time
+	}
+	logFiles := []s_LogFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !ptn.MatchString(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(this.dir, entry.Name())
+		if path == currPath {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		logFiles = append(logFiles, s_LogFile{path, info.ModTime()})
+	}
+	// 新文件在前
+	sort.Slice(logFiles, func(i, j int) bool {
+		return logFiles[i].modTime.After(logFiles[j].modTime)
+	})
+
+	now := time.Now()
+	for i, logFile := range logFiles {
+		expired := maxFiles > 0 && i >= maxFiles
+		expired = expired || (maxAge > 0 && now.Sub(logFile.modTime) > maxAge)
+		if !expired {
+			continue
+		}
+		if err := os.Remove(logFile.path); err != nil {
+			this.Errorf("remove expired log file %q fail, %v", logFile.path, err)
+		}
+	}
+}
+
+// 将文件压缩为 path.gz，压缩成功后删除原文件
+// 压缩文件保留原文件的修改时间，以免保留时长从压缩时开始计算
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	gzPath := path + ".gz"
+	dst, err := os.OpenFile(gzPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(gzPath, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
 }
 
 func (this *S_DayfileLogger) linkTo(logPath string) {
@@ -195,19 +405,55 @@ func (this *S_DayfileLogger) SetNewLogCallback(cb func(string, error)) {
 	this.newLogCB = cb
 }
 
+// 设置按尺寸切分回调，参数为切分出来的旧文件路径（压缩前）
+// 切分出旧文件后，同样会以新文件调用新建 log 文件回调和命令
+func (this *S_DayfileLogger) SetRotateCallback(cb func(string)) {
+	this.rotateCB = cb
+}
+
 // 对新产生对 log 文件进行软连接到指定地方
 func (this *S_DayfileLogger) SetNewLogLinkFile(path string) {
 	this.newLinkFile = path
 }
 
-func (this *S_DayfileLogger) Close() {
+// 设置单个 log 文件的最大尺寸（字节），超过后在当天内切分出 prefix_YYYY-MM-DD.log.N 文件
+// size 为 0 表示不限制尺寸，只在每天零点切分
+func (this *S_DayfileLogger) SetMaxSize(size int64) {
 	this.Lock()
 	defer this.Unlock()
+	this.maxSize = size
+}
+
+// 设置旧 log 文件的保留策略，每次切分出新文件时执行清理
+// maxFiles 为最多保留的旧文件数量（不包括当前正在写入的文件），0 表示不限制
+// maxAge 为旧文件（以最后修改时间计）最长保留时长，0 表示不限制
+func (this *S_DayfileLogger) SetRetention(maxFiles int, maxAge time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+	this.maxFiles = maxFiles
+	this.maxAge = maxAge
+}
+
+// 设置是否将切分出来的旧 log 文件压缩为 .gz 文件
+func (this *S_DayfileLogger) SetCompress(compress bool) {
+	this.Lock()
+	defer this.Unlock()
+	this.compress = compress
+}
+
+// 关闭 log 文件，并等待正在进行的压缩和清理结束
+// 异步输出时，会先输出缓冲区中的日志，关闭后再输出的日志将被丢弃
+func (this *S_DayfileLogger) Close() {
+	this.Flush()
+	this.Lock()
+	this.closed = true
 	if this.file != nil {
 		this.file.Close()
 		this.file = nil
 		this.logPath = ""
 	}
+	this.Unlock()
+	this.rotateWG.Wait()
 }
 
 // ---------------------------------------------------------
@@ -9,10 +9,104 @@
 
 package fslog
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type T_Level string
 
+// 所有日志级别
+var allLevels = []T_Level{
+	"DEBUG", "INFO", "NOTIC", "WARN", "ERROR", "HACK", "ILLEG", "CRIT", "TRACE", "PANIC", "FATAL",
+}
+
 func Lv(lv string) T_Level {
 	return T_Level(strings.ToUpper(lv))
 }
+
+// 返回 lv 及其以上的所有级别（按 DEBUG/INFO/NOTIC/WARN/ERROR/HACK/ILLEG/CRIT/TRACE/PANIC/FATAL 排序）
+// lv 不是合法级别时返回错误
+func LevelsFrom(lv string) ([]string, error) {
+	for i, level := range allLevels {
+		if level == Lv(lv) {
+			lvs := []string{}
+			for _, l := range allLevels[i:] {
+				lvs = append(lvs, string(l))
+			}
+			return lvs, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown log level %q", lv)
+}
+
+// 将级别名称（不区分大小写）转换为级别，有不合法的级别名称时返回错误
+func toLevels(lvs []string) ([]T_Level, error) {
+	levels := make([]T_Level, 0, len(lvs))
+	for _, lv := range lvs {
+		level := Lv(lv)
+		valid := false
+		for _, l := range allLevels {
+			if l == level {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown log level %q", lv)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// -----------------------------------------------------------------------------
+// fields
+// -----------------------------------------------------------------------------
+// 结构化日志字段
+type S_Field struct {
+	Key   string
+	Value any
+}
+
+type T_Fields []S_Field
+
+// 将 key/value 参数对转换为字段列表
+// kvs 可以是 key1, value1, key2, value2... 的形式，也可以直接是 S_Field 或 T_Fields
+// key 不是字符串时，会用 fmt.Sprint 转换为字符串；缺少 value 的 key，value 为 nil
+func Fields(kvs ...any) T_Fields {
+	fields := make(T_Fields, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i++ {
+		switch kv := kvs[i].(type) {
+		case S_Field:
+			fields = append(fields, kv)
+		case T_Fields:
+			fields = append(fields, kv...)
+		default:
+			key, ok := kv.(string)
+			if !ok {
+				key = fmt.Sprint(kv)
+			}
+			var value any
+			if i+1 < len(kvs) {
+				i++
+				value = kvs[i]
+			}
+			fields = append(fields, S_Field{key, value})
+		}
+	}
+	return fields
+}
+
+// 合并字段，返回新的字段列表，不会修改原列表
+func (this T_Fields) With(fields T_Fields) T_Fields {
+	if len(fields) == 0 {
+		return this
+	}
+	if len(this) == 0 {
+		return fields
+	}
+	newFields := make(T_Fields, 0, len(this)+len(fields))
+	newFields = append(newFields, this...)
+	return append(newFields, fields...)
+}
@@ -0,0 +1,231 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: logger with structured fields
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 带结构化字段的 logger，如：
+//   fslog.With("uid", 123).Info("login")
+//   fslog.With("uid", 123).Infow("login", "ip", "127.0.0.1")
+
+package fslog
+
+import (
+	"fmt"
+	"os"
+)
+
+type S_FieldLogger struct {
+	logger I_Logger // 为 nil 时，使用全局 logger
+	fields T_Fields
+}
+
+func newFieldLogger(logger I_Logger, fields T_Fields) *S_FieldLogger {
+	return &S_FieldLogger{
+		logger: logger,
+		fields: fields,
+	}
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+func (this *S_FieldLogger) target() I_Logger {
+	if this.logger != nil {
+		return this.logger
+	}
+	return logger
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 返回绑定的字段
+func (this *S_FieldLogger) Fields() T_Fields {
+	return this.fields
+}
+
+// 在现有字段的基础上追加字段，返回新的 logger
+func (this *S_FieldLogger) With(kvs ...any) *S_FieldLogger {
+	return newFieldLogger(this.logger, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Debug(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "DEBUG", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Debugf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "DEBUG", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Debugw(msg string, kvs ...any) {
+	this.target().Outputw(1, "DEBUG", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Info(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "INFO", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Infof(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "INFO", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Infow(msg string, kvs ...any) {
+	this.target().Outputw(1, "INFO", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Notic(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "NOTIC", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Noticf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "NOTIC", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Noticw(msg string, kvs ...any) {
+	this.target().Outputw(1, "NOTIC", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Warn(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "WARN", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Warnf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "WARN", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Warnw(msg string, kvs ...any) {
+	this.target().Outputw(1, "WARN", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Error(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "ERROR", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Errorf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "ERROR", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Errorw(msg string, kvs ...any) {
+	this.target().Outputw(1, "ERROR", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Hack(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "HACK", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Hackf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "HACK", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Hackw(msg string, kvs ...any) {
+	this.target().Outputw(1, "HACK", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Illeg(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "ILLEG", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Illegf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "ILLEG", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Illegw(msg string, kvs ...any) {
+	this.target().Outputw(1, "ILLEG", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Critical(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "CRIT", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Criticalf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "CRIT", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Criticalw(msg string, kvs ...any) {
+	this.target().Outputw(1, "CRIT", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Trace(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "TRACE", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Tracef(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "TRACE", msg, this.fields)
+}
+
+func (this *S_FieldLogger) Tracew(msg string, kvs ...any) {
+	this.target().Outputw(1, "TRACE", msg, this.fields.With(Fields(kvs...)))
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Panic(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "PANIC", msg, this.fields)
+	this.target().Flush()
+	panic(msg)
+}
+
+func (this *S_FieldLogger) Panicf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "PANIC", msg, this.fields)
+	this.target().Flush()
+	panic(msg)
+}
+
+func (this *S_FieldLogger) Panicw(msg string, kvs ...any) {
+	this.target().Outputw(1, "PANIC", msg, this.fields.With(Fields(kvs...)))
+	this.target().Flush()
+	panic(msg)
+}
+
+// ---------------------------------------------------------
+func (this *S_FieldLogger) Fatal(arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.target().Outputw(1, "FATAL", msg, this.fields)
+	this.target().Flush()
+	os.Exit(1)
+}
+
+func (this *S_FieldLogger) Fatalf(msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.target().Outputw(1, "FATAL", msg, this.fields)
+	this.target().Flush()
+	os.Exit(1)
+}
+
+func (this *S_FieldLogger) Fatalw(msg string, kvs ...any) {
+	this.target().Outputw(1, "FATAL", msg, this.fields.With(Fields(kvs...)))
+	this.target().Flush()
+	os.Exit(1)
+}
@@ -31,7 +31,7 @@ type S_FileLogger struct {
 // filePrefix 为 log 文件名前缀
 func NewFileLogger(file string) (*S_FileLogger, error) {
 	logger := &S_FileLogger{}
-	logger.S_Logger = NewLogger(logger.write)
+	logger.S_Logger = NewFieldsLogger(logger.write)
 	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
 	if err != nil {
 		return nil, fmt.Errorf("create log file fail, %v", err)
@@ -44,7 +44,7 @@ func NewFileLogger(file string) (*S_FileLogger, error) {
 // private
 // -------------------------------------------------------------------
 // 父类中的 send 函数中已经 lock，因此这里不需要再上锁了
-func (this *S_FileLogger) write(t time.Time, lv T_Level, msg []byte) {
+func (this *S_FileLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
 	if this.file == nil {
 		now := this.nowTime()
 		os.Stderr.WriteString(fmt.Sprintf("%s: %v", now.Format("[ERROR]|2006/01/02 15:04:05.999999 "), "file logger has been closed"))
@@ -61,7 +61,9 @@ func (this *S_FileLogger) write(t time.Time, lv T_Level, msg []byte) {
 // -------------------------------------------------------------------
 // public
 // -------------------------------------------------------------------
+// 异步输出时，会先输出缓冲区中的日志
 func (this *S_FileLogger) Close() {
+	this.Flush()
 	this.Lock()
 	defer this.Unlock()
 	if this.file != nil {
@@ -0,0 +1,230 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: log formatters
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 日志格式化器，将一条日志记录格式化为最终写出的字节
+// S_TextFormatter 为默认的文本格式：
+//   [G-id]|[LEVEL]|2006/01/02 15:04:05.999999 file:line: msg k=v
+// S_JsonFormatter 为 JSON-lines 格式，每条日志一行 json
+
+package fslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// record
+// -----------------------------------------------------------------------------
+// 调用栈中的一帧
+type S_StackFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+// 一条日志记录
+type S_Record struct {
+	Time   time.Time
+	Level  T_Level
+	GoID   uint64         // goroutine id，获取失败为 0
+	Site   string         // 调用位置 file:line，不显示调用位置时为空
+	Msg    string         // 日志消息
+	Fields T_Fields       // 结构化字段
+	Stack  []S_StackFrame // 调用栈，只有 TRACE/PANIC 有
+	Raw    bool           // 由 Direct 输出的日志，不带时间和调用位置
+}
+
+// -----------------------------------------------------------------------------
+// formatter interface
+// -----------------------------------------------------------------------------
+// 格式化器在 S_Logger 锁内调用，因此不需要自己处理并发
+type I_Formatter interface {
+	Format(*S_Record) []byte
+}
+
+// -----------------------------------------------------------------------------
+// text formatter
+// -----------------------------------------------------------------------------
+type S_TextFormatter struct {
+	levelFmt string
+	goidSize int
+}
+
+func NewTextFormatter() *S_TextFormatter {
+	lvLen := 1
+	for _, lv := range allLevels {
+		if lvLen < len(lv) {
+			lvLen = len(lv)
+		}
+	}
+	return &S_TextFormatter{
+		levelFmt: fmt.Sprintf("%%-%ds|", lvLen+2),
+	}
+}
+
+// ---------------------------------------------------------
+func (this *S_TextFormatter) writeGoID(buff *bytes.Buffer, goid uint64) {
+	if goid == 0 {
+		buff.WriteString("[G-ERR]|")
+		return
+	}
+	id := strconv.FormatUint(goid, 10)
+	if len(id) > this.goidSize {
+		this.goidSize = len(id)
+	}
+	buff.WriteString("[G-")
+	buff.WriteString(strings.Repeat("0", this.goidSize-len(id)))
+	buff.WriteString(id)
+	buff.WriteString("]|")
+}
+
+// 以 key=value 的形式写入字段，值中含有空白或特殊字符时加引号
+func (this *S_TextFormatter) writeFields(buff *bytes.Buffer, fields T_Fields) {
+	for _, field := range fields {
+		buff.WriteByte(' ')
+		buff.WriteString(field.Key)
+		buff.WriteByte('=')
+		value := fmt.Sprintf("%v", field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+		buff.WriteString(value)
+	}
+}
+
+func (this *S_TextFormatter) writeStack(buff *bytes.Buffer, stack []S_StackFrame) {
+	for _, frame := range stack {
+		fmt.Fprintf(buff, "\n\t%s(...):\n", frame.Func)
+		fmt.Fprintf(buff, "\t\t%s:%d", frame.File, frame.Line)
+	}
+}
+
+// 格式化日志头和消息，不包含调用栈和结尾换行
+func (this *S_TextFormatter) formatLine(buff *bytes.Buffer, rec *S_Record) {
+	this.writeGoID(buff, rec.GoID)
+	fmt.Fprintf(buff, this.levelFmt, "["+rec.Level+"]")
+	if rec.Raw {
+		buff.WriteString(rec.Msg)
+		return
+	}
+	buff.WriteString(rec.Time.Format("2006/01/02 15:04:05.999999"))
+	if rec.Site != "" {
+		buff.WriteByte(' ')
+		buff.WriteString(rec.Site)
+	}
+	buff.WriteString(": ")
+	buff.WriteString(rec.Msg)
+	this.writeFields(buff, rec.Fields)
+}
+
+// ---------------------------------------------------------
+func (this *S_TextFormatter) Format(rec *S_Record) []byte {
+	buff := new(bytes.Buffer)
+	this.formatLine(buff, rec)
+	this.writeStack(buff, rec.Stack)
+	buff.WriteByte('\n')
+	return buff.Bytes()
+}
+
+// -----------------------------------------------------------------------------
+// json formatter
+// -----------------------------------------------------------------------------
+type S_JsonFormatter struct {
+	TimeLayout string // 时间格式，默认为 RFC3339 精确到微秒
+}
+
+func NewJsonFormatter() *S_JsonFormatter {
+	return &S_JsonFormatter{
+		TimeLayout: "2006-01-02T15:04:05.000000Z07:00",
+	}
+}
+
+// ---------------------------------------------------------
+func (this *S_JsonFormatter) writeString(buff *bytes.Buffer, str string) {
+	bs, _ := json.Marshal(str)
+	buff.Write(bs)
+}
+
+// error 类型直接 json 化会变成 {}，因此转换为错误字符串
+// 无法 json 化的值以 %v 的形式输出为字符串
+func (this *S_JsonFormatter) writeValue(buff *bytes.Buffer, value any) {
+	if err, ok := value.(error); ok {
+		this.writeString(buff, err.Error())
+		return
+	}
+	bs, err := json.Marshal(value)
+	if err != nil {
+		this.writeString(buff, fmt.Sprintf("%v", value))
+		return
+	}
+	buff.Write(bs)
+}
+
+// 保持字段顺序输出
+func (this *S_JsonFormatter) writeFields(buff *bytes.Buffer, fields T_Fields) {
+	buff.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buff.WriteByte(',')
+		}
+		this.writeString(buff, field.Key)
+		buff.WriteByte(':')
+		this.writeValue(buff, field.Value)
+	}
+	buff.WriteByte('}')
+}
+
+func (this *S_JsonFormatter) writeStack(buff *bytes.Buffer, stack []S_StackFrame) {
+	buff.WriteByte('[')
+	for i, frame := range stack {
+		if i > 0 {
+			buff.WriteByte(',')
+		}
+		buff.WriteString(`{"func":`)
+		this.writeString(buff, frame.Func)
+		buff.WriteString(`,"file":`)
+		this.writeString(buff, frame.File)
+		buff.WriteString(`,"line":`)
+		buff.WriteString(strconv.Itoa(frame.Line))
+		buff.WriteByte('}')
+	}
+	buff.WriteByte(']')
+}
+
+// ---------------------------------------------------------
+func (this *S_JsonFormatter) Format(rec *S_Record) []byte {
+	buff := new(bytes.Buffer)
+	buff.WriteString(`{"time":`)
+	this.writeString(buff, rec.Time.Format(this.TimeLayout))
+	buff.WriteString(`,"level":`)
+	this.writeString(buff, string(rec.Level))
+	buff.WriteString(`,"goid":`)
+	buff.WriteString(strconv.FormatUint(rec.GoID, 10))
+	if rec.Site != "" {
+		buff.WriteString(`,"site":`)
+		this.writeString(buff, rec.Site)
+	}
+	buff.WriteString(`,"msg":`)
+	this.writeString(buff, rec.Msg)
+	if len(rec.Fields) > 0 {
+		buff.WriteString(`,"fields":`)
+		this.writeFields(buff, rec.Fields)
+	}
+	if len(rec.Stack) > 0 {
+		buff.WriteString(`,"stack":`)
+		this.writeStack(buff, rec.Stack)
+	}
+	buff.WriteString("}\n")
+	return buff.Bytes()
+}
@@ -9,6 +9,8 @@
 
 package fslog
 
+import "os"
+
 type I_Logger interface {
 	ToggleSite(bool)   // 显示/不显示源文件
 	CutSrcRoot(string) // 显示源文件根目录
@@ -53,6 +55,9 @@ type I_Logger interface {
 
 	Direct(T_Level, string)
 	Directf(T_Level, string, ...any)
+
+	Outputw(int, T_Level, string, T_Fields) // 输出带结构化字段的日志
+	Flush()                                 // 等待异步输出的日志全部输出完毕
 }
 
 // -----------------------------------------------------------------------------
@@ -185,6 +190,10 @@ func UnshieldAll() {
 	logger.UnshieldAll()
 }
 
+func Flush() {
+	logger.Flush()
+}
+
 // ---------------------------------------------------------
 func Direct(lv T_Level, msg string) {
 	logger.Direct(lv, msg)
@@ -193,3 +202,61 @@ func Direct(lv T_Level, msg string) {
 func Directf(lv T_Level, msg string, args ...any) {
 	logger.Directf(lv, msg, args...)
 }
+
+// ---------------------------------------------------------
+// 结构化日志
+// kvs 为 key1, value1, key2, value2... 形式的字段，参看 Fields 函数
+// ---------------------------------------------------------
+// 返回一个绑定了指定字段的 logger
+// 输出时使用当时通过 SetLogger 设置的全局 logger
+func With(kvs ...any) *S_FieldLogger {
+	return newFieldLogger(nil, Fields(kvs...))
+}
+
+func Debugw(msg string, kvs ...any) {
+	logger.Outputw(1, "DEBUG", msg, Fields(kvs...))
+}
+
+func Infow(msg string, kvs ...any) {
+	logger.Outputw(1, "INFO", msg, Fields(kvs...))
+}
+
+func Noticw(msg string, kvs ...any) {
+	logger.Outputw(1, "NOTIC", msg, Fields(kvs...))
+}
+
+func Warnw(msg string, kvs ...any) {
+	logger.Outputw(1, "WARN", msg, Fields(kvs...))
+}
+
+func Errorw(msg string, kvs ...any) {
+	logger.Outputw(1, "ERROR", msg, Fields(kvs...))
+}
+
+func Hackw(msg string, kvs ...any) {
+	logger.Outputw(1, "HACK", msg, Fields(kvs...))
+}
+
+func Illegw(msg string, kvs ...any) {
+	logger.Outputw(1, "ILLEG", msg, Fields(kvs...))
+}
+
+func Criticalw(msg string, kvs ...any) {
+	logger.Outputw(1, "CRIT", msg, Fields(kvs...))
+}
+
+func Tracew(msg string, kvs ...any) {
+	logger.Outputw(1, "TRACE", msg, Fields(kvs...))
+}
+
+func Panicw(msg string, kvs ...any) {
+	logger.Outputw(1, "PANIC", msg, Fields(kvs...))
+	logger.Flush()
+	panic(msg)
+}
+
+func Fatalw(msg string, kvs ...any) {
+	logger.Outputw(1, "FATAL", msg, Fields(kvs...))
+	logger.Flush()
+	os.Exit(1)
+}
@@ -1,7 +1,17 @@
 package fslog
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"fsky.pro/fstest"
 )
@@ -137,6 +147,440 @@ func TestTrace(t *testing.T) {
 	fstest.PrintTestEnd()
 }
 
+func TestWith(t *testing.T) {
+	fstest.PrintTestBegin("With")
+	defer fstest.PrintTestEnd()
+
+	ch := make(chan *S_ChanLog, 10)
+	cl := NewChanLogger(ch)
+	old := UsedLogger()
+	SetLogger(cl)
+	defer SetLogger(old)
+
+	With("uid", 123).Info("login")
+	log := <-ch
+	if log.Level != "INFO" || len(log.Fields) != 1 || log.Fields[0].Key != "uid" || log.Fields[0].Value != 123 {
+		t.Fatalf("unexpected log: %s %v", log.Level, log.Fields)
+	}
+	if !bytes.Contains(log.Msg, []byte("fslog_test.go:")) || !bytes.HasSuffix(log.Msg, []byte(": login uid=123\n")) {
+		t.Fatalf("unexpected log message: %q", log.Msg)
+	}
+
+	Warnw("slow query", "table", "user", "cost", "1.5 s")
+	log = <-ch
+	if !bytes.HasSuffix(log.Msg, []byte(`: slow query table=user cost="1.5 s"`+"\n")) {
+		t.Fatalf("unexpected log message: %q", log.Msg)
+	}
+
+	cl.With("a", 1).With("b", 2).Errorw("fail", "c", 3)
+	log = <-ch
+	if len(log.Fields) != 3 || log.Fields[2].Key != "c" {
+		t.Fatalf("unexpected log fields: %v", log.Fields)
+	}
+	os.Stdout.Write(log.Msg)
+}
+
+func TestJsonFormatter(t *testing.T) {
+	fstest.PrintTestBegin("JsonFormatter")
+	defer fstest.PrintTestEnd()
+
+	ch := make(chan *S_ChanLog, 10)
+	cl := NewChanLogger(ch)
+	cl.SetFormatter(NewJsonFormatter())
+
+	cl.With("uid", 123, "err", errors.New("not found")).Warn("login fail")
+	cl.Trace("trace", "aaaaaaa")
+	for i := 0; i < 2; i++ {
+		log := <-ch
+		os.Stdout.Write(log.Msg)
+		var obj map[string]any
+		if err := json.Unmarshal(log.Msg, &obj); err != nil {
+			t.Fatalf("unmarshal json log fail, %v", err)
+		}
+		if obj["level"] != log.Level || obj["goid"] == nil {
+			t.Fatalf("unexpected json log: %s", log.Msg)
+		}
+		switch log.Level {
+		case "WARN":
+			fields := obj["fields"].(map[string]any)
+			if obj["msg"] != "login fail" || fields["uid"] != float64(123) || fields["err"] != "not found" {
+				t.Fatalf("unexpected json log: %s", log.Msg)
+			}
+		case "TRACE":
+			if obj["msg"] != "trace aaaaaaa" || len(obj["stack"].([]any)) == 0 {
+				t.Fatalf("unexpected json log: %s", log.Msg)
+			}
+		}
+	}
+
+	// 重新打开已有的 log 文件时，JSON lines 文件中不写入纯文本分隔行
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		fl := NewDayfileLogger(dir, "json")
+		fl.SetFormatter(NewJsonFormatter())
+		fl.Infof("json message %d", i)
+		fl.Close()
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "json_"+time.Now().Format("2006-01-02")+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expect 2 json lines, got %q", data)
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Fatalf("invalid json line %q", line)
+		}
+	}
+}
+
+func TestDayfileRotate(t *testing.T) {
+	fstest.PrintTestBegin("DayfileRotate")
+	defer fstest.PrintTestEnd()
+
+	dir := t.TempDir()
+	fl := NewDayfileLogger(dir, "rotate")
+	newLogs := []string{}
+	fl.SetNewLogCallback(func(path string, err error) { newLogs = append(newLogs, path) })
+	bakLogs := []string{}
+	fl.SetRotateCallback(func(path string) { bakLogs = append(bakLogs, path) })
+	fl.SetMaxSize(256)
+	fl.SetCompress(true)
+	fl.SetRetention(2, 0)
+	for i := 0; i < 20; i++ {
+		fl.Infof("rotate test message %d", i)
+	}
+	fl.Close()
+	// 关闭后不再写入，也不重新打开文件
+	fl.Info("message after close")
+
+	entries, _ := os.ReadDir(dir)
+	rotated := 0
+	for _, entry := range entries {
+		t.Log(entry.Name())
+		if strings.HasSuffix(entry.Name(), ".log") {
+			if data, _ := os.ReadFile(filepath.Join(dir, entry.Name())); bytes.Contains(data, []byte("after close")) {
+				t.Fatalf("log after close is written to %q", entry.Name())
+			}
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".gz") {
+			t.Fatalf("rotated log file %q is not compressed", entry.Name())
+		}
+		rotated++
+	}
+	if rotated != 2 || len(entries) != 3 {
+		t.Fatalf("expect 2 rotated log files and 1 current log file, but got %d files", len(entries))
+	}
+	if len(newLogs) < 2 || len(bakLogs) != len(newLogs) {
+		t.Fatalf("expect callbacks called for every rotation, but got %d new logs and %d rotated logs", len(newLogs), len(bakLogs))
+	}
+	// 新建 log 文件回调总是报告新文件，切分回调报告切分出来的旧文件
+	logPath := filepath.Join(dir, "rotate_"+time.Now().Format("2006-01-02")+".log")
+	for _, path := range newLogs {
+		if path != logPath {
+			t.Fatalf("expect new log callback reports the new file, but got %q", path)
+		}
+	}
+	for i, path := range bakLogs {
+		if path != fmt.Sprintf("%s.%d", logPath, i+1) {
+			t.Fatalf("expect rotate callback reports the rotated file, but got %q", path)
+		}
+	}
+
+	// 压缩后保留原文件的修改时间
+	logPath = filepath.Join(dir, "old.log")
+	os.WriteFile(logPath, []byte("old log"), 0660)
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	os.Chtimes(logPath, mtime, mtime)
+	if err := gzipFile(logPath); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(logPath + ".gz")
+	if err != nil || !info.ModTime().Equal(mtime) {
+		t.Fatalf("expect compressed file keeps modify time %v, but got %v, %v", mtime, info, err)
+	}
+}
+
+func TestAsyncWriter(t *testing.T) {
+	fstest.PrintTestBegin("AsyncWriter")
+	defer fstest.PrintTestEnd()
+
+	// 阻塞策略不丢日志
+	ch := make(chan *S_ChanLog, 100)
+	cl := NewChanLogger(ch)
+	cl.SetAsync(2, OverflowBlock)
+	for i := 0; i < 20; i++ {
+		cl.Infof("async message %d", i)
+	}
+	cl.Flush()
+	if len(ch) != 20 || cl.Dropped() != 0 {
+		t.Fatalf("expect 20 log messages without dropped, but got %d, dropped %d", len(ch), cl.Dropped())
+	}
+	cl.SetAsync(0, OverflowBlock)
+
+	// 丢弃最新的日志，并报告丢弃数量
+	release := make(chan struct{})
+	written := 0
+	reported := uint64(0)
+	w := NewAsyncWriter(func(time.Time, T_Level, []byte, T_Fields) {
+		<-release
+		written++
+	}, 4, OverflowDropNewest)
+	w.OnDropped = func(n uint64) { reported += n }
+	for i := 0; i < 20; i++ {
+		w.Write(time.Now(), "INFO", []byte("msg\n"), nil)
+	}
+	close(release)
+	w.Close()
+	if w.Dropped() == 0 || reported != w.Dropped() || uint64(written)+w.Dropped() != 20 {
+		t.Fatalf("unexpected async result, written=%d, dropped=%d, reported=%d", written, w.Dropped(), reported)
+	}
+}
+
+func TestTeeLogger(t *testing.T) {
+	fstest.PrintTestBegin("TeeLogger")
+	defer fstest.PrintTestEnd()
+
+	chAll := make(chan *S_ChanLog, 10)
+	chWarn := make(chan *S_ChanLog, 10)
+	tee := NewTeeLogger()
+	warns, err := LevelsFrom("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LevelsFrom("warning"); err == nil {
+		t.Fatal("LevelsFrom should refuse unknown level")
+	}
+	if err := tee.AddSink(NewChanLogger(chAll), "warn", "warning"); err == nil {
+		t.Fatal("AddSink should refuse unknown level")
+	}
+	tee.AddSink(NewChanLogger(chAll))
+	tee.AddSink(NewChanLogger(chWarn), warns...)
+	old := UsedLogger()
+	SetLogger(tee)
+	defer SetLogger(old)
+
+	Debug("debug message")
+	Info("info message")
+	Warnf("warn %s", "message")
+	With("uid", 1).Error("error message")
+	if len(chAll) != 4 || len(chWarn) != 2 {
+		t.Fatalf("expect 4 logs in all sink and 2 logs in warn sink, but got %d and %d", len(chAll), len(chWarn))
+	}
+	for len(chWarn) > 0 {
+		log := <-chWarn
+		os.Stdout.Write(log.Msg)
+		if !bytes.Contains(log.Msg, []byte("fslog_test.go:")) {
+			t.Fatalf("unexpected log site: %q", log.Msg)
+		}
+	}
+
+	tee.Shield("info")
+	Info("info message")
+	if len(chAll) != 4 {
+		t.Fatalf("shielded log should not be sent to sinks")
+	}
+}
+
+func TestLevelConfig(t *testing.T) {
+	fstest.PrintTestBegin("LevelConfig")
+	defer fstest.PrintTestEnd()
+
+	ch := make(chan *S_ChanLog, 10)
+	old := UsedLogger()
+	SetLogger(NewChanLogger(ch))
+	defer SetLogger(old)
+
+	file := t.TempDir() + "/levels.json"
+	os.WriteFile(file, []byte(`{
+		"level": "error",   // 默认只输出 ERROR 及其以上级别
+	}`), 0666)
+	if err := LoadLevels(file); err != nil {
+		t.Fatal(err)
+	}
+	Info("info message")
+	Error("error message")
+	if len(ch) != 1 {
+		t.Fatalf("expect only error log, but got %d logs", len(ch))
+	}
+	<-ch
+
+	// 测试函数所在的包为 fslog
+	os.WriteFile(file, []byte(`{
+		"level": "error",
+		"packages": { "fsky.pro/fsrpc": "debug", "fslog": "info" },
+	}`), 0666)
+	if err := LoadLevels(file); err != nil {
+		t.Fatal(err)
+	}
+	Debug("debug message")
+	Info("info message")
+	if len(ch) != 1 {
+		t.Fatalf("expect only info log, but got %d logs", len(ch))
+	}
+	<-ch
+
+	// 包的级别优先于 Shield
+	Shield("info")
+	Info("info message")
+	Unshield("info")
+	if len(ch) != 1 {
+		t.Fatalf("expect package level to override Shield, but got %d logs", len(ch))
+	}
+	<-ch
+
+	os.WriteFile(file, []byte(`{ "level": "xxx" }`), 0666)
+	if err := LoadLevels(file); err == nil {
+		t.Fatalf("expect unknown level error")
+	}
+
+	if pkg := funcPackage("fsky.pro/fsrpc/server.(*S_Server).Serve"); pkg != "fsky.pro/fsrpc/server" {
+		t.Fatalf("unexpected package %q", pkg)
+	}
+	if pkg := funcPackage("gopkg.in/yaml%2ev2.Marshal"); pkg != "gopkg.in/yaml.v2" {
+		t.Fatalf("unexpected package %q", pkg)
+	}
+}
+
+func TestSyslogLogger(t *testing.T) {
+	fstest.PrintTestBegin("SyslogLogger")
+	defer fstest.PrintTestEnd()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sl, err := NewSyslogLogger("udp", conn.LocalAddr().String(), "fslogtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sl.Close()
+	if err := sl.SetFormatter(NewJsonFormatter()); err == nil {
+		t.Fatal("syslog logger should refuse json formatter")
+	}
+	if err := sl.SetFormatter(nil); err != nil {
+		t.Fatal(err)
+	}
+	sl.With("host", "db-1", "err", `bad "]`).Error("db down")
+
+	buff := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buff[:n])
+	t.Log(msg)
+	// facility user(1) * 8 + severity error(3)
+	if !strings.HasPrefix(msg, "<11>1 ") || !strings.Contains(msg, " fslogtest ") {
+		t.Fatalf("unexpected syslog header: %q", msg)
+	}
+	if !strings.Contains(msg, `[fields@32473 host="db-1" err="bad \"\]"]`) || !strings.HasSuffix(msg, ": db down") {
+		t.Fatalf("unexpected syslog message: %q", msg)
+	}
+
+	// 连接不上时也返回 logger，先输出到 stderr，服务器启动后重新连接
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	tl, err := NewSyslogLogger("tcp", addr, "fslogtest")
+	if err != nil || tl == nil {
+		t.Fatalf("expect syslog logger falls back to stderr, but got %v", err)
+	}
+	defer tl.Close()
+	tl.Info("to stderr")
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("listen on %s again fail: %v", addr, err)
+	}
+	defer ln.Close()
+	tl.RetryInterval = 0
+	tl.Info("reconnected")
+	sconn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sconn.Close()
+	sconn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err = sconn.Read(buff)
+	if err != nil || !strings.HasSuffix(string(buff[:n]), ": reconnected") {
+		t.Fatalf("unexpected syslog message after reconnect: %q, %v", buff[:n], err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	fstest.PrintTestBegin("Context")
+	defer fstest.PrintTestEnd()
+
+	ch := make(chan *S_ChanLog, 10)
+	old := UsedLogger()
+	SetLogger(NewChanLogger(ch))
+	defer SetLogger(old)
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	ctx = ContextWithFields(ctx, "uid", 123)
+	InfoCtx(ctx, "login")
+	FromContext(ctx).Warnf("slow %s", "query")
+	InfoCtx(context.Background(), "no fields")
+	for _, expect := range []string{": login reqid=req-1 uid=123\n", ": slow query reqid=req-1 uid=123\n", ": no fields\n"} {
+		log := <-ch
+		os.Stdout.Write(log.Msg)
+		if !bytes.HasSuffix(log.Msg, []byte(expect)) || !bytes.Contains(log.Msg, []byte("fslog_test.go:")) {
+			t.Fatalf("unexpected log message: %q", log.Msg)
+		}
+	}
+	if RequestID(ctx) != "req-1" || len(NewRequestID()) != 16 {
+		t.Fatalf("unexpected request id")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	fstest.PrintTestBegin("RateLimit")
+	defer fstest.PrintTestEnd()
+
+	ch := make(chan *S_ChanLog, 100)
+	cl := NewChanLogger(ch)
+	if err := cl.SetRateLimit(S_RateLimit{Interval: 100 * time.Millisecond, Burst: 3, Every: 5}, "error", "eror"); err == nil {
+		t.Fatal("SetRateLimit should refuse unknown level")
+	}
+	cl.SetRateLimit(S_RateLimit{Interval: 100 * time.Millisecond, Burst: 3, Every: 5}, "error")
+	for i := 0; i < 23; i++ {
+		cl.Errorf("db down %d", i)
+		cl.Infof("not limited %d", i)
+	}
+	// 前 3 条全部输出，之后的 20 条每 5 条输出一条
+	if len(ch) != 23+3+4 {
+		t.Fatalf("expect 30 log messages, but got %d", len(ch))
+	}
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	select {
+	case log := <-ch:
+		os.Stdout.Write(log.Msg)
+		if log.Level != "ERROR" || !bytes.Contains(log.Msg, []byte("fslog_test.go:")) ||
+			!bytes.Contains(log.Msg, []byte(": suppressed 16 similar messages in 100ms")) {
+			t.Fatalf("unexpected summary message: %q", log.Msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("summary of suppressed messages is not reported")
+	}
+
+	// 新的窗口重新计数
+	cl.Error("db down again")
+	if len(ch) != 1 {
+		t.Fatalf("expect log message in new window")
+	}
+}
+
 func TestFatal(t *testing.T) {
 	Fatal("xxxxxxx")
 }
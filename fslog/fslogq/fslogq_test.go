@@ -0,0 +1,117 @@
+package fslogq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"fsky.pro/fstest"
+	"fslog"
+)
+
+func writeFile(t *testing.T, path string, text string) {
+	data := []byte(text)
+	if strings.HasSuffix(path, ".gz") {
+		buff := new(bytes.Buffer)
+		zw := gzip.NewWriter(buff)
+		zw.Write(data)
+		zw.Close()
+		data = buff.Bytes()
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	fstest.PrintTestBegin("Reader")
+	defer fstest.PrintTestEnd()
+
+	buff := new(bytes.Buffer)
+	logger := fslog.NewLogger(func(_ time.Time, _ fslog.T_Level, msg []byte) { buff.Write(msg) })
+	logger.With("uid", 1).Trace("trace message")
+	logger.Error_(0, "line1\nline2")
+	logger.Direct("INFO", "raw message")
+
+	reader := NewReader(buff, "test", nil)
+	entries := []*S_Entry{}
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expect 3 log entries, but got %d", len(entries))
+	}
+	trace := entries[0]
+	if trace.Level != "TRACE" || trace.GoID == 0 || trace.Msg != "trace message uid=1" ||
+		!strings.Contains(trace.Site, "fslogq_test.go:") || len(trace.Stack) == 0 ||
+		!strings.HasSuffix(trace.Stack[0].Func, "TestReader") {
+		t.Fatalf("unexpected trace entry: %+v", trace)
+	}
+	if entries[1].Level != "ERROR" || entries[1].Msg != "line1\nline2" || entries[1].Line != len(trace.Stack)*2+2 {
+		t.Fatalf("unexpected multi-line entry: %+v", entries[1])
+	}
+	if !entries[2].Raw || entries[2].Msg != "raw message" || !entries[2].Time.Equal(entries[1].Time) {
+		t.Fatalf("unexpected raw entry: %+v", entries[2])
+	}
+}
+
+func TestQuery(t *testing.T) {
+	fstest.PrintTestBegin("Query")
+	defer fstest.PrintTestEnd()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "svr_2024-01-10.log"),
+		"[G-1]|[INFO]   |2024/01/10 23:59:59.5 a.go:1: yesterday\n")
+	writeFile(t, filepath.Join(dir, "svr_2024-01-11.log.1.gz"),
+		"[G-1]|[INFO]   |2024/01/11 09:59:00 a.go:1: before\n"+
+			"[G-1234]|[ERROR]  |2024/01/11 10:01:00 a.go:2: db down\n"+
+			"[G-7]|[ERROR]  |2024/01/11 10:01:01 a.go:3: db down\n")
+	writeFile(t, filepath.Join(dir, "svr_2024-01-11.log"),
+		"[G-1234]|[WARN]   |2024/01/11 10:02:00.123 a.go:4: slow query\n"+
+			"\n"+strings.Repeat("-", 50)+" 10:03:00 "+strings.Repeat("-", 50)+"\n"+
+			"[G-1234]|[ERROR]  |2024/01/11 10:04:00: db timeout\n"+
+			"[G-1234]|[ERROR]  |2024/01/11 10:06:00 a.go:5: db down\n")
+	writeFile(t, filepath.Join(dir, "other_2024-01-11.log"), "")
+
+	files, err := DayFiles(dir, "svr")
+	if err != nil || len(files) != 3 || files[1].Index != 1 || files[2].Index != 0 {
+		t.Fatalf("unexpected log files: %v, %v", files, err)
+	}
+
+	since, _ := time.ParseInLocation("2006-01-02 15:04", "2024-01-11 10:00", time.Local)
+	warns, _ := fslog.LevelsFrom("warn")
+	filter := &S_Filter{
+		Since:   since,
+		Until:   since.Add(5 * time.Minute),
+		Levels:  warns,
+		GoIDs:   []uint64{1234},
+		Pattern: regexp.MustCompile(`db|slow`),
+	}
+	msgs := []string{}
+	err = Query(files, filter, nil, func(entry *S_Entry) bool {
+		msgs = append(msgs, entry.Msg)
+		return true
+	})
+	if err != nil || strings.Join(msgs, "|") != "db down|slow query|db timeout" {
+		t.Fatalf("unexpected query result: %q, %v", msgs, err)
+	}
+
+	// 提前结束查询
+	count := 0
+	Query(files, nil, nil, func(*S_Entry) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Fatalf("query is not stopped, %d", count)
+	}
+}
@@ -0,0 +1,237 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: query logs across log files
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 在 S_DayfileLogger 输出的多个日志文件中按条件查询日志，如：
+//   files, _ := fslogq.DayFiles("./logs", "server")
+//   filter := &fslogq.S_Filter{Since: since, Until: until, Levels: []string{"ERROR"}, GoIDs: []uint64{1234}}
+//   fslogq.Query(files, filter, nil, func(entry *fslogq.S_Entry) bool {
+//       fmt.Println(entry.Text)
+//       return true
+//   })
+
+package fslogq
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// log files
+// -----------------------------------------------------------------------------
+// 日志文件信息
+type S_LogFile struct {
+	Path  string
+	Date  time.Time // 文件名中的日期
+	Index int       // 切分序号，当天正在写的文件为 0
+}
+
+// 按时间顺序排序，同一天的切分文件序号越小越旧，正在写的文件最新
+func sortLogFiles(files []S_LogFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].Date.Equal(files[j].Date) {
+			return files[i].Date.Before(files[j].Date)
+		}
+		if (files[i].Index == 0) != (files[j].Index == 0) {
+			return files[j].Index == 0
+		}
+		return files[i].Index < files[j].Index
+	})
+}
+
+// 列出目录下 prefix_YYYY-MM-DD.log[.N][.gz] 格式的日志文件，按时间顺序排列
+func DayFiles(dir, prefix string) ([]S_LogFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read log directory %q fail, %v", dir, err)
+	}
+	ptn := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + `_(\d{4}-\d{2}-\d{2})\.log(?:\.(\d+))?(?:\.gz)?$`)
+	files := []S_LogFile{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := ptn.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[2])
+		files = append(files, S_LogFile{filepath.Join(dir, entry.Name()), date, index})
+	}
+	sortLogFiles(files)
+	return files, nil
+}
+
+// 将指定的文件路径转换为 S_LogFile 列表
+// 文件名符合 prefix_YYYY-MM-DD.log[.N][.gz] 格式的按时间排序，其余的保持原有顺序排在前面
+func PathFiles(paths ...string) []S_LogFile {
+	ptn := regexp.MustCompile(`_(\d{4}-\d{2}-\d{2})\.log(?:\.(\d+))?(?:\.gz)?$`)
+	files := []S_LogFile{}
+	for _, path := range paths {
+		file := S_LogFile{Path: path}
+		if match := ptn.FindStringSubmatch(filepath.Base(path)); match != nil {
+			file.Date, _ = time.ParseInLocation("2006-01-02", match[1], time.Local)
+			file.Index, _ = strconv.Atoi(match[2])
+		}
+		files = append(files, file)
+	}
+	sortLogFiles(files)
+	return files
+}
+
+// -----------------------------------------------------------------------------
+// filter
+// -----------------------------------------------------------------------------
+// 查询条件，零值表示不限
+type S_Filter struct {
+	Since   time.Time      // 起始时间（包含）
+	Until   time.Time      // 结束时间（不包含）
+	Levels  []string       // 日志级别
+	GoIDs   []uint64       // goroutine id
+	Pattern *regexp.Regexp // 匹配日志原文
+}
+
+func (this *S_Filter) matchLevel(level string) bool {
+	if len(this.Levels) == 0 {
+		return true
+	}
+	for _, lv := range this.Levels {
+		if strings.EqualFold(lv, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *S_Filter) matchGoID(goid uint64) bool {
+	if len(this.GoIDs) == 0 {
+		return true
+	}
+	for _, id := range this.GoIDs {
+		if id == goid {
+			return true
+		}
+	}
+	return false
+}
+
+// 文件日期不在查询时间范围内时，跳过整个文件
+// 时间不确定的文件（文件名中没有日期）总是要读
+func (this *S_Filter) matchFile(file S_LogFile, location *time.Location) bool {
+	if file.Date.IsZero() {
+		return true
+	}
+	start := time.Date(file.Date.Year(), file.Date.Month(), file.Date.Day(), 0, 0, 0, 0, location)
+	end := start.AddDate(0, 0, 1)
+	if !this.Since.IsZero() && !end.After(this.Since) {
+		return false
+	}
+	if !this.Until.IsZero() && !start.Before(this.Until) {
+		return false
+	}
+	return true
+}
+
+// ---------------------------------------------------------
+// 日志是否符合查询条件
+func (this *S_Filter) Match(entry *S_Entry) bool {
+	if !this.Since.IsZero() && entry.Time.Before(this.Since) {
+		return false
+	}
+	if !this.Until.IsZero() && !entry.Time.Before(this.Until) {
+		return false
+	}
+	if !this.matchLevel(entry.Level) || !this.matchGoID(entry.GoID) {
+		return false
+	}
+	if this.Pattern != nil && !this.Pattern.MatchString(entry.Text) {
+		return false
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// query
+// -----------------------------------------------------------------------------
+// 打开日志文件，.gz 结尾的文件自动解压
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &s_GzipFile{zr, file}, nil
+}
+
+type s_GzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (this *s_GzipFile) Close() error {
+	this.Reader.Close()
+	return this.file.Close()
+}
+
+// ---------------------------------------------------------
+// 依次读取所有文件，将符合条件的日志交给 fn，fn 返回 false 时停止查询
+// filter 为 nil 时不过滤；location 为日志时间所在时区，nil 为本地时区
+func Query(files []S_LogFile, filter *S_Filter, location *time.Location, fn func(*S_Entry) bool) error {
+	if filter == nil {
+		filter = &S_Filter{}
+	}
+	if location == nil {
+		location = time.Local
+	}
+	for _, file := range files {
+		if !filter.matchFile(file, location) {
+			continue
+		}
+		r, err := openLogFile(file.Path)
+		if err != nil {
+			return fmt.Errorf("open log file %q fail, %v", file.Path, err)
+		}
+		reader := NewReader(r, file.Path, location)
+		for {
+			entry, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				r.Close()
+				return fmt.Errorf("read log file %q fail, %v", file.Path, err)
+			}
+			if filter.Match(entry) && !fn(entry) {
+				r.Close()
+				return nil
+			}
+		}
+		r.Close()
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: fslog text log reader
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 解释 fslog 文本格式输出的日志：
+//   [G-0123]|[ERROR]  |2006/01/02 15:04:05.999999 file:line: msg k=v
+//   	main.foo(...):
+//   		src/main:12
+// 不以 "[G-" 开头的行属于上一条日志（多行消息或 TRACE/PANIC 的调用栈）
+// S_DayfileLogger 追加写入已存在的文件时插入的分隔行会被跳过：
+//   -------------------------------------------------- 15:04:05 --------------------------------------------------
+
+package fslogq
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日志中的时间格式
+const TimeLayout = "2006/01/02 15:04:05.999999"
+
+// 行的最大长度
+const maxLineSize = 16 * 1024 * 1024
+
+var (
+	headPtn     = regexp.MustCompile(`^\[G-(\d+|ERR)\]\|\[([A-Z]+)\] *\|`)
+	timePtn     = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d{1,6})?`)
+	splitterPtn = regexp.MustCompile(`^-{50} \d{2}:\d{2}:\d{2} -{50}$`)
+	funcPtn     = regexp.MustCompile(`^\t(\S.*)\(\.\.\.\):$`)
+	filePtn     = regexp.MustCompile(`^\t\t(.+):(\d+)$`)
+)
+
+// 调用栈中的一帧
+type S_StackFrame struct {
+	Func string
+	File string
+	Line int
+}
+
+// 一条日志
+type S_Entry struct {
+	File  string         // 日志所在文件
+	Line  int            // 日志在文件中的起始行号
+	GoID  uint64         // goroutine id，日志中为 G-ERR 时为 0
+	Level string         // 日志级别
+	Time  time.Time      // 日志时间，Raw 日志为上一条日志的时间
+	Raw   bool           // 由 Direct 输出的不带时间和调用位置的日志
+	Site  string         // 调用位置，没有则为空
+	Msg   string         // 日志消息（包括字段和多行消息的后续行，不包括调用栈）
+	Stack []S_StackFrame // TRACE/PANIC 的调用栈
+	Text  string         // 日志原文
+}
+
+// -----------------------------------------------------------------------------
+// S_Reader
+// -----------------------------------------------------------------------------
+type S_Reader struct {
+	name     string
+	scanner  *bufio.Scanner
+	location *time.Location
+	lineNo   int
+	lastTime time.Time
+	pending  *S_Entry // 已读取日志头，但还没读完的日志
+	lines    []string // pending 的所有行
+	err      error
+}
+
+// name 为日志来源名称（一般为文件路径），会填入 S_Entry.File
+// location 为日志时间所在时区，为 nil 则为本地时区
+func NewReader(r io.Reader, name string, location *time.Location) *S_Reader {
+	if location == nil {
+		location = time.Local
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &S_Reader{
+		name:     name,
+		scanner:  scanner,
+		location: location,
+	}
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+// 解释日志头，不是日志头返回 nil
+func (this *S_Reader) parseHead(line string) *S_Entry {
+	match := headPtn.FindStringSubmatchIndex(line)
+	if match == nil {
+		return nil
+	}
+	entry := &S_Entry{
+		File:  this.name,
+		Line:  this.lineNo,
+		Level: line[match[4]:match[5]],
+	}
+	if goid := line[match[2]:match[3]]; goid != "ERR" {
+		entry.GoID, _ = strconv.ParseUint(goid, 10, 64)
+	}
+
+	rest := line[match[1]:]
+	loc := timePtn.FindStringIndex(rest)
+	if loc == nil {
+		entry.Raw = true
+		entry.Time = this.lastTime
+		entry.Msg = rest
+		return entry
+	}
+	t, err := time.ParseInLocation(TimeLayout, rest[:loc[1]], this.location)
+	if err != nil {
+		entry.Raw = true
+		entry.Time = this.lastTime
+		entry.Msg = rest
+		return entry
+	}
+	entry.Time = t
+	this.lastTime = t
+
+	// 时间之后为 " site: msg" 或 ": msg"
+	rest = rest[loc[1]:]
+	if strings.HasPrefix(rest, ": ") {
+		entry.Msg = rest[2:]
+	} else if index := strings.Index(rest, ": "); strings.HasPrefix(rest, " ") && index > 0 {
+		entry.Site = rest[1:index]
+		entry.Msg = rest[index+2:]
+	} else {
+		entry.Msg = strings.TrimPrefix(rest, " ")
+	}
+	return entry
+}
+
+// 将后续行合并到日志中，并返回该日志
+func (this *S_Reader) finish() *S_Entry {
+	entry := this.pending
+	lines := this.lines
+	this.pending = nil
+	this.lines = nil
+
+	// 去掉结尾的空行（分隔行前会插入一个空行）
+	for len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	entry.Text = strings.Join(lines, "\n")
+
+	// 调用栈在最后，每帧两行
+	end := len(lines)
+	stack := []S_StackFrame{}
+	for end >= 3 {
+		fm := funcPtn.FindStringSubmatch(lines[end-2])
+		lm := filePtn.FindStringSubmatch(lines[end-1])
+		if fm == nil || lm == nil {
+			break
+		}
+		line, _ := strconv.Atoi(lm[2])
+		stack = append(stack, S_StackFrame{fm[1], lm[1], line})
+		end -= 2
+	}
+	if len(stack) > 0 {
+		for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+		entry.Stack = stack
+	}
+	if end > 1 {
+		entry.Msg += "\n" + strings.Join(lines[1:end], "\n")
+	}
+	return entry
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 读取下一条日志，读完返回 nil, io.EOF
+func (this *S_Reader) Next() (*S_Entry, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+	for this.scanner.Scan() {
+		this.lineNo++
+		line := strings.TrimSuffix(this.scanner.Text(), "\r")
+		if splitterPtn.MatchString(line) {
+			continue
+		}
+		entry := this.parseHead(line)
+		if entry == nil {
+			// 文件开头不属于任何日志的行
+			if this.pending != nil {
+				this.lines = append(this.lines, line)
+			}
+			continue
+		}
+		var prev *S_Entry
+		if this.pending != nil {
+			prev = this.finish()
+		}
+		this.pending = entry
+		this.lines = []string{line}
+		if prev != nil {
+			return prev, nil
+		}
+	}
+
+	this.err = this.scanner.Err()
+	if this.err == nil {
+		this.err = io.EOF
+	}
+	if this.pending != nil {
+		return this.finish(), nil
+	}
+	return nil, this.err
+}
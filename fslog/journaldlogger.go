@@ -0,0 +1,206 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: logger for systemd journald
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 以 journald 原生协议将日志发送到 /run/systemd/journal/socket
+// 结构化字段转换为大写的 journal 字段，如 uid -> UID
+// 发送失败时会尝试重新连接，连接不上则输出到 stderr
+// 新建时连接不上 journald 也会返回 logger，先输出到 stderr，之后按 RetryInterval 重连
+
+package fslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const JournaldSocket = "/run/systemd/journal/socket"
+
+type S_JournaldLogger struct {
+	*S_Logger
+	s_NetWriter
+	tag string
+}
+
+// tag 为 SYSLOG_IDENTIFIER，为空则使用程序名
+// 连接不上 journald 时不返回错误，保留 error 返回值以兼容已有调用
+func NewJournaldLogger(tag string) (*S_JournaldLogger, error) {
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	logger := &S_JournaldLogger{tag: tag}
+	logger.S_Logger = NewFieldsLogger(logger.write)
+	logger.S_Logger.formatter = &s_SyslogFormatter{NewTextFormatter()}
+	desc := fmt.Sprintf("journald socket %q", JournaldSocket)
+	logger.s_NetWriter = newNetWriter(desc, func(timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout("unixgram", JournaldSocket, timeout)
+	}, logger.nowTime)
+	if err := logger.dial(); err != nil {
+		logger.writeStderr(time.Now(), "", nil, err)
+	}
+	return logger, nil
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+// journal 字段名只能包含大写字母、数字和下划线，且不能以下划线开头
+func (this *S_JournaldLogger) fieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	str := strings.TrimLeft(string(name), "_")
+	if str == "" {
+		return "FIELD"
+	}
+	return str
+}
+
+// 值中含有换行时，需要用 “名称\n + 8 字节小端长度 + 值\n” 的形式
+func (this *S_JournaldLogger) writeField(buff *bytes.Buffer, name string, value []byte) {
+	buff.WriteString(name)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buff.WriteByte('=')
+		buff.Write(value)
+	} else {
+		buff.WriteByte('\n')
+		binary.Write(buff, binary.LittleEndian, uint64(len(value)))
+		buff.Write(value)
+	}
+	buff.WriteByte('\n')
+}
+
+func (this *S_JournaldLogger) format(t time.Time, lv T_Level, msg []byte, fields T_Fields) []byte {
+	buff := new(bytes.Buffer)
+	this.writeField(buff, "PRIORITY", []byte(fmt.Sprint(syslogSeverity(lv))))
+	this.writeField(buff, "SYSLOG_IDENTIFIER", []byte(this.tag))
+	this.writeField(buff, "FSLOG_LEVEL", []byte(lv))
+	this.writeField(buff, "MESSAGE", msg)
+	for _, field := range fields {
+		this.writeField(buff, this.fieldName(field.Key), []byte(fmt.Sprintf("%v", field.Value)))
+	}
+	return buff.Bytes()
+}
+
+// 父类中的 send 函数中已经 lock，因此这里不需要再上锁了
+func (this *S_JournaldLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
+	this.output(t, lv, msg, this.format(t, lv, msg, fields))
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 格式化器只能为 nil（使用默认的 syslog 格式化器），否则返回错误
+func (this *S_JournaldLogger) SetFormatter(formatter I_Formatter) error {
+	return setSyslogFormatter(this.S_Logger, formatter)
+}
+
+func (this *S_JournaldLogger) Close() {
+	this.Flush()
+	this.Lock()
+	defer this.Unlock()
+	this.closeConn()
+}
+
+// ---------------------------------------------------------
+func (this *S_JournaldLogger) Debug(arg any, args ...any) {
+	this.S_Logger.Debug_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Debugf(msg string, args ...any) {
+	this.S_Logger.Debugf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Info(arg any, args ...any) {
+	this.S_Logger.Info_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Infof(msg string, args ...any) {
+	this.S_Logger.Infof_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Notic(arg any, args ...any) {
+	this.S_Logger.Notic_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Noticf(msg string, args ...any) {
+	this.S_Logger.Noticf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Warn(arg any, args ...any) {
+	this.S_Logger.Warn_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Warnf(msg string, args ...any) {
+	this.S_Logger.Warnf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Error(arg any, args ...any) {
+	this.S_Logger.Error_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Errorf(msg string, args ...any) {
+	this.S_Logger.Errorf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Hack(arg any, args ...any) {
+	this.S_Logger.Hack_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Hackf(msg string, args ...any) {
+	this.S_Logger.Hackf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Illeg(arg any, args ...any) {
+	this.S_Logger.Illeg_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Illegf(msg string, args ...any) {
+	this.S_Logger.Illegf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Critical(arg any, args ...any) {
+	this.S_Logger.Critical_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Criticalf(msg string, args ...any) {
+	this.S_Logger.Criticalf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Trace(arg any, args ...any) {
+	this.S_Logger.Trace_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Tracef(msg string, args ...any) {
+	this.S_Logger.Tracef_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Panic(arg any, args ...any) {
+	this.S_Logger.Panic_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Panicf(msg string, args ...any) {
+	this.S_Logger.Panicf_(1, msg, args...)
+}
+
+func (this *S_JournaldLogger) Fatal(arg any, args ...any) {
+	this.S_Logger.Fatal_(1, arg, args...)
+}
+
+func (this *S_JournaldLogger) Fatalf(msg string, args ...any) {
+	this.S_Logger.Fatalf_(1, msg, args...)
+}
@@ -0,0 +1,203 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: log levels configuration
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 从 jsonex 配置文件加载日志级别，可以按包单独配置，配置文件如：
+// {
+//     "level": "info",                  // 默认输出 INFO 及其以上级别
+//     "packages": {
+//         "fsky.pro/fsrpc": "debug",    // fsky.pro/fsrpc 及其子包输出 DEBUG 及其以上级别
+//         "myapp/db": "error",
+//     },
+// }
+// 通过 WatchLevelConfig 加载后，进程收到 SIGHUP 信号时会重新加载
+// 配置了级别的包以包的级别为准，之后调用 Shield/Unshield 只修改默认级别，对这些包不起作用
+
+package fslog
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"fsky.pro/fsserializer/jsonex"
+)
+
+// -----------------------------------------------------------------------------
+// package levels
+// -----------------------------------------------------------------------------
+type s_PkgLevels struct {
+	pkg    string
+	levels map[T_Level]bool
+}
+
+// pkg 为 this.pkg 本身或其子包
+func (this *s_PkgLevels) match(pkg string) bool {
+	return pkg == this.pkg || strings.HasPrefix(pkg, this.pkg+"/")
+}
+
+// 从函数全名中取出包路径，如：
+// fsky.pro/fsrpc/server.(*S_Server).Serve 的包路径为 fsky.pro/fsrpc/server
+// 包路径最后一节中的 “.” 在函数名中会被转义为 “%2e”
+func funcPackage(fname string) string {
+	slash := strings.LastIndex(fname, "/")
+	if dot := strings.Index(fname[slash+1:], "."); dot >= 0 {
+		fname = fname[:slash+1+dot]
+	}
+	return strings.ReplaceAll(fname, "%2e", ".")
+}
+
+// -----------------------------------------------------------------------------
+// level config
+// -----------------------------------------------------------------------------
+type S_LevelConfig struct {
+	Level    string            `json:"level"`    // 默认输出级别，输出该级别及其以上的日志；为空则不修改
+	Packages map[string]string `json:"packages"` // 包路径 -> 输出级别，对子包同样有效，路径长者优先
+}
+
+func LoadLevelConfig(file string) (*S_LevelConfig, error) {
+	cfg := new(S_LevelConfig)
+	if err := jsonex.Load(file, cfg); err != nil {
+		return nil, fmt.Errorf("load log level config file %q fail, %v", file, err)
+	}
+	if err := cfg.check(); err != nil {
+		return nil, fmt.Errorf("log level config file %q is invalid, %v", file, err)
+	}
+	return cfg, nil
+}
+
+func (this *S_LevelConfig) check() error {
+	if this.Level != "" {
+		if _, err := LevelsFrom(this.Level); err != nil {
+			return err
+		}
+	}
+	for pkg, lv := range this.Packages {
+		if _, err := LevelsFrom(lv); err != nil {
+			return fmt.Errorf("%v of package %q", err, pkg)
+		}
+	}
+	return nil
+}
+
+// 转换为按包路径长度倒序排列的级别列表
+func (this *S_LevelConfig) pkgLevels() []*s_PkgLevels {
+	pkgLevels := []*s_PkgLevels{}
+	for pkg, lv := range this.Packages {
+		levels := map[T_Level]bool{}
+		lvs, _ := LevelsFrom(lv) // 已经检查过
+		for _, l := range lvs {
+			levels[T_Level(l)] = true
+		}
+		pkgLevels = append(pkgLevels, &s_PkgLevels{strings.TrimSuffix(pkg, "/"), levels})
+	}
+	sort.Slice(pkgLevels, func(i, j int) bool {
+		return len(pkgLevels[i].pkg) > len(pkgLevels[j].pkg)
+	})
+	return pkgLevels
+}
+
+// -----------------------------------------------------------------------------
+// apply config
+// -----------------------------------------------------------------------------
+// 可以应用日志级别配置的 logger
+type i_LevelConfigurable interface {
+	ApplyLevelConfig(*S_LevelConfig) error
+}
+
+// 应用日志级别配置，会替换掉之前的按包配置
+func (this *S_Logger) ApplyLevelConfig(cfg *S_LevelConfig) error {
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	this.Lock()
+	defer this.Unlock()
+	if cfg.Level != "" {
+		for lv := range this.levels {
+			this.levels[lv] = false
+		}
+		lvs, _ := LevelsFrom(cfg.Level)
+		for _, lv := range lvs {
+			this.levels[T_Level(lv)] = true
+		}
+	}
+	this.pkgLevels = cfg.pkgLevels()
+	return nil
+}
+
+// 将日志级别配置应用到所有 sink
+func (this *S_TeeLogger) ApplyLevelConfig(cfg *S_LevelConfig) error {
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	for _, sink := range this.allSinks() {
+		if l, ok := sink.logger.(i_LevelConfigurable); ok {
+			if err := l.ApplyLevelConfig(cfg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// package interfaces
+// -----------------------------------------------------------------------------
+// 将日志级别配置应用到全局 logger
+func ApplyLevelConfig(cfg *S_LevelConfig) error {
+	l, ok := logger.(i_LevelConfigurable)
+	if !ok {
+		return fmt.Errorf("logger %T does not support level config", logger)
+	}
+	return l.ApplyLevelConfig(cfg)
+}
+
+// 加载日志级别配置文件并应用到全局 logger
+func LoadLevels(file string) error {
+	cfg, err := LoadLevelConfig(file)
+	if err != nil {
+		return err
+	}
+	return ApplyLevelConfig(cfg)
+}
+
+// 加载日志级别配置文件并应用到全局 logger，之后每次收到 SIGHUP 信号时重新加载
+// 返回的函数用于停止监听信号
+func WatchLevelConfig(file string) (func(), error) {
+	if err := LoadLevels(file); err != nil {
+		return nil, err
+	}
+
+	chSignal := make(chan os.Signal, 1)
+	chStop := make(chan struct{})
+	notifyReload(chSignal)
+	go func() {
+		for {
+			select {
+			case <-chSignal:
+				if err := LoadLevels(file); err != nil {
+					Errorf("reload log level config fail, %v", err)
+				} else {
+					Infof("log level config %q has been reloaded", file)
+				}
+			case <-chStop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stopNotifyReload(chSignal)
+			close(chStop)
+		})
+	}, nil
+}
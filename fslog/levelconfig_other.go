@@ -0,0 +1,22 @@
+//go:build !unix
+
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: reload log levels on SIGHUP
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// windows、js/wasm 等非 unix 系统下没有 SIGHUP 信号，不支持重新加载
+
+package fslog
+
+import "os"
+
+func notifyReload(c chan os.Signal) {
+}
+
+func stopNotifyReload(c chan os.Signal) {
+}
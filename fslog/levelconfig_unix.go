@@ -0,0 +1,26 @@
+//go:build unix
+
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: reload log levels on SIGHUP
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package fslog
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyReload(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGHUP)
+}
+
+func stopNotifyReload(c chan os.Signal) {
+	signal.Stop(c)
+}
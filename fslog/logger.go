@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,12 +22,21 @@ import (
 	"fsky.pro/fspath"
 )
 
+// 日志输出函数
+// fields 为结构化日志字段，非结构化日志为 nil
+type F_Writer func(t time.Time, lv T_Level, msg []byte, fields T_Fields)
+
 type S_Logger struct {
 	sync.Mutex
-	writer   func(time.Time, T_Level, []byte)
-	levels   map[T_Level]bool
-	levelFmt string
-	goidSize int
+	writer    F_Writer
+	levels    map[T_Level]bool
+	formatter I_Formatter
+	async     *S_AsyncWriter // 异步输出，为 nil 时同步输出
+
+	pkgLevels []*s_PkgLevels     // 按包配置的日志级别，包路径长的在前
+	pkgMutex  sync.Mutex         // 保护 pkgCache
+	pkgCache  map[uintptr]string // 调用位置 -> 包路径
+	limiter   s_RateLimiter      // 日志限流
 
 	showSite   bool
 	cutSrcRoot string
@@ -34,6 +44,11 @@ type S_Logger struct {
 }
 
 func NewLogger(writer func(time.Time, T_Level, []byte)) *S_Logger {
+	return NewFieldsLogger(discardFields(writer))
+}
+
+// 新建可以接收结构化字段的 logger
+func NewFieldsLogger(writer F_Writer) *S_Logger {
 	logger := &S_Logger{
 		writer: writer,
 		levels: map[T_Level]bool{
@@ -49,18 +64,28 @@ func NewLogger(writer func(time.Time, T_Level, []byte)) *S_Logger {
 			"PANIC": true,
 			"FATAL": true,
 		},
-		showSite: true,
-	}
-	lvLen := 1
-	for lv := range logger.levels {
-		if lvLen < len(lv) {
-			lvLen = len(lv)
-		}
+		formatter: NewTextFormatter(),
+		pkgCache:  map[uintptr]string{},
+		showSite:  true,
 	}
-	logger.levelFmt = fmt.Sprintf("%%-%ds|", lvLen+2)
 	return logger
 }
 
+// 将不关心字段的输出函数转换为 F_Writer
+func discardFields(writer func(time.Time, T_Level, []byte)) F_Writer {
+	if writer == nil {
+		return nil
+	}
+	return func(t time.Time, lv T_Level, msg []byte, _ T_Fields) {
+		writer(t, lv, msg)
+	}
+}
+
+// 与 fmt.Sprintln 一致，参数之间以空格分隔，但不带结尾换行
+func sprint(arg any, args ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(append([]any{arg}, args...)...), "\n")
+}
+
 // -------------------------------------------------------------------
 // private
 // -------------------------------------------------------------------
@@ -71,86 +96,164 @@ func (this *S_Logger) nowTime() time.Time {
 	return time.Now()
 }
 
-func (this *S_Logger) send(t time.Time, lv T_Level, msg []byte) {
+// 异步输出时，在锁外写入缓冲区，以免缓冲区满时阻塞持有锁
+func (this *S_Logger) send(rec *S_Record) {
+	this.Lock()
+	if this.writer == nil {
+		this.Unlock()
+		return
+	}
+	msg := this.formatter.Format(rec)
+	async := this.async
+	if async == nil {
+		defer this.Unlock()
+		this.writer(rec.Time, rec.Level, msg, rec.Fields)
+		return
+	}
+	this.Unlock()
+	async.Write(rec.Time, rec.Level, msg, rec.Fields)
+}
+
+// 在锁内调用 writer，子类的 writer 依赖于此
+func (this *S_Logger) lockedWrite(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
 	this.Lock()
 	defer this.Unlock()
 	if this.writer != nil {
-		this.writer(t, lv, msg)
+		this.writer(t, lv, msg, fields)
 	}
 }
 
-// ---------------------------------------------------------
-func (this *S_Logger) writef(buff *bytes.Buffer, msg string, args ...any) {
-	buff.WriteString(fmt.Sprintf(msg, args...))
+// 异步输出丢弃日志时，直接输出一条警告
+func (this *S_Logger) reportDropped(dropped uint64) {
+	rec := &S_Record{
+		Time:  this.nowTime(),
+		Level: "WARN",
+		GoID:  this.goID(),
+		Msg:   fmt.Sprintf("async log buffer overflow, %d log messages have been dropped", dropped),
+	}
+	this.Lock()
+	defer this.Unlock()
+	if this.writer != nil {
+		this.writer(rec.Time, rec.Level, this.formatter.Format(rec), nil)
+	}
 }
 
-func (this *S_Logger) writeGoID(buff *bytes.Buffer) {
-	var buf = make([]byte, 23)
+// ---------------------------------------------------------
+func (this *S_Logger) goID() uint64 {
+	var buf = make([]byte, 32)
 	runtime.Stack(buf, false)
 	fields := bytes.Fields(buf)
-	if len(fields) > 1 {
-		goid := fields[1]
-		if len(goid) > this.goidSize {
-			this.goidSize = len(goid)
-		}
-		this.writef(buff, fmt.Sprintf("[G-%%0%ds]|", this.goidSize), goid)
-	} else {
-		buff.WriteString("[G-ERR]|")
+	if len(fields) < 2 {
+		return 0
 	}
+	goid, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return goid
 }
 
-func (this *S_Logger) writePrefix(buff *bytes.Buffer, level T_Level) {
-	this.writef(buff, this.levelFmt, "["+level+"]")
-}
-
-func (this *S_Logger) writeDateTime(buff *bytes.Buffer) time.Time {
-	now := this.nowTime()
-	buff.WriteString(now.Format("2006/01/02 15:04:05.999999"))
-	return now
+func (this *S_Logger) cutFile(file string) string {
+	file = fspath.CleanPath(file)
+	if this.cutSrcRoot != "" {
+		file = strings.TrimPrefix(file, this.cutSrcRoot)
+		file = strings.TrimSuffix(file, ".go")
+	}
+	return file
 }
 
-func (this *S_Logger) writeCallStack(buff *bytes.Buffer, depth int) {
+func (this *S_Logger) callStack(depth int) []S_StackFrame {
 	pc := make([]uintptr, 50)
 	n := runtime.Callers(depth+2, pc)
 	if n < 1 {
-		buff.WriteString("\n???:?")
-		return
+		return []S_StackFrame{{Func: "???", File: "???"}}
 	}
-	frames := runtime.CallersFrames(pc)
+	stack := []S_StackFrame{}
+	frames := runtime.CallersFrames(pc[:n])
 	for {
 		frame, more := frames.Next()
-		this.writef(buff, "\n\t%s(...):\n", frame.Function)
-		file := fspath.CleanPath(frame.File)
-		if this.cutSrcRoot != "" {
-			file = strings.TrimPrefix(file, this.cutSrcRoot)
-			file = strings.TrimSuffix(file, ".go")
-		}
-		this.writef(buff, "\t\t%s:%d", file, frame.Line)
+		stack = append(stack, S_StackFrame{frame.Function, this.cutFile(frame.File), frame.Line})
 		if !more {
 			break
 		}
 	}
+	return stack
 }
 
-func (this *S_Logger) writeCallTopStack(buff *bytes.Buffer, depth int) {
+func (this *S_Logger) callSite(depth int) string {
 	_, file, line, ok := runtime.Caller(depth + 1)
 	if !ok {
-		buff.WriteString("???:?")
-		return
+		return "???:?"
 	}
-	file = fspath.CleanPath(file)
-	if this.cutSrcRoot != "" {
-		file = strings.TrimPrefix(file, this.cutSrcRoot)
-		file = strings.TrimSuffix(file, ".go")
+	return fmt.Sprintf("%s:%d", this.cutFile(file), line)
+}
+
+// 新建一条日志记录
+// TRACE 和 PANIC 总是带上调用位置和调用栈
+func (this *S_Logger) newRecord(depth int, level T_Level, msg string, fields T_Fields) *S_Record {
+	rec := &S_Record{
+		Time:   this.nowTime(),
+		Level:  level,
+		GoID:   this.goID(),
+		Msg:    msg,
+		Fields: fields,
 	}
-	this.writef(buff, "%s:%d", file, line)
+	withStack := level == "TRACE" || level == "PANIC"
+	if this.showSite || withStack {
+		rec.Site = this.callSite(depth + 1)
+	}
+	if withStack {
+		rec.Stack = this.callStack(depth + 1)
+	}
+	return rec
+}
+
+// panic 抛出的字符串为不带调用栈的文本格式日志
+func (this *S_Logger) panicMsg(rec *S_Record) string {
+	buff := new(bytes.Buffer)
+	NewTextFormatter().formatLine(buff, rec)
+	return buff.String()
 }
 
 // ---------------------------------------------------------
-func (this *S_Logger) isShield(lv T_Level) bool {
+// depth 为调用层次，用于在按包配置了日志级别时，确定调用者所在的包
+// 调用者所在的包配置了级别时，以包的级别为准，Shield/Unshield 对该包不起作用
+func (this *S_Logger) isShield(depth int, lv T_Level) bool {
 	this.Lock()
-	defer this.Unlock()
-	return !this.levels[lv]
+	shield := !this.levels[lv]
+	pkgLevels := this.pkgLevels // ApplyLevelConfig 整体替换该列表，不会修改其内容
+	this.Unlock()
+	if len(pkgLevels) > 0 {
+		// 获取调用位置比较耗时，不在锁内进行
+		pkg := this.callerPackage(depth + 1)
+		for _, levels := range pkgLevels {
+			if levels.match(pkg) {
+				return !levels.levels[lv]
+			}
+		}
+	}
+	return shield
+}
+
+// 调用者所在包的路径
+func (this *S_Logger) callerPackage(depth int) string {
+	pc, _, _, ok := runtime.Caller(depth + 1)
+	if !ok {
+		return ""
+	}
+	this.pkgMutex.Lock()
+	pkg, ok := this.pkgCache[pc]
+	this.pkgMutex.Unlock()
+	if ok {
+		return pkg
+	}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		pkg = funcPackage(fn.Name())
+	}
+	this.pkgMutex.Lock()
+	if len(this.pkgCache) > 4096 {
+		this.pkgCache = map[uintptr]string{}
+	}
+	this.pkgCache[pc] = pkg
+	this.pkgMutex.Unlock()
+	return pkg
 }
 
 // -------------------------------------------------------------------
@@ -222,90 +325,40 @@ func (this *S_Logger) Criticalf_(depth int, msg string, args ...any) {
 
 func (this *S_Logger) Fatal_(depth int, arg any, args ...any) {
 	this.Output(depth+1, "FATAL", arg, args...)
+	this.Flush()
 	os.Exit(1)
 }
 
 func (this *S_Logger) Fatalf_(depth int, msg string, args ...any) {
 	this.Outputf(depth+1, "FATAL", msg, args...)
+	this.Flush()
 	os.Exit(1)
 }
 
 func (this *S_Logger) Panic_(depth int, arg any, args ...any) {
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, "PANIC")
-	now := this.writeDateTime(bb)
-	bb.WriteByte(' ')
-	this.writeCallTopStack(bb, depth+1)
-	bb.WriteString(": ")
-	this.writef(bb, "%v", arg)
-	for _, a := range args {
-		this.writef(bb, " %v", a)
+	rec := this.newRecord(depth+1, "PANIC", sprint(arg, args...), nil)
+	if !this.isShield(depth+1, "PANIC") {
+		this.send(rec)
+		this.Flush()
 	}
-
-	msg := bb.String()
-	if !this.isShield("PANIC") {
-		this.writeCallStack(bb, depth+1)
-		bb.WriteByte('\n')
-		this.send(now, "PANIC", bb.Bytes())
-	}
-	panic(msg)
+	panic(this.panicMsg(rec))
 }
 
 func (this *S_Logger) Panicf_(depth int, msg string, args ...any) {
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, "PANIC")
-	now := this.writeDateTime(bb)
-	bb.WriteByte(' ')
-	this.writeCallTopStack(bb, depth+1)
-	bb.WriteString(": ")
-	this.writef(bb, msg, args...)
-
-	msg = bb.String()
-	if !this.isShield("PANIC") {
-		this.writeCallStack(bb, depth+1)
-		bb.WriteByte('\n')
-		this.send(now, "PANIC", bb.Bytes())
+	rec := this.newRecord(depth+1, "PANIC", fmt.Sprintf(msg, args...), nil)
+	if !this.isShield(depth+1, "PANIC") {
+		this.send(rec)
+		this.Flush()
 	}
-	panic(msg)
+	panic(this.panicMsg(rec))
 }
 
 func (this *S_Logger) Trace_(depth int, arg any, args ...any) {
-	if this.isShield("TRACE") {
-		return
-	}
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, "TRACE")
-	now := this.writeDateTime(bb)
-	bb.WriteByte(' ')
-	this.writeCallTopStack(bb, depth+1)
-	bb.WriteString(": ")
-	this.writef(bb, "%v", arg)
-	for _, a := range args {
-		this.writef(bb, " %v", a)
-	}
-	this.writeCallStack(bb, depth+1)
-	bb.WriteByte('\n')
-	this.send(now, "TRACE", bb.Bytes())
+	this.Output(depth+1, "TRACE", arg, args...)
 }
 
 func (this *S_Logger) Tracef_(depth int, msg string, args ...any) {
-	if this.isShield("TRACE") {
-		return
-	}
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, "TRACE")
-	now := this.writeDateTime(bb)
-	bb.WriteByte(' ')
-	this.writeCallTopStack(bb, depth+1)
-	bb.WriteString(": ")
-	this.writef(bb, msg, args...)
-	this.writeCallStack(bb, depth+1)
-	bb.WriteByte('\n')
-	this.send(now, "TRACE", bb.Bytes())
+	this.Outputf(depth+1, "TRACE", msg, args...)
 }
 
 // -------------------------------------------------------------------
@@ -320,54 +373,97 @@ func (this *S_Logger) CutSrcRoot(root string) {
 }
 
 func (this *S_Logger) SetOutputWriter(writer func(time.Time, T_Level, []byte)) {
+	this.Lock()
+	defer this.Unlock()
+	this.writer = discardFields(writer)
+}
+
+// 设置日志格式化器，默认为 S_TextFormatter
+func (this *S_Logger) SetFormatter(formatter I_Formatter) {
+	this.Lock()
+	defer this.Unlock()
+	if formatter == nil {
+		formatter = NewTextFormatter()
+	}
+	this.formatter = formatter
+}
+
+func (this *S_Logger) SetFieldsOutputWriter(writer F_Writer) {
 	this.Lock()
 	defer this.Unlock()
 	this.writer = writer
 }
 
+// 设置异步输出，size 为缓冲区能容纳的日志条数，overflow 为缓冲区满时的处理策略
+// size 小于 1 时，关闭异步输出，并输出缓冲区中剩余的日志
+func (this *S_Logger) SetAsync(size int, overflow T_Overflow) {
+	this.Lock()
+	async := this.async
+	this.async = nil
+	if size > 0 {
+		this.async = NewAsyncWriter(this.lockedWrite, size, overflow)
+		this.async.OnDropped = this.reportDropped
+	}
+	this.Unlock()
+	if async != nil {
+		async.Close()
+	}
+}
+
+// 异步输出时，等待缓冲区中的日志全部输出完毕；同步输出时什么都不做
+func (this *S_Logger) Flush() {
+	this.Lock()
+	async := this.async
+	this.Unlock()
+	if async != nil {
+		async.Flush()
+	}
+}
+
+// 异步输出累计丢弃的日志数量
+func (this *S_Logger) Dropped() uint64 {
+	this.Lock()
+	async := this.async
+	this.Unlock()
+	if async != nil {
+		return async.Dropped()
+	}
+	return 0
+}
+
+// 返回一个绑定了指定字段的 logger，通过它输出的日志都会带上这些字段
+func (this *S_Logger) With(kvs ...any) *S_FieldLogger {
+	return newFieldLogger(this, Fields(kvs...))
+}
+
 // ---------------------------------------------------------
 func (this *S_Logger) Output(depth int, level T_Level, arg any, args ...any) {
-	if this.isShield(level) {
+	if this.isShield(depth+1, level) || !this.allow(depth+1, level) {
 		return
 	}
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, level)
-	now := this.writeDateTime(bb)
-	if this.showSite {
-		bb.WriteByte(' ')
-		this.writeCallTopStack(bb, depth+1)
-	}
-	bb.WriteString(": ")
-	this.writef(bb, "%v", arg)
-	for _, a := range args {
-		this.writef(bb, " %v", a)
+	this.send(this.newRecord(depth+1, level, sprint(arg, args...), nil))
+}
+
+// 输出带结构化字段的日志
+// TRACE 和 PANIC 级别会附带调用栈，但不会 panic；FATAL 级别也不会退出进程
+func (this *S_Logger) Outputw(depth int, level T_Level, msg string, fields T_Fields) {
+	if this.isShield(depth+1, level) || !this.allow(depth+1, level) {
+		return
 	}
-	bb.WriteByte('\n')
-	this.send(now, level, bb.Bytes())
+	this.send(this.newRecord(depth+1, level, msg, fields))
 }
 
 func (this *S_Logger) Outputf(depth int, level T_Level, msg string, args ...any) {
-	if this.isShield(level) {
+	if this.isShield(depth+1, level) || !this.allow(depth+1, level) {
 		return
 	}
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, level)
-	now := this.writeDateTime(bb)
-	if this.showSite {
-		bb.WriteByte(' ')
-		this.writeCallTopStack(bb, depth+1)
-	}
-	bb.WriteString(": ")
-	this.writef(bb, msg, args...)
-	bb.WriteByte('\n')
-	this.send(now, level, bb.Bytes())
+	this.send(this.newRecord(depth+1, level, fmt.Sprintf(msg, args...), nil))
 }
 
 // ---------------------------------------------------------
 // 过滤输出级别
 // debug/info/warn/error/notice/clit/trace
+// 只影响默认级别，按包配置了级别（ApplyLevelConfig）的包不受影响
 func (this *S_Logger) Shield(lvs ...string) {
 	this.Lock()
 	defer this.Unlock()
@@ -390,6 +486,7 @@ func (this *S_Logger) ShieldAll() {
 
 // 取消过滤日志级别
 // debug/info/warn/error/notice/clit/trace
+// 与 Shield 一样，按包配置了级别的包不受影响
 func (this *S_Logger) Unshield(lvs ...string) {
 	this.Lock()
 	defer this.Unlock()
@@ -421,13 +518,14 @@ func (this *S_Logger) Whateverf(lv T_Level, msg string, args ...any) {
 
 // 直接输出字符串，不带任何前缀记录
 func (this *S_Logger) Direct(lv T_Level, msg string) {
-	if this.isShield(lv) { return }
-	bb := new(bytes.Buffer)
-	this.writeGoID(bb)
-	this.writePrefix(bb, lv)
-	bb.Write([]byte(msg))
-	bb.WriteByte('\n')
-	this.send(time.Now(), lv, bb.Bytes())
+	if this.isShield(1, lv) { return }
+	this.send(&S_Record{
+		Time:  this.nowTime(),
+		Level: lv,
+		GoID:  this.goID(),
+		Msg:   msg,
+		Raw:   true,
+	})
 }
 
 func (this *S_Logger) Directf(lv T_Level, msg string, args ...any) {
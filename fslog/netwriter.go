@@ -0,0 +1,116 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: network log writer
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package fslog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// NetWriter
+// -----------------------------------------------------------------------------
+// 将日志发送到网络日志服务，由 S_SyslogLogger 和 S_JournaldLogger 共用：
+//
+//	发送失败时重新连接并再发送一次，仍然失败则输出到 stderr
+//	连接失败后，RetryInterval 内不再连接，日志直接输出到 stderr
+//
+// 所有方法都在 logger 的锁内调用
+type s_NetWriter struct {
+	desc   string                                        // 日志服务描述，用于错误信息
+	dialer func(timeout time.Duration) (net.Conn, error) // 连接日志服务
+	framer func(conn net.Conn, data []byte) []byte       // 发送前对数据分帧，为 nil 则不分帧
+	now    func() time.Time
+
+	conn          net.Conn
+	lastDialTime  time.Time
+	RetryInterval time.Duration // 连接失败后，至少间隔多久才再次连接，默认 5 秒
+	Timeout       time.Duration // 连接和发送超时，默认 3 秒（发送时持有 logger 锁，不能无限等待）
+}
+
+func newNetWriter(desc string, dialer func(time.Duration) (net.Conn, error), now func() time.Time) s_NetWriter {
+	return s_NetWriter{
+		desc:          desc,
+		dialer:        dialer,
+		now:           now,
+		RetryInterval: 5 * time.Second,
+		Timeout:       3 * time.Second,
+	}
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+func (this *s_NetWriter) dial() error {
+	this.lastDialTime = time.Now()
+	conn, err := this.dialer(this.Timeout)
+	if err != nil {
+		return fmt.Errorf("dial to %s fail, %v", this.desc, err)
+	}
+	this.conn = conn
+	return nil
+}
+
+func (this *s_NetWriter) sendData(data []byte) error {
+	if this.conn == nil {
+		if time.Since(this.lastDialTime) < this.RetryInterval {
+			return fmt.Errorf("%s is not connected", this.desc)
+		}
+		if err := this.dial(); err != nil {
+			return err
+		}
+	}
+	if this.framer != nil {
+		data = this.framer(this.conn, data)
+	}
+	if this.Timeout > 0 {
+		this.conn.SetWriteDeadline(time.Now().Add(this.Timeout))
+	}
+	_, err := this.conn.Write(data)
+	return err
+}
+
+// 发送失败时输出到 stderr，lv 为空表示只输出错误
+func (this *s_NetWriter) writeStderr(t time.Time, lv T_Level, msg []byte, err error) {
+	now := this.now()
+	os.Stderr.WriteString(now.Format("[ERROR]|2006/01/02 15:04:05.999999 "))
+	os.Stderr.WriteString(err.Error())
+	os.Stderr.WriteString("\n")
+	if lv == "" {
+		return
+	}
+	os.Stderr.WriteString(fmt.Sprintf("[%s]|%s ", lv, t.Format("2006/01/02 15:04:05.999999")))
+	os.Stderr.Write(msg)
+	os.Stderr.WriteString("\n")
+}
+
+// 发送已经编码好的日志 data，失败时将日志消息 msg 输出到 stderr
+func (this *s_NetWriter) output(t time.Time, lv T_Level, msg []byte, data []byte) {
+	err := this.sendData(data)
+	if err != nil && this.conn != nil {
+		// 连接已断开，重新连接后再发送一次
+		this.closeConn()
+		this.lastDialTime = time.Time{}
+		err = this.sendData(data)
+	}
+	if err != nil {
+		this.closeConn()
+		this.writeStderr(t, lv, msg, err)
+	}
+}
+
+func (this *s_NetWriter) closeConn() {
+	if this.conn != nil {
+		this.conn.Close()
+		this.conn = nil
+	}
+}
@@ -0,0 +1,175 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: log rate limiting
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 日志限流
+// 同一调用位置、同一级别的日志，在每个时间窗口内：
+//   前 Burst 条全部输出，之后每 Every 条输出一条，其余的被抑制
+// 窗口结束时，如果有被抑制的日志，会在原调用位置输出一条汇总：
+//   [G-31]|[ERROR] |2006/01/02 15:04:05.999999 db.go:12: suppressed 1234 similar messages in 1s
+// 如：
+//   logger.SetRateLimit(fslog.S_RateLimit{Interval: time.Second, Burst: 10, Every: 100}, "error", "warn")
+
+package fslog
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// 限流规则
+type S_RateLimit struct {
+	Interval time.Duration // 时间窗口
+	Burst    int           // 每个窗口内全部输出的条数
+	Every    int           // 超过 Burst 后，每 Every 条输出一条，小于 1 时全部抑制
+}
+
+type s_RateKey struct {
+	pc uintptr
+	lv T_Level
+}
+
+// 一个调用位置在当前窗口内的状态
+type s_RateState struct {
+	start      time.Time
+	count      int
+	suppressed int
+	site       string
+	timer      *time.Timer // 窗口结束时输出汇总，有被抑制的日志时才启动
+}
+
+type s_RateLimiter struct {
+	sync.Mutex
+	limits map[T_Level]S_RateLimit
+	states map[s_RateKey]*s_RateState
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+// 清除窗口已经结束的状态，调用前必须已经上锁
+func (this *s_RateLimiter) prune(now time.Time) {
+	for key, state := range this.states {
+		if state.timer == nil && now.Sub(state.start) >= this.limits[key.lv].Interval {
+			delete(this.states, key)
+		}
+	}
+}
+
+// depth 为调用层次，用于确定调用位置
+// 返回 false 表示该条日志被抑制
+func (this *S_Logger) allow(depth int, lv T_Level) bool {
+	limiter := &this.limiter
+	limiter.Lock()
+	defer limiter.Unlock()
+	limit, ok := limiter.limits[lv]
+	if !ok {
+		return true
+	}
+	pc, file, line, _ := runtime.Caller(depth + 1)
+	key := s_RateKey{pc, lv}
+	now := time.Now()
+	state := limiter.states[key]
+	if state == nil {
+		if len(limiter.states) > 4096 {
+			limiter.prune(now)
+		}
+		state = &s_RateState{start: now, site: fmt.Sprintf("%s:%d", this.cutFile(file), line)}
+		limiter.states[key] = state
+	} else if state.timer == nil && now.Sub(state.start) >= limit.Interval {
+		state.start = now
+		state.count = 0
+	}
+
+	state.count++
+	if state.count <= limit.Burst {
+		return true
+	}
+	if limit.Every > 0 && (state.count-limit.Burst)%limit.Every == 0 {
+		return true
+	}
+	state.suppressed++
+	if state.timer == nil {
+		state.timer = time.AfterFunc(limit.Interval-now.Sub(state.start), func() {
+			this.reportSuppressed(key, limit.Interval)
+		})
+	}
+	return false
+}
+
+// 窗口结束，输出被抑制日志的汇总，并开始新的窗口
+func (this *S_Logger) reportSuppressed(key s_RateKey, interval time.Duration) {
+	limiter := &this.limiter
+	limiter.Lock()
+	state := limiter.states[key]
+	if state == nil {
+		limiter.Unlock()
+		return
+	}
+	suppressed := state.suppressed
+	state.suppressed = 0
+	state.count = 0
+	state.start = time.Now()
+	state.timer = nil
+	site := state.site
+	limiter.Unlock()
+
+	rec := &S_Record{
+		Time:  this.nowTime(),
+		Level: key.lv,
+		GoID:  this.goID(),
+		Msg:   fmt.Sprintf("suppressed %d similar messages in %v", suppressed, interval),
+	}
+	if this.showSite {
+		rec.Site = site
+	}
+	this.send(rec)
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 为指定级别设置限流规则，不指定级别则设置所有级别
+// limit.Interval 小于等于 0 时，取消这些级别的限流
+// lvs 中有不合法的级别名称时返回错误，不修改任何级别的限流规则
+func (this *S_Logger) SetRateLimit(limit S_RateLimit, lvs ...string) error {
+	levels, err := toLevels(lvs)
+	if err != nil {
+		return fmt.Errorf("set rate limit fail, %v", err)
+	}
+	if len(levels) == 0 {
+		levels = allLevels
+	}
+	limiter := &this.limiter
+	limiter.Lock()
+	defer limiter.Unlock()
+	if limiter.limits == nil {
+		limiter.limits = map[T_Level]S_RateLimit{}
+	}
+	if limiter.states == nil {
+		limiter.states = map[s_RateKey]*s_RateState{}
+	}
+	for _, lv := range levels {
+		if limit.Interval > 0 {
+			limiter.limits[lv] = limit
+		} else {
+			delete(limiter.limits, lv)
+		}
+	}
+	return nil
+}
+
+// 取消所有级别的限流，已经被抑制的日志仍会在窗口结束时输出汇总
+func (this *S_Logger) RemoveRateLimit() {
+	limiter := &this.limiter
+	limiter.Lock()
+	defer limiter.Unlock()
+	limiter.limits = nil
+}
@@ -25,14 +25,14 @@ type S_StdoutLogger struct {
 // filePrefix 为 log 文件名前缀
 func NewStdoutLogger() *S_StdoutLogger {
 	logger := &S_StdoutLogger{}
-	logger.S_Logger = NewLogger(logger.write)
+	logger.S_Logger = NewFieldsLogger(logger.write)
 	return logger
 }
 
 // -------------------------------------------------------------------
 // private
 // -------------------------------------------------------------------
-func (this *S_StdoutLogger) write(t time.Time, lv T_Level, msg []byte) {
+func (this *S_StdoutLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
 	os.Stdout.Write(msg)
 }
 
@@ -0,0 +1,315 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: logger for syslog
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 以 RFC 5424 格式将日志发送到 syslog 服务器，支持 udp/tcp/unix socket
+// 结构化字段放在 STRUCTURED-DATA 中：[fields@32473 key="value" ...]
+// 发送失败时会尝试重新连接，连接不上则输出到 stderr
+// 新建时连接不上 syslog 服务器也会返回 logger，先输出到 stderr，之后按 RetryInterval 重连
+
+package fslog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fslog 日志级别对应的 RFC 5424 severity
+var syslogSeverities = map[T_Level]int{
+	"DEBUG": 7, // debug
+	"INFO":  6, // informational
+	"NOTIC": 5, // notice
+	"WARN":  4, // warning
+	"ILLEG": 4, // warning
+	"ERROR": 3, // error
+	"HACK":  3, // error
+	"TRACE": 3, // error
+	"CRIT":  2, // critical
+	"PANIC": 1, // alert
+	"FATAL": 0, // emergency
+}
+
+func syslogSeverity(lv T_Level) int {
+	if severity, ok := syslogSeverities[lv]; ok {
+		return severity
+	}
+	return 6
+}
+
+// -----------------------------------------------------------------------------
+// syslog formatter
+// -----------------------------------------------------------------------------
+// 时间、级别和字段由 syslog 头部携带，消息中只保留调用位置、消息和调用栈
+type s_SyslogFormatter struct {
+	text *S_TextFormatter
+}
+
+func (this *s_SyslogFormatter) Format(rec *S_Record) []byte {
+	buff := new(bytes.Buffer)
+	if rec.Site != "" && !rec.Raw {
+		buff.WriteString(rec.Site)
+		buff.WriteString(": ")
+	}
+	buff.WriteString(rec.Msg)
+	this.text.writeStack(buff, rec.Stack)
+	return buff.Bytes()
+}
+
+// syslog/journald 的时间、级别和字段由协议头携带，只能使用 syslog 格式化器
+// 其他格式化器（如 JSON）会重复输出这些内容，或者破坏协议格式
+func setSyslogFormatter(logger *S_Logger, formatter I_Formatter) error {
+	if formatter == nil {
+		formatter = &s_SyslogFormatter{NewTextFormatter()}
+	}
+	if _, ok := formatter.(*s_SyslogFormatter); !ok {
+		return fmt.Errorf("formatter %T is not supported by syslog/journald logger", formatter)
+	}
+	logger.SetFormatter(formatter)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// SyslogLogger
+// -----------------------------------------------------------------------------
+type S_SyslogLogger struct {
+	*S_Logger
+	s_NetWriter
+	network  string
+	raddr    string
+	tag      string
+	hostname string
+	facility int
+}
+
+// network 可以为 udp/tcp/unix，unix 会先尝试 unixgram
+// raddr 为 syslog 服务地址，如 127.0.0.1:514、/dev/log
+// tag 为 APP-NAME，为空则使用程序名
+// 只有 network 不支持时才返回错误，连接不上 syslog 服务器不返回错误
+func NewSyslogLogger(network, raddr, tag string) (*S_SyslogLogger, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	logger := &S_SyslogLogger{
+		network:  network,
+		raddr:    raddr,
+		tag:      tag,
+		hostname: hostname,
+		facility: 1,
+	}
+	logger.S_Logger = NewFieldsLogger(logger.write)
+	logger.S_Logger.formatter = &s_SyslogFormatter{NewTextFormatter()}
+	desc := fmt.Sprintf("syslog server %s://%s", network, raddr)
+	logger.s_NetWriter = newNetWriter(desc, logger.dialServer, logger.nowTime)
+	logger.framer = logger.frame
+	if err := logger.dial(); err != nil {
+		logger.writeStderr(time.Now(), "", nil, err)
+	}
+	return logger, nil
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+func (this *S_SyslogLogger) dialServer(timeout time.Duration) (net.Conn, error) {
+	if this.network == "unix" {
+		conn, err := net.DialTimeout("unixgram", this.raddr, timeout)
+		if err == nil {
+			return conn, nil
+		}
+		return net.DialTimeout("unix", this.raddr, timeout)
+	}
+	return net.DialTimeout(this.network, this.raddr, timeout)
+}
+
+// 流式连接需要用 RFC 6587 octet-counting 的方式分帧
+func (this *S_SyslogLogger) frame(conn net.Conn, data []byte) []byte {
+	stream := true
+	if _, isUDP := conn.(*net.UDPConn); isUDP {
+		stream = false
+	} else if unixConn, isUnix := conn.(*net.UnixConn); isUnix {
+		stream = unixConn.RemoteAddr().Network() == "unix"
+	}
+	if !stream {
+		return data
+	}
+	return append([]byte(strconv.Itoa(len(data))+" "), data...)
+}
+
+// SD-NAME 不能包含 '='、空格、']'、'"'，最长 32 个字符
+func (this *S_SyslogLogger) sdName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c <= ' ' || c >= 127 || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if len(name) == 0 {
+		return "_"
+	}
+	return string(name)
+}
+
+func (this *S_SyslogLogger) writeStructuredData(buff *bytes.Buffer, fields T_Fields) {
+	if len(fields) == 0 {
+		buff.WriteByte('-')
+		return
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	buff.WriteString("[fields@32473")
+	for _, field := range fields {
+		fmt.Fprintf(buff, ` %s="%s"`, this.sdName(field.Key), escaper.Replace(fmt.Sprintf("%v", field.Value)))
+	}
+	buff.WriteByte(']')
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (this *S_SyslogLogger) format(t time.Time, lv T_Level, msg []byte, fields T_Fields) []byte {
+	buff := new(bytes.Buffer)
+	fmt.Fprintf(buff, "<%d>1 %s %s %s %d %s ",
+		this.facility*8+syslogSeverity(lv),
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		this.hostname, this.tag, os.Getpid(), lv)
+	this.writeStructuredData(buff, fields)
+	buff.WriteByte(' ')
+	buff.Write(msg)
+	return buff.Bytes()
+}
+
+// 父类中的 send 函数中已经 lock，因此这里不需要再上锁了
+func (this *S_SyslogLogger) write(t time.Time, lv T_Level, msg []byte, fields T_Fields) {
+	this.output(t, lv, msg, this.format(t, lv, msg, fields))
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 设置 syslog facility，默认为 1（user-level messages），local0~local7 为 16~23
+func (this *S_SyslogLogger) SetFacility(facility int) {
+	this.Lock()
+	defer this.Unlock()
+	this.facility = facility
+}
+
+// 格式化器只能为 nil（使用默认的 syslog 格式化器），否则返回错误
+func (this *S_SyslogLogger) SetFormatter(formatter I_Formatter) error {
+	return setSyslogFormatter(this.S_Logger, formatter)
+}
+
+func (this *S_SyslogLogger) Close() {
+	this.Flush()
+	this.Lock()
+	defer this.Unlock()
+	this.closeConn()
+}
+
+// ---------------------------------------------------------
+func (this *S_SyslogLogger) Debug(arg any, args ...any) {
+	this.S_Logger.Debug_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Debugf(msg string, args ...any) {
+	this.S_Logger.Debugf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Info(arg any, args ...any) {
+	this.S_Logger.Info_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Infof(msg string, args ...any) {
+	this.S_Logger.Infof_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Notic(arg any, args ...any) {
+	this.S_Logger.Notic_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Noticf(msg string, args ...any) {
+	this.S_Logger.Noticf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Warn(arg any, args ...any) {
+	this.S_Logger.Warn_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Warnf(msg string, args ...any) {
+	this.S_Logger.Warnf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Error(arg any, args ...any) {
+	this.S_Logger.Error_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Errorf(msg string, args ...any) {
+	this.S_Logger.Errorf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Hack(arg any, args ...any) {
+	this.S_Logger.Hack_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Hackf(msg string, args ...any) {
+	this.S_Logger.Hackf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Illeg(arg any, args ...any) {
+	this.S_Logger.Illeg_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Illegf(msg string, args ...any) {
+	this.S_Logger.Illegf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Critical(arg any, args ...any) {
+	this.S_Logger.Critical_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Criticalf(msg string, args ...any) {
+	this.S_Logger.Criticalf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Trace(arg any, args ...any) {
+	this.S_Logger.Trace_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Tracef(msg string, args ...any) {
+	this.S_Logger.Tracef_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Panic(arg any, args ...any) {
+	this.S_Logger.Panic_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Panicf(msg string, args ...any) {
+	this.S_Logger.Panicf_(1, msg, args...)
+}
+
+func (this *S_SyslogLogger) Fatal(arg any, args ...any) {
+	this.S_Logger.Fatal_(1, arg, args...)
+}
+
+func (this *S_SyslogLogger) Fatalf(msg string, args ...any) {
+	this.S_Logger.Fatalf_(1, msg, args...)
+}
@@ -0,0 +1,416 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: logger fan out to multiple loggers
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 将日志同时输出到多个 logger，每个 logger 可以指定接收的日志级别，如：
+//   tee := fslog.NewTeeLogger()
+//   tee.AddSink(dayfileLogger)
+//   warns, _ := fslog.LevelsFrom("warn")
+//   tee.AddSink(stdoutLogger, warns...)
+//   tee.AddSink(chanLogger, "crit", "panic", "fatal")
+//   fslog.SetLogger(tee)
+
+package fslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+type s_TeeSink struct {
+	logger I_Logger
+	levels map[T_Level]bool
+}
+
+func (this *s_TeeSink) accept(lv T_Level) bool {
+	return this.levels == nil || this.levels[lv]
+}
+
+// -----------------------------------------------------------------------------
+// TeeLogger
+// -----------------------------------------------------------------------------
+type S_TeeLogger struct {
+	sync.Mutex
+	sinks  []*s_TeeSink
+	levels map[T_Level]bool
+}
+
+func NewTeeLogger() *S_TeeLogger {
+	logger := &S_TeeLogger{
+		levels: map[T_Level]bool{},
+	}
+	for _, lv := range allLevels {
+		logger.levels[lv] = true
+	}
+	return logger
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+// 返回接收指定级别日志的 sink 列表
+// 不在锁内调用 sink，以免 sink 中的 Fatal/Panic 等调用造成死锁
+func (this *S_TeeLogger) sinksOf(lv T_Level) []*s_TeeSink {
+	this.Lock()
+	defer this.Unlock()
+	if !this.levels[lv] {
+		return nil
+	}
+	sinks := []*s_TeeSink{}
+	for _, sink := range this.sinks {
+		if sink.accept(lv) {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
+func (this *S_TeeLogger) allSinks() []*s_TeeSink {
+	this.Lock()
+	defer this.Unlock()
+	return append([]*s_TeeSink{}, this.sinks...)
+}
+
+// -------------------------------------------------------------------
+// public
+// -------------------------------------------------------------------
+// 添加一个输出 logger，lvs 为该 logger 接收的日志级别，不指定则接收所有级别
+// lvs 中有不合法的级别名称时返回错误，不添加该 logger
+func (this *S_TeeLogger) AddSink(logger I_Logger, lvs ...string) error {
+	levels, err := toLevels(lvs)
+	if err != nil {
+		return fmt.Errorf("add sink fail, %v", err)
+	}
+	sink := &s_TeeSink{logger: logger}
+	if len(levels) > 0 {
+		sink.levels = map[T_Level]bool{}
+		for _, lv := range levels {
+			sink.levels[lv] = true
+		}
+	}
+	this.Lock()
+	defer this.Unlock()
+	this.sinks = append(this.sinks, sink)
+	return nil
+}
+
+// 返回一个绑定了指定字段的 logger
+func (this *S_TeeLogger) With(kvs ...any) *S_FieldLogger {
+	return newFieldLogger(this, Fields(kvs...))
+}
+
+// 移除输出 logger
+func (this *S_TeeLogger) RemoveSink(logger I_Logger) {
+	this.Lock()
+	defer this.Unlock()
+	for i, sink := range this.sinks {
+		if sink.logger == logger {
+			this.sinks = append(this.sinks[:i], this.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// ---------------------------------------------------------
+func (this *S_TeeLogger) ToggleSite(show bool) {
+	for _, sink := range this.allSinks() {
+		sink.logger.ToggleSite(show)
+	}
+}
+
+func (this *S_TeeLogger) CutSrcRoot(root string) {
+	for _, sink := range this.allSinks() {
+		sink.logger.CutSrcRoot(root)
+	}
+}
+
+// 屏蔽/解除屏蔽作用于 tee logger 本身，对所有 sink 生效
+func (this *S_TeeLogger) Shield(lvs ...string) {
+	this.Lock()
+	defer this.Unlock()
+	for _, lv := range lvs {
+		if _, ok := this.levels[Lv(lv)]; ok {
+			this.levels[Lv(lv)] = false
+		}
+	}
+}
+
+func (this *S_TeeLogger) ShieldAll() {
+	this.Lock()
+	defer this.Unlock()
+	for lv := range this.levels {
+		this.levels[lv] = false
+	}
+}
+
+func (this *S_TeeLogger) Unshield(lvs ...string) {
+	this.Lock()
+	defer this.Unlock()
+	for _, lv := range lvs {
+		if _, ok := this.levels[Lv(lv)]; ok {
+			this.levels[Lv(lv)] = true
+		}
+	}
+}
+
+func (this *S_TeeLogger) UnshieldAll() {
+	this.Lock()
+	defer this.Unlock()
+	for lv := range this.levels {
+		this.levels[lv] = true
+	}
+}
+
+func (this *S_TeeLogger) Flush() {
+	for _, sink := range this.allSinks() {
+		sink.logger.Flush()
+	}
+}
+
+// ---------------------------------------------------------
+func (this *S_TeeLogger) Debug_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("DEBUG") {
+		sink.logger.Debug_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Debugf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("DEBUG") {
+		sink.logger.Debugf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Info_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("INFO") {
+		sink.logger.Info_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Infof_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("INFO") {
+		sink.logger.Infof_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Notic_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("NOTIC") {
+		sink.logger.Notic_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Noticf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("NOTIC") {
+		sink.logger.Noticf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Warn_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("WARN") {
+		sink.logger.Warn_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Warnf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("WARN") {
+		sink.logger.Warnf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Error_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("ERROR") {
+		sink.logger.Error_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Errorf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("ERROR") {
+		sink.logger.Errorf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Hack_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("HACK") {
+		sink.logger.Hack_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Hackf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("HACK") {
+		sink.logger.Hackf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Illeg_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("ILLEG") {
+		sink.logger.Illeg_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Illegf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("ILLEG") {
+		sink.logger.Illegf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Critical_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("CRIT") {
+		sink.logger.Critical_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Criticalf_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("CRIT") {
+		sink.logger.Criticalf_(depth+1, msg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Trace_(depth int, arg any, args ...any) {
+	for _, sink := range this.sinksOf("TRACE") {
+		sink.logger.Trace_(depth+1, arg, args...)
+	}
+}
+
+func (this *S_TeeLogger) Tracef_(depth int, msg string, args ...any) {
+	for _, sink := range this.sinksOf("TRACE") {
+		sink.logger.Tracef_(depth+1, msg, args...)
+	}
+}
+
+// sink 的 Panic_/Fatal_ 会 panic 或退出进程，因此通过 Outputw 输出到所有 sink 后再统一处理
+func (this *S_TeeLogger) Panic_(depth int, arg any, args ...any) {
+	msg := sprint(arg, args...)
+	this.Outputw(depth+1, "PANIC", msg, nil)
+	this.Flush()
+	panic(msg)
+}
+
+func (this *S_TeeLogger) Panicf_(depth int, msg string, args ...any) {
+	msg = fmt.Sprintf(msg, args...)
+	this.Outputw(depth+1, "PANIC", msg, nil)
+	this.Flush()
+	panic(msg)
+}
+
+func (this *S_TeeLogger) Fatal_(depth int, arg any, args ...any) {
+	this.Outputw(depth+1, "FATAL", sprint(arg, args...), nil)
+	this.Flush()
+	os.Exit(1)
+}
+
+func (this *S_TeeLogger) Fatalf_(depth int, msg string, args ...any) {
+	this.Outputw(depth+1, "FATAL", fmt.Sprintf(msg, args...), nil)
+	this.Flush()
+	os.Exit(1)
+}
+
+// ---------------------------------------------------------
+func (this *S_TeeLogger) Direct(lv T_Level, msg string) {
+	for _, sink := range this.sinksOf(lv) {
+		sink.logger.Direct(lv, msg)
+	}
+}
+
+func (this *S_TeeLogger) Directf(lv T_Level, msg string, args ...any) {
+	this.Direct(lv, fmt.Sprintf(msg, args...))
+}
+
+func (this *S_TeeLogger) Outputw(depth int, lv T_Level, msg string, fields T_Fields) {
+	for _, sink := range this.sinksOf(lv) {
+		sink.logger.Outputw(depth+1, lv, msg, fields)
+	}
+}
+
+// ---------------------------------------------------------
+func (this *S_TeeLogger) Debug(arg any, args ...any) {
+	this.Debug_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Debugf(msg string, args ...any) {
+	this.Debugf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Info(arg any, args ...any) {
+	this.Info_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Infof(msg string, args ...any) {
+	this.Infof_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Notic(arg any, args ...any) {
+	this.Notic_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Noticf(msg string, args ...any) {
+	this.Noticf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Warn(arg any, args ...any) {
+	this.Warn_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Warnf(msg string, args ...any) {
+	this.Warnf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Error(arg any, args ...any) {
+	this.Error_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Errorf(msg string, args ...any) {
+	this.Errorf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Hack(arg any, args ...any) {
+	this.Hack_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Hackf(msg string, args ...any) {
+	this.Hackf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Illeg(arg any, args ...any) {
+	this.Illeg_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Illegf(msg string, args ...any) {
+	this.Illegf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Critical(arg any, args ...any) {
+	this.Critical_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Criticalf(msg string, args ...any) {
+	this.Criticalf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Trace(arg any, args ...any) {
+	this.Trace_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Tracef(msg string, args ...any) {
+	this.Tracef_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Panic(arg any, args ...any) {
+	this.Panic_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Panicf(msg string, args ...any) {
+	this.Panicf_(1, msg, args...)
+}
+
+func (this *S_TeeLogger) Fatal(arg any, args ...any) {
+	this.Fatal_(1, arg, args...)
+}
+
+func (this *S_TeeLogger) Fatalf(msg string, args ...any) {
+	this.Fatalf_(1, msg, args...)
+}
@@ -1,3 +1,11 @@
 module fshttp
 
-go 1.19
+go 1.23
+
+replace fsky.pro => ../../common
+
+replace fsky.pro/fslog => ../../fslog
+
+require fsky.pro/fslog v0.0.0-00010101000000-000000000000
+
+require fsky.pro v0.0.0-00010101000000-000000000000 // indirect
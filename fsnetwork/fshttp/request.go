@@ -10,6 +10,7 @@
 package fshttp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -22,6 +23,8 @@ import (
 	"time"
 	"unicode"
 	"unsafe"
+
+	"fsky.pro/fslog"
 )
 
 // -------------------------------------------------------------------
@@ -231,6 +234,21 @@ func (this *S_Request) RemotePort() int {
 	return port
 }
 
+// 请求的 context，其中带有请求 ID
+func (this *S_Request) Context() context.Context {
+	return this.R.Context()
+}
+
+// 请求 ID
+func (this *S_Request) RequestID() string {
+	return fslog.RequestID(this.R.Context())
+}
+
+// 带有请求 ID 的 logger
+func (this *S_Request) Logger() *fslog.S_FieldLogger {
+	return fslog.FromContext(this.R.Context())
+}
+
 // ---------------------------------------------------------
 // 将 get 请求的参数反序列化到 obj 对象
 // 对象成员的 tag 标记为：urlkey
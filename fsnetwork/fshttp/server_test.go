@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"fsky.pro/fslog"
 )
 
 type Service struct {
@@ -42,3 +45,27 @@ func Test(t *testing.T) {
 		fmt.Println(err)
 	}
 }
+
+func TestRequestID(t *testing.T) {
+	svc := newService()
+	svc.AddHandler("/reqid", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fslog.RequestID(r.Context())))
+	})
+
+	// 自动生成请求 ID
+	w := httptest.NewRecorder()
+	svc.ServeHTTP(w, httptest.NewRequest("GET", "/reqid", nil))
+	reqID := w.Header().Get(RequestIDHeader)
+	if reqID == "" || w.Body.String() != reqID {
+		t.Fatalf("unexpected request id, header=%q, body=%q", reqID, w.Body.String())
+	}
+
+	// 沿用请求中的请求 ID
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/reqid", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	svc.ServeHTTP(w, r)
+	if w.Header().Get(RequestIDHeader) != "req-1" || w.Body.String() != "req-1" {
+		t.Fatalf("unexpected request id, header=%q, body=%q", w.Header().Get(RequestIDHeader), w.Body.String())
+	}
+}
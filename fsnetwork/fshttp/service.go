@@ -18,8 +18,13 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+
+	"fsky.pro/fslog"
 )
 
+// 请求 ID 头，请求中带有该头时沿用其值，否则自动生成，并在回复中带回
+const RequestIDHeader = "X-Request-ID"
+
 type I_Handler interface {
 	OnRequest(*S_Request)
 }
@@ -227,6 +232,15 @@ func (this *S_Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r.Header.Add("RemoteHost", host)
 	r.Header.Add("RemotePort", port)
 
+	// 将请求 ID 放入 request 的 context 中
+	// handler 中可以通过 fslog.FromContext(r.Context()) 输出带请求 ID 的日志
+	reqID := r.Header.Get(RequestIDHeader)
+	if reqID == "" {
+		reqID = fslog.NewRequestID()
+	}
+	r = r.WithContext(fslog.ContextWithRequestID(r.Context(), reqID))
+	w.Header().Set(RequestIDHeader, reqID)
+
 	this.locker.RLock()
 	defer this.locker.RUnlock()
 
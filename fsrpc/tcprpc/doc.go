@@ -6,4 +6,10 @@ server
 
 serverproxy
 	表示一个连接的客户端部分实现，serverproxy 中可以注册多个 client 用于寻求服务器的 service 服务
+
+session
+	服务器上的一个连接，server 记录所有会话，可以列举、踢掉会话，或者向会话推送消息
+
+push
+	服务器推送，以特殊序号的 rpc 回复在同一个连接上发送，serverproxy 通过 SetPushHandler 接收
 */
@@ -0,0 +1,170 @@
+package tcprpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type S_Echo struct{}
+
+func (this *S_Echo) Name() string {
+	return "echo"
+}
+
+func (this *S_Echo) Echo(req string, reply *string) error {
+	*reply = req
+	return nil
+}
+
+// 可以“拔线”的转发器：拔线后不再转发任何数据，但连接保持，模拟半开连接
+type s_Relay struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	blocked  bool
+	conns    []net.Conn
+}
+
+func newRelay(t *testing.T, target string) *s_Relay {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := &s_Relay{listener: listener}
+	go relay.serve(target)
+	t.Cleanup(relay.close)
+	return relay
+}
+
+func (this *s_Relay) serve(target string) {
+	for {
+		src, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		dst, err := net.Dial("tcp", target)
+		if err != nil {
+			src.Close()
+			continue
+		}
+		this.mutex.Lock()
+		this.conns = append(this.conns, src, dst)
+		this.mutex.Unlock()
+		go this.pipe(dst, src)
+		go this.pipe(src, dst)
+	}
+}
+
+func (this *s_Relay) pipe(dst, src net.Conn) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if err != nil {
+			return
+		}
+		this.mutex.Lock()
+		blocked := this.blocked
+		this.mutex.Unlock()
+		if !blocked {
+			dst.Write(buf[:n])
+		}
+	}
+}
+
+// 拔线，并且不再接受新连接
+func (this *s_Relay) block() {
+	this.mutex.Lock()
+	this.blocked = true
+	this.mutex.Unlock()
+	this.listener.Close()
+}
+
+func (this *s_Relay) close() {
+	this.listener.Close()
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for _, conn := range this.conns {
+		conn.Close()
+	}
+}
+
+func startEchoServer(t *testing.T) *net.TCPAddr {
+	server := NewServer()
+	server.Register(new(S_Echo))
+	listener, err := server.Listen("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(nil)
+	t.Cleanup(server.Close)
+	return listener.Addr().(*net.TCPAddr)
+}
+
+func waitState(t *testing.T, ch chan *S_RunState, want E_RunState, d time.Duration) *S_RunState {
+	timeout := time.After(d)
+	for {
+		select {
+		case state := <-ch:
+			if state.State == want {
+				return state
+			}
+		case <-timeout:
+			t.Fatalf("wait for state %d timeout", want)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	direct := startEchoServer(t)
+	relay := newRelay(t, direct.String())
+	relayAddr := relay.listener.Addr().(*net.TCPAddr)
+	dead := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+
+	proxy := NewServerProxy("tcp", dead)
+	proxy.SetFailoverAddrs(relayAddr, direct)
+	proxy.SetHeartbeatInfo(&S_HeartbeatInfo{Interval: 1, Timeout: 1, MaxMissed: 2, Request: []byte("hi")})
+	ch := make(chan *S_RunState, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.Run(ctx, ch)
+
+	// 主地址连接失败，切换到第一个备用地址
+	state := waitState(t, ch, DIAL_FAIL, 3*time.Second)
+	if state.RemoteAddr.String() != dead.String() {
+		t.Fatal("first dial should be the primary address:", state.RemoteAddr)
+	}
+	state = waitState(t, ch, DIAL_SUCC, 3*time.Second)
+	if state.RemoteAddr.String() != relayAddr.String() || state.Conn == nil {
+		t.Fatal("should fail over to relay:", state.RemoteAddr)
+	}
+	var reply string
+	if err := proxy.Call("echo.Echo", "x", &reply); err != nil || reply != "x" {
+		t.Fatal(err, reply)
+	}
+
+	// 半开连接由心跳检测到，之后切换到下一个备用地址
+	relay.block()
+	start := time.Now()
+	waitState(t, ch, LOSE_CONN, 8*time.Second)
+	if cost := time.Since(start); cost > 5*time.Second {
+		t.Fatalf("half-open connection detected after %v", cost)
+	}
+	state = waitState(t, ch, DIAL_SUCC, 5*time.Second)
+	if state.RemoteAddr.String() != direct.String() {
+		t.Fatal("should fail over to direct address:", state.RemoteAddr)
+	}
+	if proxy.RemoteAddr().String() != direct.String() {
+		t.Fatal("unexpected remote address:", proxy.RemoteAddr())
+	}
+	if err := proxy.Call("echo.Echo", "y", &reply); err != nil || reply != "y" {
+		t.Fatal(err, reply)
+	}
+
+	cancel()
+	waitState(t, ch, LOSE_CONN, 3*time.Second)
+	if proxy.Connected() {
+		t.Fatal("proxy should be disconnected after Run returns")
+	}
+}
@@ -11,7 +11,12 @@ package tcprpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"net/rpc"
+	"sync"
 	"time"
 
 	"fsky.pro/fsrpcs"
@@ -42,24 +47,41 @@ func (this *s_HeartbeatService) Hello(req []byte, reply *[]byte) error {
 // -------------------------------------------------------------------
 // 心跳客户端
 // -------------------------------------------------------------------
+// 心跳超时
+var ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+
 // 心跳参数
 type S_HeartbeatInfo struct {
-	Interval int         // 发送心跳包时间间隔（秒）
-	Request  []byte      // 心跳表内容
-	Respond  chan []byte // 心跳回复内容
+	Interval  int         // 发送心跳包时间间隔（秒）
+	Timeout   int         // 等待心跳回复的超时时间（秒），小于 1 则为 3 秒
+	MaxMissed int         // 连续多少次心跳失败视为与服务器失去连接，小于 1 则为 3 次
+	Request   []byte      // 心跳表内容
+	Respond   chan []byte // 心跳回复内容
 }
 
 func NewHeartbeatInfo() *S_HeartbeatInfo {
 	return &S_HeartbeatInfo{
-		Interval: 10,
-		Request:  []byte("hello!"),
-		Respond:  nil,
+		Interval:  10,
+		Timeout:   3,
+		MaxMissed: 3,
+		Request:   []byte("hello!"),
+		Respond:   nil,
+	}
+}
+
+// 是否是连接错误，连接错误不必等待心跳失败次数达到阈值
+func isConnError(err error) bool {
+	if err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
 	}
+	_, ok := err.(net.Error)
+	return ok
 }
 
 // ---------------------------------------------------------
 type s_HeartbeatClient struct {
 	*fsrpcs.S_Client
+	sync.Mutex
 	info *S_HeartbeatInfo
 }
 
@@ -74,36 +96,69 @@ func (this *s_HeartbeatClient) updateInfo(info *S_HeartbeatInfo) {
 	if info.Interval < 1 {
 		info.Interval = 1
 	}
+	if info.Timeout < 1 {
+		info.Timeout = 3
+	}
+	if info.MaxMissed < 1 {
+		info.MaxMissed = 3
+	}
+	this.Lock()
 	this.info = info
+	this.Unlock()
+}
+
+func (this *s_HeartbeatClient) getInfo() *S_HeartbeatInfo {
+	this.Lock()
+	defer this.Unlock()
+	return this.info
 }
 
-func (this *s_HeartbeatClient) hello() error {
+func (this *s_HeartbeatClient) hello(info *S_HeartbeatInfo) error {
 	var reply []byte
-	err := this.Call("Hello", this.info.Request, &reply)
-	if err == nil && this.info.Respond != nil {
+	call := this.Go2("Hello", info.Request, &reply)
+	select {
+	case <-call.Done:
+	case <-time.After(time.Second * time.Duration(info.Timeout)):
+		return ErrHeartbeatTimeout
+	}
+	if call.Error == nil && info.Respond != nil {
 		select {
 		case <-time.After(time.Second * 2):
 			break
-		case this.info.Respond <- reply:
+		case info.Respond <- reply:
 			break
 		}
 	}
-	return err
+	return call.Error
 }
 
-func (this *s_HeartbeatClient) loopSend(ctx context.Context, conn *net.TCPConn) {
-	interval := time.Second * time.Duration(this.info.Interval)
-	//outtime := time.Now().Add(interval).Add(time.Second * 3)
+// 循环发送心跳，直到 ctx 结束或者判定与服务器失去连接，返回失去连接的原因
+// 每次心跳成功后延后 conn 的读超时，半开的连接在连续 MaxMissed 个心跳周期没有回复后读取失败
+func (this *s_HeartbeatClient) loopSend(ctx context.Context, conn *net.TCPConn) error {
+	deadline := func(info *S_HeartbeatInfo) time.Time {
+		return time.Now().Add(time.Second * time.Duration(info.Interval*info.MaxMissed+info.Timeout))
+	}
+	conn.SetReadDeadline(deadline(this.getInfo()))
+	missed := 0
 	for {
+		info := this.getInfo()
+		err := this.hello(info)
+		if err == nil {
+			missed = 0
+			conn.SetReadDeadline(deadline(info))
+		} else if isConnError(err) {
+			return err
+		} else {
+			missed++
+			if missed >= info.MaxMissed {
+				return fmt.Errorf("missed %d heartbeats: %v", missed, err)
+			}
+		}
+
 		select {
 		case <-ctx.Done():
-			return
-		default:
-			//conn.SetReadDeadline(outtime)
-			if this.hello() != nil {
-				return
-			}
-			time.Sleep(interval)
+			return ctx.Err()
+		case <-time.After(time.Second * time.Duration(info.Interval)):
 		}
 	}
 }
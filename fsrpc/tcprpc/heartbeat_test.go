@@ -0,0 +1,150 @@
+package tcprpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 不回复的心跳
+var errNoReply = errors.New("no reply")
+
+// 依次以 errs 作为心跳调用结果的代理，errs 用完后心跳都成功
+type s_FakeProxy struct {
+	mutex sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (this *s_FakeProxy) Call(method string, arg interface{}, reply interface{}) error {
+	call := <-this.Go(method, arg, reply, nil).Done
+	return call.Error
+}
+
+func (this *s_FakeProxy) Go(method string, arg interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
+	this.mutex.Lock()
+	var err error
+	if this.calls < len(this.errs) {
+		err = this.errs[this.calls]
+	}
+	this.calls++
+	this.mutex.Unlock()
+
+	call := &rpc.Call{ServiceMethod: method, Args: arg, Reply: reply, Done: make(chan *rpc.Call, 1)}
+	if err != errNoReply {
+		call.Error = err
+		call.Done <- call
+	}
+	return call
+}
+
+func (this *s_FakeProxy) count() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.calls
+}
+
+// 本地回环上的一对 TCP 连接
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	lis, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	client, err := net.DialTCP("tcp", nil, lis.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := lis.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func newTestHeartbeat(errs []error, info *S_HeartbeatInfo) (*s_HeartbeatClient, *s_FakeProxy) {
+	proxy := &s_FakeProxy{errs: errs}
+	heartbeat := newHeartbeatClient(proxy)
+	heartbeat.updateInfo(info)
+	return heartbeat, proxy
+}
+
+// 连续失败 MaxMissed 次才判定失去连接，中间成功一次则重新计数
+func TestHeartbeatMissed(t *testing.T) {
+	busy := errors.New("busy")
+	heartbeat, proxy := newTestHeartbeat([]error{busy, nil, busy, busy},
+		&S_HeartbeatInfo{Interval: 1, Timeout: 1, MaxMissed: 2})
+	conn, _ := tcpPair(t)
+	err := heartbeat.loopSend(context.Background(), conn)
+	if err == nil || !strings.Contains(err.Error(), "missed 2 heartbeats") {
+		t.Fatal("unexpected error:", err)
+	}
+	if n := proxy.count(); n != 4 {
+		t.Fatalf("sent %d heartbeats, expect 4", n)
+	}
+}
+
+// 连接错误立即判定失去连接
+func TestHeartbeatConnError(t *testing.T) {
+	heartbeat, proxy := newTestHeartbeat([]error{rpc.ErrShutdown},
+		&S_HeartbeatInfo{Interval: 1, Timeout: 1, MaxMissed: 3})
+	conn, _ := tcpPair(t)
+	if err := heartbeat.loopSend(context.Background(), conn); err != rpc.ErrShutdown {
+		t.Fatal("expect rpc.ErrShutdown, got", err)
+	}
+	if n := proxy.count(); n != 1 {
+		t.Fatalf("sent %d heartbeats, expect 1", n)
+	}
+}
+
+func TestHeartbeatCancel(t *testing.T) {
+	heartbeat, _ := newTestHeartbeat(nil, &S_HeartbeatInfo{Interval: 1, Timeout: 1, MaxMissed: 3})
+	conn, _ := tcpPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := heartbeat.loopSend(ctx, conn); err != context.DeadlineExceeded {
+		t.Fatal("expect context.DeadlineExceeded, got", err)
+	}
+}
+
+// 心跳没有回复时，连接的读超时先于心跳失败次数到期，半开连接上的读取会失败
+func TestHeartbeatReadDeadline(t *testing.T) {
+	heartbeat, _ := newTestHeartbeat([]error{nil, errNoReply, errNoReply, errNoReply},
+		&S_HeartbeatInfo{Interval: 1, Timeout: 1, MaxMissed: 2})
+	conn, _ := tcpPair(t)
+
+	readErr := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		buf := make([]byte, 1)
+		_, err := conn.Read(buf)
+		readErr <- err
+	}()
+	loopErr := heartbeat.loopSend(context.Background(), conn)
+	if loopErr == nil || !strings.Contains(loopErr.Error(), ErrHeartbeatTimeout.Error()) {
+		t.Fatal("unexpected error:", loopErr)
+	}
+
+	select {
+	case err := <-readErr:
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatal("expect read timeout, got", err)
+		}
+		// 最后一次成功的心跳后 Interval*MaxMissed+Timeout 秒
+		if cost := time.Since(start); cost < 2*time.Second {
+			t.Fatalf("read deadline expired after %v", cost)
+		}
+	default:
+		t.Fatal("read deadline is not expired before heartbeats are missed")
+	}
+}
@@ -0,0 +1,70 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: server push
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package tcprpc
+
+import (
+	"math"
+	"net/rpc"
+)
+
+// -------------------------------------------------------------------
+// 服务器推送
+// 推送消息以 rpc 回复的形式在同一个连接上发送：
+//
+//	回复头的 Seq 为 PushSeq，ServiceMethod 为推送主题，回复体为推送内容
+//
+// 客户端的请求序号不会到达 PushSeq，不支持推送的客户端会把它当作未知回复丢弃
+// -------------------------------------------------------------------
+const PushSeq uint64 = math.MaxUint64
+
+// 推送处理函数，decode 将推送内容解码到指针参数中，不调用则丢弃推送内容
+// 丢弃推送内容时以 nil 调用编码器的 ReadResponseBody，自定义编码器必须支持（见 I_TcpClientCodec）
+// 处理函数在连接的读取协程中调用，不能阻塞，耗时的处理应该另起协程
+type F_PushHandler func(topic string, decode func(interface{}) error)
+
+// ---------------------------------------------------------
+// 包装客户端编码器，在读取回复时截取推送消息
+type s_PushClientCodec struct {
+	rpc.ClientCodec
+	proxy *S_ServerProxy
+}
+
+func (this *s_PushClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	for {
+		// gob 不编码零值字段，读取前要清空上一次的推送头
+		*r = rpc.Response{}
+		if err := this.ClientCodec.ReadResponseHeader(r); err != nil {
+			return err
+		}
+		if r.Seq != PushSeq {
+			return nil
+		}
+
+		decoded := false
+		var decodeErr error
+		decode := func(v interface{}) error {
+			if decoded {
+				return decodeErr
+			}
+			decoded = true
+			decodeErr = this.ClientCodec.ReadResponseBody(v)
+			return decodeErr
+		}
+		if handler := this.proxy.pushHandler(); handler != nil {
+			handler(r.ServiceMethod, decode)
+		}
+		// 处理函数解码失败与 net/rpc 解码回复失败一样，不断开连接
+		if !decoded {
+			if err := decode(nil); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -16,6 +16,9 @@ import (
 	"net"
 	"net/rpc"
 	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"fsky.pro/fsrpcs"
 )
@@ -24,12 +27,17 @@ type S_Server struct {
 	*rpc.Server
 	listener  *net.TCPListener
 	heartbeat *s_HeartbeatService
+
+	mutex    sync.RWMutex
+	sessions map[uint64]*S_Session
+	lastID   uint64
 }
 
 func NewServer() *S_Server {
 	server := &S_Server{
 		Server:    rpc.NewServer(),
 		heartbeat: newHeartbeatService(),
+		sessions:  make(map[uint64]*S_Session),
 	}
 	server.Register(server.heartbeat)
 	return server
@@ -66,6 +74,22 @@ func (this *S_Server) Close() {
 	}
 }
 
+// 每个连接对应一个会话，连接断开时移除
+func (this *S_Server) _serveSession(conn *net.TCPConn, codec rpc.ServerCodec) {
+	this.mutex.Lock()
+	this.lastID++
+	session := newSession(this.lastID, conn, codec)
+	this.sessions[session.ID] = session
+	this.mutex.Unlock()
+
+	this.Server.ServeCodec(session.codec)
+
+	atomic.StoreInt32(&session.closed, 1)
+	this.mutex.Lock()
+	delete(this.sessions, session.ID)
+	this.mutex.Unlock()
+}
+
 // 使用 gob 编码
 func (this *S_Server) Serve(ch chan *net.TCPConn) {
 	for {
@@ -74,7 +98,7 @@ func (this *S_Server) Serve(ch chan *net.TCPConn) {
 			if ch != nil {
 				ch <- conn
 			}
-			go this.Server.ServeConn(conn)
+			go this._serveSession(conn, newGobServerCodec(conn))
 		} else {
 			break
 		}
@@ -90,9 +114,58 @@ func (this *S_Server) ServeCodec(codec I_TcpServerCodec, ch chan *net.TCPConn) {
 				ch <- conn
 			}
 			codec.SetConn(conn)
-			go this.Server.ServeCodec(codec)
+			go this._serveSession(conn, codec)
 		} else {
 			break
 		}
 	}
 }
+
+// ---------------------------------------------------------
+// 当前所有会话，按 ID 排序
+func (this *S_Server) Sessions() []*S_Session {
+	this.mutex.RLock()
+	sessions := make([]*S_Session, 0, len(this.sessions))
+	for _, session := range this.sessions {
+		sessions = append(sessions, session)
+	}
+	this.mutex.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// 获取指定会话，不存在则返回 nil
+func (this *S_Server) Session(id uint64) *S_Session {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.sessions[id]
+}
+
+// 断开指定会话
+func (this *S_Server) Kick(id uint64) error {
+	session := this.Session(id)
+	if session == nil {
+		return ErrSessionNotExists
+	}
+	return session.Kick()
+}
+
+// 向指定会话推送消息
+func (this *S_Server) Push(id uint64, topic string, msg interface{}) error {
+	session := this.Session(id)
+	if session == nil {
+		return ErrSessionNotExists
+	}
+	return session.Push(topic, msg)
+}
+
+// 向所有会话推送消息，返回推送成功的会话数
+func (this *S_Server) Broadcast(topic string, msg interface{}) int {
+	count := 0
+	for _, session := range this.Sessions() {
+		if session.Push(topic, msg) == nil {
+			count++
+		}
+	}
+	return count
+}
@@ -15,6 +15,7 @@ import (
 	"math"
 	"net"
 	"net/rpc"
+	"sync"
 	"time"
 
 	"fsky.pro/fsrpcs"
@@ -47,6 +48,7 @@ func _newRunState(raddr *net.TCPAddr) *S_RunState {
 
 func (this *S_RunState) reset(state E_RunState, conn *net.TCPConn, err error) *S_RunState {
 	this.State = state
+	this.Conn = conn
 	this.Error = err
 	return this
 }
@@ -59,22 +61,26 @@ type S_ServerProxy struct {
 	fsrpcs.I_ServiceProxy
 	*rpc.Client
 	codec     I_TcpClientCodec
+	mutex     sync.RWMutex
 	closed    bool
 	connected bool
 
 	netstr    string
-	raddr     *net.TCPAddr
+	raddrs    []*net.TCPAddr // 第一个为主地址，其余为备用地址
+	current   int            // 当前使用的地址下标
 	heartbeat *s_HeartbeatClient
+	onPush    F_PushHandler
 }
 
 func NewServerProxy(netstr string, addr *net.TCPAddr) *S_ServerProxy {
-	return &S_ServerProxy{
+	proxy := &S_ServerProxy{
 		closed:    false,
 		connected: false,
 		netstr:    netstr,
-		raddr:     addr,
-		heartbeat: nil,
+		raddrs:    []*net.TCPAddr{addr},
 	}
+	proxy.heartbeat = newHeartbeatClient(proxy)
+	return proxy
 }
 
 func NewServerProxWithCodec(codec I_TcpClientCodec, netstr string, addr *net.TCPAddr) *S_ServerProxy {
@@ -83,25 +89,102 @@ func NewServerProxWithCodec(codec I_TcpClientCodec, netstr string, addr *net.TCP
 	return proxy
 }
 
+// -------------------------------------------------------------------
+// inner methods
+// -------------------------------------------------------------------
+func (this *S_ServerProxy) _client() *rpc.Client {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	if !this.connected {
+		return nil
+	}
+	return this.Client
+}
+
+func (this *S_ServerProxy) pushHandler() F_PushHandler {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.onPush
+}
+
+func (this *S_ServerProxy) _isClosed() bool {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.closed
+}
+
+// 切换到下一个地址，返回新地址的下标
+func (this *S_ServerProxy) _nextAddr() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.current = (this.current + 1) % len(this.raddrs)
+	return this.current
+}
+
+// 关闭当前连接，但不标记为 closed，Run 会继续重连
+func (this *S_ServerProxy) _disconnect() {
+	this.mutex.Lock()
+	client := this.Client
+	this.Client = nil
+	this.connected = false
+	this.mutex.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
+
 // ---------------------------------------------------------
 // 设置心跳参数，注意：
-//   必须调用 Run() 方法拨号才能触发心跳
+//
+//	必须调用 Run() 方法拨号才能触发心跳
 func (this *S_ServerProxy) SetHeartbeatInfo(info *S_HeartbeatInfo) {
-	if this.heartbeat != nil {
-		this.heartbeat.updateInfo(info)
-	}
+	this.heartbeat.updateInfo(info)
+}
+
+// 设置服务器推送的处理函数（见 S_Session.Push），如：
+//
+//	proxy.SetPushHandler(func(topic string, decode func(interface{}) error) {
+//	    var msg string
+//	    if topic == "notice" && decode(&msg) == nil { ... }
+//	})
+func (this *S_ServerProxy) SetPushHandler(handler F_PushHandler) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.onPush = handler
+}
+
+// 设置备用服务器地址，Run 中连接当前地址失败时依次尝试下一个地址
+func (this *S_ServerProxy) SetFailoverAddrs(addrs ...*net.TCPAddr) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.raddrs = append(this.raddrs[:1:1], addrs...)
+	this.current = 0
+}
+
+// 当前使用的服务器地址
+func (this *S_ServerProxy) RemoteAddr() *net.TCPAddr {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.raddrs[this.current]
+}
+
+// 是否已经连接到服务器
+func (this *S_ServerProxy) Connected() bool {
+	return this._client() != nil
 }
 
 // ---------------------------------------------------------
 func (this *S_ServerProxy) Call(method string, arg interface{}, reply interface{}) error {
-	if !this.connected || this.Client == nil {
+	client := this._client()
+	if client == nil {
 		return fmt.Errorf("call service method %q fail, client hasn't connect to server yet.", method)
 	}
-	return this.Client.Call(method, arg, reply)
+	return client.Call(method, arg, reply)
 }
 
 func (this *S_ServerProxy) Go(method string, arg interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
-	if !this.connected || this.Client == nil {
+	client := this._client()
+	if client == nil {
 		call := new(rpc.Call)
 		call.ServiceMethod = method
 		call.Args = arg
@@ -110,75 +193,88 @@ func (this *S_ServerProxy) Go(method string, arg interface{}, reply interface{},
 		call.Done <- call
 		return call
 	}
-	call := this.Client.Go(method, arg, reply, done)
+	call := client.Go(method, arg, reply, done)
 	return call
 }
 
 // ---------------------------------------------------------
+// 连接当前地址的服务器
 func (this *S_ServerProxy) Dial() (*net.TCPConn, error) {
-	conn, err := net.DialTCP(this.netstr, nil, this.raddr)
+	raddr := this.RemoteAddr()
+	conn, err := net.DialTCP(this.netstr, nil, raddr)
 	if err != nil {
-		return nil, fmt.Errorf("dial server fail: %v", err)
+		return nil, fmt.Errorf("dial server(%v) fail: %v", raddr, err)
 	}
+	var codec rpc.ClientCodec
 	if this.codec != nil {
 		this.codec.SetConn(conn)
-		this.Client = rpc.NewClientWithCodec(this.codec)
+		codec = this.codec
 	} else {
-		this.Client = rpc.NewClient(conn)
+		codec = newGobClientCodec(conn)
 	}
+	client := rpc.NewClientWithCodec(&s_PushClientCodec{codec, this})
+	this.mutex.Lock()
+	this.Client = client
+	this.connected = true
+	this.mutex.Unlock()
 	return conn, nil
 }
 
 // 会创建心跳，自动重连
-// <-ch 为 1 表示连接成功；为 0 表示断开连接
+// 心跳连续失败 MaxMissed 次或者连接出错时，ch 收到 LOSE_CONN 状态，然后重新连接
+// 设置了备用地址（SetFailoverAddrs）时，连接失败会依次尝试下一个地址，所有地址都失败后再等待重试
 func (this *S_ServerProxy) Run(ctx context.Context, ch chan *S_RunState) {
+	this.mutex.Lock()
 	this.closed = false
+	this.mutex.Unlock()
 	defer this.Close()
-	this.heartbeat = newHeartbeatClient(this)
 
-	runState := _newRunState(this.raddr)
+	notify := func(raddr *net.TCPAddr, state E_RunState, conn *net.TCPConn, err error) {
+		if ch != nil {
+			ch <- _newRunState(raddr).reset(state, conn, err)
+		}
+	}
+
 	const maxInterval = 10
 	interval := 1
 	for {
-		select {
-		case <-ctx.Done():
-			this.Close()
+		if this._isClosed() || ctx.Err() != nil {
 			return
-		default:
-			if this.closed {
-				return
-			}
-			if ch != nil {
-				ch <- runState.reset(TRY_DIAL, nil, nil)
+		}
+		raddr := this.RemoteAddr()
+		notify(raddr, TRY_DIAL, nil, nil)
+		conn, err := this.Dial()
+		if err != nil {
+			notify(raddr, DIAL_FAIL, nil, err)
+			// 所有地址都尝试失败后，再等待
+			if this._nextAddr() != 0 {
+				continue
 			}
-			conn, err := this.Dial()
-			if err != nil {
-				if ch != nil {
-					ch <- runState.reset(DIAL_FAIL, conn, err)
-				}
-				time.Sleep(time.Second * time.Duration(interval))
-				interval = int(math.Min(float64(interval+1), float64(maxInterval)))
-			} else {
-				this.connected = true
-				interval = 1
-				if ch != nil {
-					ch <- runState.reset(DIAL_SUCC, conn, nil)
-				}
-				this.heartbeat.loopSend(ctx, conn)
-				this.connected = false
-				if ch != nil {
-					ch <- runState.reset(LOSE_CONN, conn, nil)
-				}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second * time.Duration(interval)):
 			}
+			interval = int(math.Min(float64(interval+1), float64(maxInterval)))
+			continue
 		}
+
+		interval = 1
+		notify(raddr, DIAL_SUCC, conn, nil)
+		err = this.heartbeat.loopSend(ctx, conn)
+		this._disconnect()
+		notify(raddr, LOSE_CONN, conn, err)
 	}
 }
 
 func (this *S_ServerProxy) Close() error {
+	this.mutex.Lock()
+	client := this.Client
 	this.closed = true
 	this.connected = false
-	if this.Client != nil {
-		return this.Client.Close()
+	this.mutex.Unlock()
+	if client != nil {
+		return client.Close()
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: server side session
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package tcprpc
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrSessionClosed = errors.New("session is closed")
+var ErrSessionNotExists = errors.New("session is not exists")
+
+// -------------------------------------------------------------------
+// 服务方法的请求参数实现该接口时，服务器在解码参数后调用 SetSession，
+// 服务方法以此得知调用者的会话，以便之后向其推送消息，如：
+//
+//	type S_LoginArg struct {
+//	    Name    string
+//	    session *tcprpc.S_Session // 不导出，不参与编码
+//	}
+//	func (this *S_LoginArg) SetSession(s *tcprpc.S_Session) { this.session = s }
+//
+// 注意：参数类型至少要有一个导出字段，否则 gob 无法编码
+// -------------------------------------------------------------------
+type I_SessionArg interface {
+	SetSession(*S_Session)
+}
+
+// -------------------------------------------------------------------
+// S_Session
+// 服务器上的一个客户端连接
+// -------------------------------------------------------------------
+type S_Session struct {
+	ID          uint64
+	RemoteAddr  net.Addr
+	ConnectTime time.Time
+
+	conn          *net.TCPConn
+	codec         *s_SessionCodec
+	lastHeartbeat int64 // UnixNano
+	closed        int32
+}
+
+func newSession(id uint64, conn *net.TCPConn, codec rpc.ServerCodec) *S_Session {
+	session := &S_Session{
+		ID:          id,
+		RemoteAddr:  conn.RemoteAddr(),
+		ConnectTime: time.Now(),
+		conn:        conn,
+	}
+	session.codec = &s_SessionCodec{ServerCodec: codec, session: session}
+	return session
+}
+
+// 最后一次收到心跳的时间，没有收到过心跳则返回零值
+func (this *S_Session) LastHeartbeat() time.Time {
+	nano := atomic.LoadInt64(&this.lastHeartbeat)
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// 会话是否已经断开
+func (this *S_Session) Closed() bool {
+	return atomic.LoadInt32(&this.closed) != 0
+}
+
+// 向客户端推送消息，客户端通过 S_ServerProxy.SetPushHandler 接收
+func (this *S_Session) Push(topic string, msg interface{}) error {
+	if this.Closed() {
+		return ErrSessionClosed
+	}
+	return this.codec.push(topic, msg)
+}
+
+// 断开会话
+func (this *S_Session) Kick() error {
+	return this.conn.Close()
+}
+
+// ---------------------------------------------------------
+// 包装服务器编码器：
+//
+//	记录心跳时间；给实现 I_SessionArg 的参数设置会话；
+//	回复和推送共用一把写锁，以免交错写入
+type s_SessionCodec struct {
+	rpc.ServerCodec
+	session  *S_Session
+	sendLock sync.Mutex
+}
+
+func (this *s_SessionCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := this.ServerCodec.ReadRequestHeader(r)
+	if err == nil && r.ServiceMethod == _heartbeatServiceName+".Hello" {
+		atomic.StoreInt64(&this.session.lastHeartbeat, time.Now().UnixNano())
+	}
+	return err
+}
+
+func (this *s_SessionCodec) ReadRequestBody(body interface{}) error {
+	err := this.ServerCodec.ReadRequestBody(body)
+	if err == nil {
+		if arg, ok := body.(I_SessionArg); ok {
+			arg.SetSession(this.session)
+		}
+	}
+	return err
+}
+
+func (this *s_SessionCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	this.sendLock.Lock()
+	defer this.sendLock.Unlock()
+	return this.ServerCodec.WriteResponse(r, body)
+}
+
+func (this *s_SessionCodec) push(topic string, msg interface{}) error {
+	return this.WriteResponse(&rpc.Response{ServiceMethod: topic, Seq: PushSeq}, msg)
+}
@@ -0,0 +1,147 @@
+package tcprpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+type S_LoginArg struct {
+	Name    string
+	session *S_Session
+}
+
+func (this *S_LoginArg) SetSession(session *S_Session) {
+	this.session = session
+}
+
+type S_Notice struct {
+	Text string
+	N    int
+}
+
+type S_Login struct{}
+
+func (this *S_Login) Name() string {
+	return "login"
+}
+
+func (this *S_Login) Login(arg *S_LoginArg, reply *uint64) error {
+	*reply = arg.session.ID
+	go arg.session.Push("welcome", S_Notice{"hi " + arg.Name, 1})
+	return nil
+}
+
+func waitCond(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait for %s timeout", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startLoginServer(t *testing.T) (*S_Server, *net.TCPAddr) {
+	server := NewServer()
+	server.Register(new(S_Login))
+	listener, err := server.Listen("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(nil)
+	t.Cleanup(server.Close)
+	return server, listener.Addr().(*net.TCPAddr)
+}
+
+func TestSessions(t *testing.T) {
+	server, addr := startLoginServer(t)
+	proxy := NewServerProxy("tcp", addr)
+	proxy.SetHeartbeatInfo(&S_HeartbeatInfo{Interval: 1, Request: []byte("hi")})
+	pushes := make(chan S_Notice, 64)
+	proxy.SetPushHandler(func(topic string, decode func(interface{}) error) {
+		switch topic {
+		case "bad":
+			var i int
+			if decode(&i) == nil {
+				t.Error("decode push of wrong type should fail")
+			}
+			return
+		case "skip":
+			return
+		}
+		var notice S_Notice
+		if err := decode(&notice); err != nil {
+			t.Error(err)
+		}
+		pushes <- notice
+	})
+	ch := make(chan *S_RunState, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.Run(ctx, ch)
+	waitState(t, ch, DIAL_SUCC, 3*time.Second)
+
+	// 服务方法通过 I_SessionArg 得到调用者的会话，并向其推送
+	var id uint64
+	if err := proxy.Call("login.Login", &S_LoginArg{Name: "bob"}, &id); err != nil || id == 0 {
+		t.Fatal(err, id)
+	}
+	if notice := <-pushes; notice.Text != "hi bob" {
+		t.Fatal(notice)
+	}
+
+	sessions := server.Sessions()
+	if len(sessions) != 1 || sessions[0].ID != id || server.Session(id) != sessions[0] {
+		t.Fatal("unexpected sessions:", sessions)
+	}
+	session := sessions[0]
+	waitCond(t, "heartbeat", func() bool { return !session.LastHeartbeat().IsZero() })
+
+	// 未解码、解码失败的推送不影响之后的推送和调用
+	if err := server.Push(id, "skip", S_Notice{"x", 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Push(id, "bad", S_Notice{"x", 2}); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Broadcast("all", S_Notice{"everyone", 3}); n != 1 {
+		t.Fatal("broadcast to", n, "sessions")
+	}
+	if notice := <-pushes; notice.Text != "everyone" || notice.N != 3 {
+		t.Fatal(notice)
+	}
+	for i := 0; i < 20; i++ {
+		var id2 uint64
+		if err := proxy.Call("login.Login", &S_LoginArg{Name: "x"}, &id2); err != nil || id2 != id {
+			t.Fatal(err, id2)
+		}
+		if notice := <-pushes; notice.Text != "hi x" {
+			t.Fatal(notice)
+		}
+	}
+	if err := server.Push(id+1000, "all", S_Notice{}); err != ErrSessionNotExists {
+		t.Fatal("expect ErrSessionNotExists, got", err)
+	}
+
+	// 踢掉会话后，会话被移除，客户端重连为新会话
+	if err := server.Kick(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, ch, LOSE_CONN, 3*time.Second)
+	waitCond(t, "session removed", func() bool { return server.Session(id) == nil && session.Closed() })
+	if err := server.Kick(id); err != ErrSessionNotExists {
+		t.Fatal("expect ErrSessionNotExists, got", err)
+	}
+	if err := session.Push("all", S_Notice{}); err != ErrSessionClosed {
+		t.Fatal("expect ErrSessionClosed, got", err)
+	}
+
+	waitState(t, ch, DIAL_SUCC, 5*time.Second)
+	var id3 uint64
+	if err := proxy.Call("login.Login", &S_LoginArg{Name: "again"}, &id3); err != nil || id3 == id {
+		t.Fatal(err, id3)
+	}
+	<-pushes
+}
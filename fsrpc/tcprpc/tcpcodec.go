@@ -10,6 +10,9 @@
 package tcprpc
 
 import (
+	"bufio"
+	"encoding/gob"
+	"io"
 	"net"
 	"net/rpc"
 )
@@ -28,8 +31,106 @@ type I_TcpServerCodec interface {
 // ClientCodec
 // 如果自定义编码器，则需要对客户端编码器实现该接口，并对 SetConn 中拿到的 conn 进行流操作
 // 可以参考：rpc.gobClientCodec
+// 注意：ReadResponseBody 必须支持 nil 参数，即读出并丢弃回复内容
+// 服务器推送（见 push.go）没有被处理函数解码时，以 nil 调用 ReadResponseBody 丢弃推送内容
 // -------------------------------------------------------------------
 type I_TcpClientCodec interface {
 	rpc.ClientCodec
 	SetConn(*net.TCPConn)
 }
+
+// -------------------------------------------------------------------
+// gob 编码器，与 net/rpc 默认使用的编码器一致
+// net/rpc 的 gob 编码器不导出，这里实现一份，以便包装成会话和推送编码器
+// -------------------------------------------------------------------
+type s_GobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *s_GobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &s_GobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (this *s_GobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return this.dec.Decode(r)
+}
+
+func (this *s_GobServerCodec) ReadRequestBody(body interface{}) error {
+	return this.dec.Decode(body)
+}
+
+func (this *s_GobServerCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = this.enc.Encode(r); err != nil {
+		if this.encBuf.Flush() == nil {
+			// gob 无法编码回复头，不应该出现这种情况，关闭连接
+			this.Close()
+		}
+		return
+	}
+	if err = this.enc.Encode(body); err != nil {
+		if this.encBuf.Flush() == nil {
+			// 无法编码回复内容，关闭连接
+			this.Close()
+		}
+		return
+	}
+	return this.encBuf.Flush()
+}
+
+func (this *s_GobServerCodec) Close() error {
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+	return this.rwc.Close()
+}
+
+// ---------------------------------------------------------
+type s_GobClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func newGobClientCodec(conn io.ReadWriteCloser) *s_GobClientCodec {
+	buf := bufio.NewWriter(conn)
+	return &s_GobClientCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (this *s_GobClientCodec) WriteRequest(r *rpc.Request, body interface{}) (err error) {
+	if err = this.enc.Encode(r); err != nil {
+		return
+	}
+	if err = this.enc.Encode(body); err != nil {
+		return
+	}
+	return this.encBuf.Flush()
+}
+
+func (this *s_GobClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return this.dec.Decode(r)
+}
+
+func (this *s_GobClientCodec) ReadResponseBody(body interface{}) error {
+	return this.dec.Decode(body)
+}
+
+func (this *s_GobClientCodec) Close() error {
+	return this.rwc.Close()
+}
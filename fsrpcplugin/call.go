@@ -0,0 +1,28 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: typed plugin call
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package fsrpcplugin
+
+import "context"
+
+// 以类型化的参数和回复调用插件方法，如：
+//
+//	sum, err := fsrpcplugin.Call[[2]int, int](client, "Arith.Add", [2]int{1, 2})
+func Call[A, R any](client *S_Client, method string, arg A) (R, error) {
+	var reply R
+	err := client.Call(method, arg, &reply)
+	return reply, err
+}
+
+// 与 Call 相同，ctx 结束时不再等待回复
+func CallContext[A, R any](ctx context.Context, client *S_Client, method string, arg A) (R, error) {
+	var reply R
+	err := client.CallContext(ctx, method, arg, &reply)
+	return reply, err
+}
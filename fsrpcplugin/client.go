@@ -19,6 +19,7 @@ import (
 	"net/rpc"
 	"os"
 	"os/exec"
+	"sync"
 	"time"
 
 	"fsky.pro/fslog"
@@ -30,21 +31,56 @@ import (
 type S_ClientConfig struct {
 	SocketFile  string         // unix socket file
 	Logger      fslog.I_Logger // logger
-	Cmd         *exec.Cmd      // start plugin server command
+	Cmd         *exec.Cmd      // start plugin server command，作为模板，每次启动插件时复制一份
 	DialTimeout time.Duration  // 插件响应超时时间
+
+	// 重启策略
+	MaxRestarts   int           // 插件崩溃后最多重启的次数，0 表示不重启，小于 0 表示不限制
+	RestartWindow time.Duration // 插件就绪后稳定运行超过该时间，重启次数清零，默认 1 分钟
+	MinBackoff    time.Duration // 第一次重启前的等待时间，之后每次翻倍，默认 200 毫秒
+	MaxBackoff    time.Duration // 重启前最长的等待时间，默认 30 秒
+
+	// 健康检查
+	HealthCheckInterval time.Duration // 健康检查间隔，0 表示不检查
+	HealthCheckTimeout  time.Duration // 健康检查超时，默认 3 秒
+	HealthCheckFails    int           // 连续失败多少次后杀掉插件（之后按重启策略重启），默认 3 次
+
+	// 停止插件（Stop）时：先请求插件退出，StopTimeout 后发送 SIGTERM，再过 TermTimeout 后 SIGKILL
+	StopTimeout time.Duration // 默认 5 秒
+	TermTimeout time.Duration // 默认 3 秒
+
+	// 插件兼容检查（见 protocol.go）
+	RequiredCapabilities []string                  // 插件必须声明的能力
+	CheckPlugin          func(*S_PluginInfo) error // 自定义检查，返回错误则拒绝插件
+
+	// 插件状态改变时回调，在内部协程中调用，不能阻塞
+	OnStateChange func(state T_State, err error)
 }
 
 // -----------------------------------------------------------------------------
 // client
 // -----------------------------------------------------------------------------
 type S_Client struct {
+	conf *S_ClientConfig
+
+	mutex     sync.Mutex
 	rpcClient *rpc.Client
-	conf      *S_ClientConfig
 	process   *os.Process
+	exited    chan struct{} // 当前插件进程结束时关闭
+	state     T_State
+	stopping  bool
+	stopCh    chan struct{}
+	restarts  int       // 当前重启次数
+	readyTime time.Time // 最近一次就绪的时间
+	info      *S_PluginInfo
 }
 
 func NewClient(cfg *S_ClientConfig) (*S_Client, error) {
-	client := &S_Client{conf: cfg}
+	client := &S_Client{
+		conf:   cfg,
+		state:  StateStopped,
+		stopCh: make(chan struct{}),
+	}
 	if cfg.SocketFile == "" {
 		return nil, errors.New("unix socket file must be indicated")
 	}
@@ -59,6 +95,30 @@ func NewClient(cfg *S_ClientConfig) (*S_Client, error) {
 	} else if cfg.DialTimeout < time.Second {
 		cfg.DialTimeout = time.Second
 	}
+	if cfg.RestartWindow <= 0 {
+		cfg.RestartWindow = time.Minute
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Millisecond * 200
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = time.Second * 30
+		if cfg.MaxBackoff < cfg.MinBackoff {
+			cfg.MaxBackoff = cfg.MinBackoff
+		}
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = time.Second * 5
+	}
+	if cfg.TermTimeout <= 0 {
+		cfg.TermTimeout = time.Second * 3
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = time.Second * 3
+	}
+	if cfg.HealthCheckFails <= 0 {
+		cfg.HealthCheckFails = 3
+	}
 	return client, nil
 }
 
@@ -78,86 +138,267 @@ func (this *S_Client) pipout(r io.Reader) {
 		} else if err != nil {
 			return
 		}
+		msg = append(msg, line...)
 		if isPrefix {
-			msg = append(msg, line...)
 			continue
 		}
 
 		level := "info"
-		segs := bytes.SplitN(msg, []byte(">"), 7)
+		segs := bytes.SplitN(msg, []byte(">"), 2)
 		if len(segs) > 1 {
 			level = string(segs[0])
 			msg = segs[1]
 		}
 		msg = bytes.ReplaceAll(msg, []byte{1}, []byte{'\n'})
-		this.conf.Logger.Direct(level, msg)
+		this.conf.Logger.Direct(fslog.Lv(level), string(msg))
 		msg = []byte{}
 	}
 }
 
+// 以配置中的命令为模板新建命令，exec.Cmd 不能重复启动
+func (this *S_Client) newCmd() (*exec.Cmd, error) {
+	tmpl := this.conf.Cmd
+	if tmpl.Err != nil {
+		return nil, tmpl.Err
+	}
+	env := tmpl.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(append([]string{}, env...), fmt.Sprintf("GO_PLUGIN_UNIX_SOCKET_FILE=%s", this.conf.SocketFile))
+	return &exec.Cmd{
+		Path:        tmpl.Path,
+		Args:        append([]string{}, tmpl.Args...),
+		Env:         env,
+		Dir:         tmpl.Dir,
+		ExtraFiles:  tmpl.ExtraFiles,
+		SysProcAttr: tmpl.SysProcAttr,
+	}, nil
+}
+
+// 连接插件，直到成功、超时或者插件进程结束
+func (this *S_Client) dial() error {
+	this.mutex.Lock()
+	exited := this.exited
+	this.mutex.Unlock()
+
+	var err error
+	var rpcClient *rpc.Client
+	endTime := time.Now().Add(this.conf.DialTimeout)
+	for {
+		rpcClient, err = rpc.Dial("unix", this.conf.SocketFile)
+		if err == nil {
+			break
+		}
+		if time.Now().After(endTime) {
+			return fmt.Errorf("dial to unix file %q fail, %v", this.conf.SocketFile, err)
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("dial to unix file %q fail, plugin has exited", this.conf.SocketFile)
+		case <-time.After(time.Millisecond * 20):
+		}
+	}
+
+	info, err := this.handshake(rpcClient)
+	if err != nil {
+		rpcClient.Close()
+		return err
+	}
+
+	this.mutex.Lock()
+	if this.exited != exited || this.stopping {
+		this.mutex.Unlock()
+		rpcClient.Close()
+		return fmt.Errorf("dial to unix file %q fail, plugin has exited", this.conf.SocketFile)
+	}
+	old := this.rpcClient
+	this.rpcClient = rpcClient
+	this.info = info
+	this.readyTime = time.Now()
+	this.mutex.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	this.setState(StateReady, nil)
+	if this.conf.HealthCheckInterval > 0 {
+		go this.healthLoop(rpcClient, exited)
+	}
+	return nil
+}
+
 // -------------------------------------------------------------------
 // public
 // -------------------------------------------------------------------
+// 启动插件进程
 func (this *S_Client) Start() error {
-	cmd := this.conf.Cmd
-	cmd.Env = append(cmd.Env, fmt.Sprintf("GO_PLUGIN_UNIX_SOCKET_FILE=%s", this.conf.SocketFile))
+	this.mutex.Lock()
+	stopping := this.stopping
+	this.mutex.Unlock()
+	if stopping {
+		return ErrStopped
+	}
+
+	this.setState(StateStarting, nil)
+	cmd, err := this.newCmd()
+	if err != nil {
+		this.setState(StateCrashed, err)
+		return fmt.Errorf("start plugin fail, %v", err)
+	}
+	// 上次崩溃的插件可能留下 socket 文件，导致新插件监听失败
+	// 只删除没有进程在监听的 socket 文件，不能删掉其他插件正在使用的 socket
+	if err := removeStaleSocket(this.conf.SocketFile); err != nil {
+		this.setState(StateCrashed, err)
+		return fmt.Errorf("start plugin fail, %v", err)
+	}
 
 	cmdStdout, err := cmd.StdoutPipe()
 	if err != nil {
+		this.setState(StateCrashed, err)
 		return fmt.Errorf("get cmd stdout pip fail, %v", err)
 	}
 	cmdStderr, err := cmd.StderrPipe()
 	if err != nil {
+		this.setState(StateCrashed, err)
 		return fmt.Errorf("get cmd stderr pip fail, %v", err)
 	}
 	go this.pipout(cmdStdout)
 	go this.pipout(cmdStderr)
-	//	cmd.Stdout = os.Stdout
-	//	cmd.Stderr = os.Stderr
 
 	// 启动插件
 	if err := cmd.Start(); err != nil {
+		this.setState(StateCrashed, err)
 		return fmt.Errorf("start plugin fail, %v", err)
 	}
+	exited := make(chan struct{})
+	this.mutex.Lock()
+	if this.stopping {
+		// 启动期间调用了 Stop，Stop 看不到这个进程，这里杀掉，避免留下孤儿进程
+		this.mutex.Unlock()
+		cmd.Process.Kill()
+		cmd.Wait()
+		this.setState(StateStopped, nil)
+		return ErrStopped
+	}
 	this.process = cmd.Process
+	this.exited = exited
+	this.mutex.Unlock()
 
 	// 等待插件结束
 	go func() {
-		cmd.Wait()
+		err := cmd.Wait()
+		close(exited)
 		fslog.Infof("plugin %q has exited", cmd.Path)
+		this.onExit(exited, err)
 	}()
 	return nil
 }
 
+// 连接插件并握手，ctx 结束时停止插件
+// 插件不兼容时停止插件，返回的错误可以用 errors.Is(err, ErrIncompatible) 判断
 func (this *S_Client) Dial(ctx context.Context) error {
 	// 外部结束控制
 	go func() {
-		<-ctx.Done()
-		this.Kill()
+		select {
+		case <-ctx.Done():
+			this.Stop()
+		case <-this.stopCh:
+		}
 	}()
+	err := this.dial()
+	if errors.Is(err, ErrIncompatible) {
+		this.refuse(err)
+	}
+	return err
+}
 
-	var err error
-	endTime := time.Now().Add(this.conf.DialTimeout)
-	for {
-		this.rpcClient, err = rpc.Dial("unix", this.conf.SocketFile)
-		if err == nil { return nil }
-		if time.Now().After(endTime) {
-			return fmt.Errorf("dial to unix file %q fail, %v", this.conf.SocketFile, err)
-		}
-		time.Sleep(time.Microsecond * 200)
+// 调用插件方法，reply 必须是指针，也可以使用泛型函数 Call
+func (this *S_Client) Call(method string, arg any, reply any) error {
+	this.mutex.Lock()
+	rpcClient := this.rpcClient
+	this.mutex.Unlock()
+	if rpcClient == nil {
+		return fmt.Errorf("plugin client is not ready")
 	}
+	return rpcClient.Call(method, arg, reply)
 }
 
-func (this *S_Client) Call(method string, arg any, reply *string) error {
-	if this.rpcClient == nil {
+// 调用插件方法，ctx 结束时不再等待回复
+func (this *S_Client) CallContext(ctx context.Context, method string, arg any, reply any) error {
+	this.mutex.Lock()
+	rpcClient := this.rpcClient
+	this.mutex.Unlock()
+	if rpcClient == nil {
 		return fmt.Errorf("plugin client is not ready")
 	}
-	return this.rpcClient.Call(method, arg, reply)
+	call := rpcClient.Go(method, arg, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 握手获得的插件信息，未连接则返回 nil
+func (this *S_Client) PluginInfo() *S_PluginInfo {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.info
+}
+
+// 当前插件状态
+func (this *S_Client) State() T_State {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.state
 }
 
+// 强制杀掉插件进程（SIGKILL），设置了重启策略时，插件会被重启
+// 要结束插件并不再重启，调用 Stop
 func (this *S_Client) Kill() error {
-	if this.process != nil {
-		return this.process.Kill()
+	this.mutex.Lock()
+	process := this.process
+	this.mutex.Unlock()
+	if process != nil {
+		return process.Kill()
 	}
 	return nil
 }
+
+// 停止插件，不再重启，插件进程结束后返回：
+//
+//	先请求插件优雅退出（插件停止接受连接，处理完正在处理的调用后退出）；
+//	StopTimeout 后插件仍未退出则发送 SIGTERM；再过 TermTimeout 仍未退出则 SIGKILL
+func (this *S_Client) Stop() error {
+	this.mutex.Lock()
+	if this.stopping {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.stopping = true
+	close(this.stopCh)
+	process, exited, rpcClient := this.process, this.exited, this.rpcClient
+	this.rpcClient = nil
+	this.mutex.Unlock()
+
+	if process == nil {
+		if rpcClient != nil {
+			rpcClient.Close()
+		}
+		this.setState(StateStopped, nil)
+		return nil
+	}
+	select {
+	case <-exited:
+		if rpcClient != nil {
+			rpcClient.Close()
+		}
+		this.setState(StateStopped, nil)
+		return nil
+	default:
+	}
+	// 进程结束后，onExit 设置为 StateStopped
+	return this.stopProcess(process, exited, rpcClient)
+}
@@ -0,0 +1,101 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: plugin server codec
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+package fsrpcplugin
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+)
+
+var errShuttingDown = errors.New("plugin server is shutting down")
+
+// -------------------------------------------------------------------
+// gob 编码器，与 net/rpc 默认使用的编码器一致
+// net/rpc 的 gob 编码器不导出，这里实现一份，以便统计正在处理的调用
+// -------------------------------------------------------------------
+type s_GobServerCodec struct {
+	rwc       io.ReadWriteCloser
+	dec       *gob.Decoder
+	enc       *gob.Encoder
+	encBuf    *bufio.Writer
+	closeOnce sync.Once // WriteResponse 和 Close 可能在不同协程中关闭连接
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *s_GobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &s_GobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (this *s_GobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return this.dec.Decode(r)
+}
+
+func (this *s_GobServerCodec) ReadRequestBody(body any) error {
+	return this.dec.Decode(body)
+}
+
+func (this *s_GobServerCodec) WriteResponse(r *rpc.Response, body any) (err error) {
+	if err = this.enc.Encode(r); err != nil {
+		if this.encBuf.Flush() == nil {
+			// gob 无法编码回复头，不应该出现这种情况，关闭连接
+			this.Close()
+		}
+		return
+	}
+	if err = this.enc.Encode(body); err != nil {
+		if this.encBuf.Flush() == nil {
+			// 无法编码回复内容，关闭连接
+			this.Close()
+		}
+		return
+	}
+	return this.encBuf.Flush()
+}
+
+func (this *s_GobServerCodec) Close() (err error) {
+	this.closeOnce.Do(func() { err = this.rwc.Close() })
+	return
+}
+
+// -------------------------------------------------------------------
+// 统计正在处理的调用：读到请求头时加一，写回复后减一
+// net/rpc 对每个成功读取的请求头都会写一个回复
+// 开始退出后不再接受新的调用，返回错误让 net/rpc 处理完正在处理的调用后关闭连接，
+// 否则宿主进程不断发来的调用（包括健康检查）会让插件一直无法退出
+// -------------------------------------------------------------------
+type s_CountCodec struct {
+	rpc.ServerCodec
+	server *S_Server
+}
+
+func (this *s_CountCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := this.ServerCodec.ReadRequestHeader(r)
+	if err != nil {
+		return err
+	}
+	if !this.server.beginCall() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+func (this *s_CountCodec) WriteResponse(r *rpc.Response, body any) error {
+	defer this.server.endCall()
+	return this.ServerCodec.WriteResponse(r, body)
+}
@@ -31,8 +31,8 @@ func NewLogger() *S_Logger {
 // -------------------------------------------------------------------
 // private
 // -------------------------------------------------------------------
-func (this *S_Logger) write(t time.Time, lv string, msg []byte) {
-	prefix := []byte(lv + ">")
+func (this *S_Logger) write(t time.Time, lv fslog.T_Level, msg []byte) {
+	prefix := []byte(string(lv) + ">")
 	//msg = bytes.ReplaceAll(msg, []byte{'\n'}, []byte{1})
 	//msg = append(prefix, msg...)
 	os.Stdout.Write(append(prefix, msg...))
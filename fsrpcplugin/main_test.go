@@ -0,0 +1,154 @@
+package fsrpcplugin
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 测试程序设置了该环境变量时作为插件运行：
+//
+//	"1"     声明插件信息的插件
+//	"old"   不声明插件信息的插件
+//	"drain" 退出时最多等待正在处理的调用 300 毫秒
+//	"stuck" Serve 返回后忽略 SIGTERM 并且不退出，只能被 SIGKILL 杀掉
+const _testPluginEnv = "FSRPCPLUGIN_TEST_PLUGIN"
+
+type S_Pair struct {
+	A, B int
+}
+
+type S_Sum struct {
+	Sum int
+	Msg string
+}
+
+type Echo struct{}
+
+func (*Echo) Say(req string, reply *string) error {
+	*reply = "echo:" + req
+	return nil
+}
+
+func (*Echo) Crash(req string, reply *string) error {
+	os.Exit(3)
+	return nil
+}
+
+func (*Echo) Add(p S_Pair, reply *S_Sum) error {
+	*reply = S_Sum{p.A + p.B, "ok"}
+	return nil
+}
+
+func (*Echo) Slow(ms int, reply *int) error {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	*reply = ms
+	return nil
+}
+
+// 插件进程的 main，Serve 优雅返回后写 "<socket>.exited" 文件
+func runTestPlugin(kind string) {
+	svr := NewServer()
+	if err := svr.Register("Echo", new(Echo)); err != nil {
+		panic(err)
+	}
+	if kind != "old" {
+		svr.SetInfo("testplugin", "1.2.0", "echo", "crash")
+	}
+	if kind == "drain" {
+		svr.DrainTimeout = 300 * time.Millisecond
+	}
+	if err := svr.Serve(context.Background()); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	if kind == "stuck" {
+		signal.Ignore(syscall.SIGTERM)
+		select {}
+	}
+	os.WriteFile(os.Getenv("GO_PLUGIN_UNIX_SOCKET_FILE")+".exited", []byte("ok"), 0600)
+}
+
+func TestMain(m *testing.M) {
+	if kind := os.Getenv(_testPluginEnv); kind != "" {
+		runTestPlugin(kind)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// -------------------------------------------------------------------
+// helpers
+// -------------------------------------------------------------------
+// 记录插件状态变化
+type s_StateLog struct {
+	mutex  sync.Mutex
+	states []T_State
+	ch     chan T_State
+}
+
+func (this *s_StateLog) add(state T_State) {
+	this.mutex.Lock()
+	this.states = append(this.states, state)
+	this.mutex.Unlock()
+	this.ch <- state
+}
+
+// 以测试程序自身作为插件创建客户端，测试结束时停止插件
+func newTestClient(t *testing.T, conf *S_ClientConfig, kind string) (*S_Client, *s_StateLog) {
+	sl := &s_StateLog{ch: make(chan T_State, 100)}
+	conf.SocketFile = filepath.Join(t.TempDir(), "p.sock")
+	conf.Logger = NewLogger()
+	conf.Cmd = exec.Command(os.Args[0])
+	conf.Cmd.Env = append(os.Environ(), _testPluginEnv+"="+kind)
+	if conf.OnStateChange == nil {
+		conf.OnStateChange = func(state T_State, err error) { sl.add(state) }
+	}
+	client, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Stop() })
+	return client, sl
+}
+
+// 启动并连接插件
+func startTestClient(t *testing.T, conf *S_ClientConfig) (*S_Client, *s_StateLog) {
+	client, sl := newTestClient(t, conf, "1")
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return client, sl
+}
+
+func waitState(t *testing.T, sl *s_StateLog, want T_State, d time.Duration) {
+	timeout := time.After(d)
+	for {
+		select {
+		case state := <-sl.ch:
+			if state == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("wait for state %s timeout", want)
+		}
+	}
+}
+
+func pluginPid(client *S_Client) int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.process == nil {
+		return 0
+	}
+	return client.process.Pid
+}
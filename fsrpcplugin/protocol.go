@@ -0,0 +1,137 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: plugin protocol and handshake
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 宿主进程连接插件后先握手，获取插件信息（S_PluginInfo）：
+//   Protocol 与宿主进程的 ProtocolVersion 不一致时拒绝插件
+//   宿主进程可以在 S_ClientConfig 中要求插件具备的能力（RequiredCapabilities），
+//   或者设置 CheckPlugin 自行检查插件名称、版本等
+// 被拒绝的插件不会被重启
+
+package fsrpcplugin
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 插件协议版本，宿主进程与插件的协议版本不一致时，宿主进程拒绝插件
+const ProtocolVersion = 1
+
+var ErrIncompatible = errors.New("incompatible plugin")
+
+// 插件信息
+type S_PluginInfo struct {
+	Name         string   // 插件名称
+	Version      string   // 插件版本
+	Protocol     int      // 插件协议版本
+	Capabilities []string // 插件声明的能力
+	Methods      []string // 插件注册的所有方法，格式为 "服务名.方法名"
+}
+
+// 是否声明了指定的能力
+func (this *S_PluginInfo) HasCapability(capability string) bool {
+	for _, c := range this.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+// 检查服务的所有导出方法是否都可以被 net/rpc 调用，返回所有方法名称
+func checkService(name string, rcvr any) ([]string, error) {
+	if !token.IsExported(name) {
+		return nil, fmt.Errorf("register service %q fail, service name must be exported", name)
+	}
+	typ := reflect.TypeOf(rcvr)
+	if typ == nil {
+		return nil, fmt.Errorf("register service %q fail, receiver is nil", name)
+	}
+	methods := []string{}
+	problems := []string{}
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		mtype := method.Type
+		var problem string
+		switch {
+		case mtype.NumIn() != 3:
+			problem = fmt.Sprintf("has %d arguments, needs exactly two", mtype.NumIn()-1)
+		case !isExportedOrBuiltinType(mtype.In(1)):
+			problem = fmt.Sprintf("argument type %s is not exported", mtype.In(1))
+		case mtype.In(2).Kind() != reflect.Pointer:
+			problem = fmt.Sprintf("reply type %s is not a pointer", mtype.In(2))
+		case !isExportedOrBuiltinType(mtype.In(2)):
+			problem = fmt.Sprintf("reply type %s is not exported", mtype.In(2))
+		case mtype.NumOut() != 1 || mtype.Out(0) != typeOfError:
+			problem = "must return exactly one error"
+		}
+		if problem != "" {
+			problems = append(problems, method.Name+" "+problem)
+		} else {
+			methods = append(methods, name+"."+method.Name)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("register service %q fail, invalid methods: %s", name, strings.Join(problems, "; "))
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("register service %q fail, it has no exported methods", name)
+	}
+	return methods, nil
+}
+
+// 与插件握手，检查插件是否兼容
+func (this *S_Client) handshake(rpcClient *rpc.Client) (*S_PluginInfo, error) {
+	info := new(S_PluginInfo)
+	call := rpcClient.Go(_pluginServiceName+".Handshake", ProtocolVersion, info, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-time.After(this.conf.DialTimeout):
+		return nil, errors.New("handshake with plugin timeout")
+	}
+	if call.Error != nil {
+		if _, ok := call.Error.(rpc.ServerError); ok {
+			// 旧版本插件没有握手方法
+			return nil, fmt.Errorf("%w: handshake fail, %v", ErrIncompatible, call.Error)
+		}
+		return nil, fmt.Errorf("handshake with plugin fail, %v", call.Error)
+	}
+	if info.Protocol != ProtocolVersion {
+		return nil, fmt.Errorf("%w: plugin protocol version is %d, host protocol version is %d",
+			ErrIncompatible, info.Protocol, ProtocolVersion)
+	}
+	for _, capability := range this.conf.RequiredCapabilities {
+		if !info.HasCapability(capability) {
+			return nil, fmt.Errorf("%w: plugin %s %s has no capability %q", ErrIncompatible, info.Name, info.Version, capability)
+		}
+	}
+	if this.conf.CheckPlugin != nil {
+		if err := this.conf.CheckPlugin(info); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncompatible, err)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,105 @@
+package fsrpcplugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type s_GoodService struct{}
+
+func (*s_GoodService) Do(arg int, reply *int) error { return nil }
+
+type s_BadService struct{}
+
+func (*s_BadService) Do(arg int, reply *int) error   { return nil }
+func (*s_BadService) Helper() string                 { return "" }
+func (*s_BadService) NoPtr(arg int, reply int) error { return nil }
+
+// Register 时检查方法签名，不符合的方法返回错误
+func TestRegister(t *testing.T) {
+	svr := NewServer()
+	if err := svr.Register("Good", new(s_GoodService)); err != nil {
+		t.Fatal(err)
+	}
+	err := svr.Register("Bad", new(s_BadService))
+	if err == nil || !strings.Contains(err.Error(), "Helper") || !strings.Contains(err.Error(), "NoPtr") {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := svr.Register("good", new(s_GoodService)); err == nil {
+		t.Fatal("unexported service name should be refused")
+	}
+	if err := svr.Register("Nil", nil); err == nil {
+		t.Fatal("nil receiver should be refused")
+	}
+
+	svr.SetInfo("x", "1", "a")
+	info := svr.Info()
+	if info.Protocol != ProtocolVersion || len(info.Methods) != 1 || info.Methods[0] != "Good.Do" || !info.HasCapability("a") {
+		t.Fatal("unexpected info:", info)
+	}
+}
+
+func TestTypedCall(t *testing.T) {
+	client, _ := startTestClient(t, &S_ClientConfig{RequiredCapabilities: []string{"echo"}})
+	sum, err := Call[S_Pair, S_Sum](client, "Echo.Add", S_Pair{2, 3})
+	if err != nil || sum.Sum != 5 || sum.Msg != "ok" {
+		t.Fatal(err, sum)
+	}
+	s, err := CallContext[string, string](context.Background(), client, "Echo.Say", "x")
+	if err != nil || s != "echo:x" {
+		t.Fatal(err, s)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := CallContext[int, int](ctx, client, "Echo.Slow", 300); err != context.DeadlineExceeded {
+		t.Fatal("expect context.DeadlineExceeded, got", err)
+	}
+
+	info := client.PluginInfo()
+	if info.Name != "testplugin" || info.Version != "1.2.0" || !info.HasCapability("crash") {
+		t.Fatal("unexpected plugin info:", info)
+	}
+	if len(info.Methods) != 4 || info.Methods[0] != "Echo.Add" {
+		t.Fatal("unexpected plugin methods:", info.Methods)
+	}
+}
+
+// 不兼容的插件被拒绝，停止并且不再重启
+func TestIncompatible(t *testing.T) {
+	client, sl := newTestClient(t, &S_ClientConfig{RequiredCapabilities: []string{"fly"}, MaxRestarts: -1}, "1")
+	client.Start()
+	if err := client.Dial(context.Background()); !errors.Is(err, ErrIncompatible) {
+		t.Fatal("expect ErrIncompatible, got", err)
+	}
+	waitState(t, sl, StateStopped, 3*time.Second)
+
+	client, sl = newTestClient(t, &S_ClientConfig{MaxRestarts: -1, CheckPlugin: func(info *S_PluginInfo) error {
+		if info.Version < "2" {
+			return errors.New("version too old")
+		}
+		return nil
+	}}, "1")
+	client.Start()
+	err := client.Dial(context.Background())
+	if !errors.Is(err, ErrIncompatible) || !strings.Contains(err.Error(), "version too old") {
+		t.Fatal("expect ErrIncompatible, got", err)
+	}
+	waitState(t, sl, StateStopped, 3*time.Second)
+	time.Sleep(200 * time.Millisecond)
+	if state := client.State(); state != StateStopped {
+		t.Fatal("refused plugin should not be restarted, but is", state)
+	}
+
+	// 没有声明插件信息的插件仍然可以握手
+	client, _ = newTestClient(t, &S_ClientConfig{}, "old")
+	client.Start()
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if info := client.PluginInfo(); info.Name != "" || info.Protocol != ProtocolVersion {
+		t.Fatal("unexpected plugin info:", info)
+	}
+}
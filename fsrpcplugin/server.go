@@ -16,48 +16,226 @@ import (
 	"net"
 	"net/rpc"
 	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
 
 	"fsky.pro/fslog"
 )
 
+// -------------------------------------------------------------------
+// 插件内置服务，供宿主进程做健康检查和握手
+// -------------------------------------------------------------------
+const _pluginServiceName = "PluginService_"
+
+type s_PluginService struct {
+	server *S_Server
+}
+
+func (this *s_PluginService) Ping(req string, reply *string) error {
+	*reply = req
+	return nil
+}
+
+func (this *s_PluginService) Handshake(hostProtocol int, reply *S_PluginInfo) error {
+	*reply = this.server.Info()
+	return nil
+}
+
+// 宿主进程请求插件退出，回复后插件开始优雅退出
+func (this *s_PluginService) Shutdown(req int, reply *int) error {
+	this.server.Shutdown()
+	return nil
+}
+
+// -------------------------------------------------------------------
+// server
+// -------------------------------------------------------------------
 type S_Server struct {
+	rpcServer *rpc.Server
+
+	// 退出时等待正在处理的调用结束的最长时间，默认 5 秒，小于等于 0 表示不限制
+	// 等待期间再次收到 SIGTERM（如宿主进程 Stop 超时后发送的 SIGTERM）时也不再等待
+	DrainTimeout time.Duration
+
+	mutex    sync.Mutex
+	info     S_PluginInfo
+	methods  []string
+	conns    map[net.Conn]struct{}
+	active   int // 正在处理的调用数
+	shutdown chan struct{}
+	once     sync.Once
 }
 
 func NewServer() *S_Server {
-	return &S_Server{}
+	server := &S_Server{
+		rpcServer:    rpc.NewServer(),
+		DrainTimeout: time.Second * 5,
+		conns:        make(map[net.Conn]struct{}),
+		shutdown:     make(chan struct{}),
+	}
+	server.rpcServer.RegisterName(_pluginServiceName, &s_PluginService{server})
+	return server
 }
 
 // -------------------------------------------------------------------
 // private
 // -------------------------------------------------------------------
-func (this *S_Server) serve(ctx context.Context, conn net.Conn) {
-	go rpc.ServeConn(conn)
-	<-ctx.Done()
-	conn.Close()
+func (this *S_Server) serve(conn net.Conn) {
+	this.mutex.Lock()
+	this.conns[conn] = struct{}{}
+	this.mutex.Unlock()
+
+	this.rpcServer.ServeCodec(&s_CountCodec{newGobServerCodec(conn), this})
+
+	this.mutex.Lock()
+	delete(this.conns, conn)
+	this.mutex.Unlock()
+}
+
+// 开始处理调用，已经开始退出则返回 false
+// 在锁内检查并计数，Serve 在开始退出后等待计数归零时不会漏掉正在开始的调用
+func (this *S_Server) beginCall() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	select {
+	case <-this.shutdown:
+		return false
+	default:
+	}
+	this.active++
+	return true
+}
+
+func (this *S_Server) endCall() {
+	this.mutex.Lock()
+	this.active--
+	this.mutex.Unlock()
+}
+
+func (this *S_Server) activeCount() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.active
+}
+
+// 删除上次崩溃留下的 socket 文件
+// 如果文件不是 socket，或者还有进程在监听，则返回错误
+func removeStaleSocket(ufile string) error {
+	info, err := os.Lstat(ufile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%q exists and is not a unix socket file", ufile)
+	}
+	if conn, err := net.DialTimeout("unix", ufile, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket file %q is in use by another process", ufile)
+	}
+	fslog.Infof("remove stale unix socket file %q", ufile)
+	if err := os.Remove(ufile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 // -------------------------------------------------------------------
 // public
 // -------------------------------------------------------------------
+// 注册服务，rcvr 的所有导出方法都必须符合：
+//
+//	func (t *T) MethodName(arg A, reply *R) error
+//
+// 其中 A、R 为导出类型或者内置类型，有不符合的方法则返回错误
+// （net/rpc 只会忽略不符合的方法，宿主进程调用时才发现找不到方法）
 func (this *S_Server) Register(name string, rcvr any) error {
-	return rpc.RegisterName(name, rcvr)
+	methods, err := checkService(name, rcvr)
+	if err != nil {
+		return err
+	}
+	if err = this.rpcServer.RegisterName(name, rcvr); err != nil {
+		return err
+	}
+	this.mutex.Lock()
+	this.methods = append(this.methods, methods...)
+	this.mutex.Unlock()
+	return nil
 }
 
+// 设置插件信息，握手时返回给宿主进程，Protocol 和 Methods 字段自动设置
+func (this *S_Server) SetInfo(name string, version string, capabilities ...string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.info.Name = name
+	this.info.Version = version
+	this.info.Capabilities = capabilities
+}
+
+// 插件信息
+func (this *S_Server) Info() S_PluginInfo {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	info := this.info
+	info.Protocol = ProtocolVersion
+	info.Capabilities = append([]string{}, this.info.Capabilities...)
+	info.Methods = append([]string{}, this.methods...)
+	sort.Strings(info.Methods)
+	return info
+}
+
+// 请求优雅退出：停止接受新连接，已有连接上的新调用被拒绝（连接被关闭），等待正在处理的调用结束后，Serve 返回
+// 宿主进程调用 S_Client.Stop 时，会通过内置服务调用该方法
+func (this *S_Server) Shutdown() {
+	this.once.Do(func() { close(this.shutdown) })
+}
+
+// 监听 GO_PLUGIN_UNIX_SOCKET_FILE 并服务，以下情况优雅退出并返回 nil：
+//
+//	ctx 结束、收到 SIGTERM 或者调用了 Shutdown（包括宿主进程请求退出）
+//
+// 退出时停止接受新连接和新调用，等待正在处理的调用结束（最多 DrainTimeout），关闭所有连接并删除 socket 文件
+// 开始退出后收到 SIGTERM 则不再等待正在处理的调用
+// 插件的 main 函数应该在 Serve 返回后退出进程
 func (this *S_Server) Serve(ctx context.Context) error {
 	ufile := os.Getenv("GO_PLUGIN_UNIX_SOCKET_FILE")
 	if ufile == "" {
 		return fmt.Errorf("unix socket file environment %q is not seted", "GO_PLUGIN_UNIX_SOCKET_FILE")
 	}
+	if err := removeStaleSocket(ufile); err != nil {
+		return fmt.Errorf("listen on unix file %q fail, %v", ufile, err)
+	}
 	ln, err := net.Listen("unix", ufile)
 	if err != nil {
 		return fmt.Errorf("listen on unix file %q fail, %v", ufile, err)
 	}
 
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	done := make(chan struct{})
+	defer close(done)
+	abort := make(chan struct{}) // 开始退出后收到 SIGTERM 时关闭
 	go func() {
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case <-sigCh:
+			fslog.Info("plugin receives SIGTERM, shutdown")
+		case <-this.shutdown:
+		case <-done:
+			return
+		}
+		this.Shutdown()
 		ln.Close()
-		if err = os.Remove(ufile); err != nil {
-			fslog.Errorf("remove unix socket file %q fail, %v", ufile, err)
+		select {
+		case <-sigCh:
+			close(abort)
+		case <-done:
 		}
 	}()
 
@@ -67,9 +245,37 @@ func (this *S_Server) Serve(ctx context.Context) error {
 			if errors.Is(err, net.ErrClosed) {
 				break
 			}
+			ln.Close()
 			return fmt.Errorf("accept connect fail, %v", err)
 		}
-		go this.serve(ctx, conn)
+		go this.serve(conn)
+	}
+
+	// 等待正在处理的调用结束
+	var timeout <-chan time.Time
+	if this.DrainTimeout > 0 {
+		timeout = time.After(this.DrainTimeout)
+	}
+drain:
+	for this.activeCount() > 0 {
+		select {
+		case <-abort:
+			fslog.Warnf("plugin receives SIGTERM, abandon %d in-flight calls", this.activeCount())
+			break drain
+		case <-timeout:
+			fslog.Warnf("wait for in-flight calls timeout, abandon %d calls", this.activeCount())
+			break drain
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	this.mutex.Lock()
+	for conn := range this.conns {
+		conn.Close()
+	}
+	this.mutex.Unlock()
+	// 关闭 listener 时一般已经删除了 socket 文件
+	if err = os.Remove(ufile); err != nil && !os.IsNotExist(err) {
+		fslog.Errorf("remove unix socket file %q fail, %v", ufile, err)
 	}
-	return net.ErrClosed
+	return nil
 }
@@ -0,0 +1,187 @@
+package fsrpcplugin
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func exitedGracefully(client *S_Client) bool {
+	_, err := os.Stat(client.conf.SocketFile + ".exited")
+	return err == nil
+}
+
+// 插件处理完正在处理的调用后退出，并删除 socket 文件
+func TestGracefulStop(t *testing.T) {
+	client, sl := startTestClient(t, &S_ClientConfig{MaxRestarts: -1})
+	done := make(chan error, 1)
+	var reply int
+	go func() { done <- client.Call("Echo.Slow", 300, &reply) }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if err := client.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	cost := time.Since(start)
+	if err := <-done; err != nil || reply != 300 {
+		t.Fatal("in-flight call fail:", err, reply)
+	}
+	if cost < 200*time.Millisecond || cost > 2*time.Second {
+		t.Fatalf("stop plugin after %v", cost)
+	}
+	if !exitedGracefully(client) {
+		t.Fatal("plugin doesn't exit gracefully")
+	}
+	if _, err := os.Stat(client.conf.SocketFile); !os.IsNotExist(err) {
+		t.Fatal("socket file is not removed:", err)
+	}
+	waitState(t, sl, StateStopped, time.Second)
+}
+
+// 宿主进程不断发来调用时，插件仍然能自行退出，不需要 SIGTERM
+func TestGracefulStopUnderLoad(t *testing.T) {
+	client, _ := startTestClient(t, &S_ClientConfig{
+		HealthCheckInterval: 20 * time.Millisecond,
+		StopTimeout:         10 * time.Second,
+	})
+	client.mutex.Lock()
+	rpcClient := client.rpcClient
+	client.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reply int
+			for rpcClient.Call("Echo.Slow", 50, &reply) == nil {
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	client.Stop()
+	if cost := time.Since(start); cost > 3*time.Second {
+		t.Fatalf("stop plugin under load after %v", cost)
+	}
+	if !exitedGracefully(client) {
+		t.Fatal("plugin doesn't exit gracefully")
+	}
+	wg.Wait()
+}
+
+// 插件在 StopTimeout 内没有处理完调用时，宿主进程发送 SIGTERM，插件不再等待，立即退出
+func TestStopAbandonDrain(t *testing.T) {
+	client, _ := startTestClient(t, &S_ClientConfig{
+		StopTimeout: 200 * time.Millisecond,
+		TermTimeout: 5 * time.Second,
+	})
+	testStopHungCall(t, client, 150*time.Millisecond, 1500*time.Millisecond)
+	if !exitedGracefully(client) {
+		t.Fatal("plugin should return from Serve on SIGTERM")
+	}
+}
+
+// 插件等待正在处理的调用最多 DrainTimeout，之后自行退出
+func TestDrainTimeout(t *testing.T) {
+	client, _ := newTestClient(t, &S_ClientConfig{StopTimeout: 5 * time.Second}, "drain")
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	testStopHungCall(t, client, 250*time.Millisecond, 1500*time.Millisecond)
+	if !exitedGracefully(client) {
+		t.Fatal("plugin should return from Serve after DrainTimeout")
+	}
+}
+
+// 插件收到 SIGTERM 后仍未退出时，宿主进程发送 SIGKILL
+func TestStopEscalate(t *testing.T) {
+	client, _ := newTestClient(t, &S_ClientConfig{
+		StopTimeout: 200 * time.Millisecond,
+		TermTimeout: 200 * time.Millisecond,
+	}, "stuck")
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	testStopHungCall(t, client, 350*time.Millisecond, 2*time.Second)
+	if exitedGracefully(client) {
+		t.Fatal("stuck plugin should be killed")
+	}
+}
+
+// 有一个挂起的调用时停止插件，检查停止耗时在 [min, max] 内，并且挂起的调用失败
+func testStopHungCall(t *testing.T, client *S_Client, min, max time.Duration) {
+	done := make(chan error, 1)
+	var reply int
+	go func() { done <- client.Call("Echo.Slow", 10000, &reply) }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	client.Stop()
+	if cost := time.Since(start); cost < min || cost > max {
+		t.Fatalf("stop plugin after %v", cost)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("hung call should fail")
+	}
+}
+
+// Serve 删除上次崩溃留下的 socket 文件，但不删除正在使用的 socket 文件
+func TestStaleSocket(t *testing.T) {
+	client, _ := newTestClient(t, &S_ClientConfig{}, "1")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: client.conf.SocketFile, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(client.conf.SocketFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// 直接启动插件进程，不经过 S_Client.Start 中的清理
+	cmd, _ := client.newCmd()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	var conn net.Conn
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("unix", client.conf.SocketFile); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	cmd2, _ := client.newCmd()
+	if err := cmd2.Run(); err == nil {
+		t.Fatal("second plugin should fail to listen on the socket in use")
+	}
+	if conn, err = net.Dial("unix", client.conf.SocketFile); err != nil {
+		t.Fatal("socket file in use is removed:", err)
+	}
+	conn.Close()
+
+	// 收到 SIGTERM 时优雅退出
+	cmd.Process.Signal(syscall.SIGTERM)
+	cmd.Wait()
+	if !exitedGracefully(client) {
+		t.Fatal("plugin doesn't exit gracefully on SIGTERM")
+	}
+}
@@ -0,0 +1,261 @@
+/**
+@copyright: fantasysky 2016
+@website: https://www.fsky.pro
+@brief: plugin supervisor
+@author: fanky
+@version: 1.0
+@date: 2026-10-18
+**/
+
+// 插件进程监管：
+//   插件崩溃后按 S_ClientConfig 中的重启策略退避重启，并重新连接 unix socket
+//   定时调用插件内置的 PluginService_.Ping 做健康检查，连续失败则杀掉插件，之后按重启策略重启
+//   插件状态可以通过 S_Client.State 获取，或者设置 S_ClientConfig.OnStateChange 监听
+
+package fsrpcplugin
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"syscall"
+	"time"
+
+	"fsky.pro/fslog"
+)
+
+var ErrStopped = errors.New("plugin client has been stopped")
+var errHealthCheckTimeout = errors.New("health check timeout")
+
+// -------------------------------------------------------------------
+// 插件状态
+// -------------------------------------------------------------------
+type T_State int
+
+const (
+	StateStarting T_State = iota // 正在启动
+	StateReady                   // 已经连接，可以调用
+	StateCrashed                 // 插件异常退出或者启动失败
+	StateStopped                 // 已经停止（未启动或者调用了 Stop）
+)
+
+func (this T_State) String() string {
+	switch this {
+	case StateStarting:
+		return "starting"
+	case StateReady:
+		return "ready"
+	case StateCrashed:
+		return "crashed"
+	case StateStopped:
+		return "stopped"
+	}
+	return fmt.Sprintf("T_State(%d)", int(this))
+}
+
+// -------------------------------------------------------------------
+// private
+// -------------------------------------------------------------------
+func (this *S_Client) setState(state T_State, err error) {
+	this.mutex.Lock()
+	if this.state == state && err == nil {
+		this.mutex.Unlock()
+		return
+	}
+	this.state = state
+	this.mutex.Unlock()
+
+	if err != nil {
+		fslog.Warnf("plugin %q is %s: %v", this.conf.Cmd.Path, state, err)
+	} else {
+		fslog.Infof("plugin %q is %s", this.conf.Cmd.Path, state)
+	}
+	if this.conf.OnStateChange != nil {
+		this.conf.OnStateChange(state, err)
+	}
+}
+
+// 插件进程结束
+func (this *S_Client) onExit(exited chan struct{}, err error) {
+	this.mutex.Lock()
+	if this.exited != exited {
+		this.mutex.Unlock()
+		return
+	}
+	rpcClient := this.rpcClient
+	this.rpcClient = nil
+	this.info = nil
+	stopping := this.stopping
+	// 稳定运行足够长的时间，重启次数清零
+	if !this.readyTime.IsZero() && time.Since(this.readyTime) >= this.conf.RestartWindow {
+		this.restarts = 0
+	}
+	this.readyTime = time.Time{}
+	this.mutex.Unlock()
+
+	if rpcClient != nil {
+		rpcClient.Close()
+	}
+	if stopping {
+		this.setState(StateStopped, nil)
+		return
+	}
+	if err == nil {
+		err = errors.New("plugin exited unexpectedly")
+	}
+	this.setState(StateCrashed, err)
+	this.restart()
+}
+
+// 按重启策略退避后重启插件
+func (this *S_Client) restart() {
+	this.mutex.Lock()
+	maxRestarts := this.conf.MaxRestarts
+	if maxRestarts == 0 || (maxRestarts > 0 && this.restarts >= maxRestarts) {
+		this.mutex.Unlock()
+		if maxRestarts != 0 {
+			fslog.Errorf("plugin %q has been restarted %d times, give up", this.conf.Cmd.Path, maxRestarts)
+		}
+		return
+	}
+	this.restarts++
+	backoff := this.conf.MinBackoff
+	for i := 1; i < this.restarts && backoff < this.conf.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > this.conf.MaxBackoff {
+		backoff = this.conf.MaxBackoff
+	}
+	restarts := this.restarts
+	this.mutex.Unlock()
+
+	go func() {
+		select {
+		case <-this.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		fslog.Infof("restart plugin %q (%d)", this.conf.Cmd.Path, restarts)
+		if err := this.Start(); err != nil {
+			if err != ErrStopped {
+				this.restart()
+			}
+			return
+		}
+		if err := this.dial(); errors.Is(err, ErrIncompatible) {
+			this.refuse(err)
+		} else if err != nil {
+			// 杀掉连不上的插件，进程结束后再次按重启策略重启
+			fslog.Errorf("dial restarted plugin %q fail: %v", this.conf.Cmd.Path, err)
+			this.killProcess()
+		}
+	}()
+}
+
+// 拒绝不兼容的插件，停止并且不再重启
+func (this *S_Client) refuse(err error) {
+	fslog.Errorf("refuse plugin %q: %v", this.conf.Cmd.Path, err)
+	this.Stop()
+}
+
+// 逐步升级地结束插件进程：请求退出 -> SIGTERM -> SIGKILL
+func (this *S_Client) stopProcess(process *os.Process, exited chan struct{}, rpcClient *rpc.Client) error {
+	wait := func(timeout time.Duration) bool {
+		select {
+		case <-exited:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+
+	// 插件处理完正在处理的调用后才退出，所以进程结束后再关闭 rpc 客户端，以便收到这些调用的回复
+	if rpcClient != nil {
+		defer rpcClient.Close()
+	}
+
+	timeout := time.Now().Add(this.conf.StopTimeout)
+	requested := false
+	if rpcClient != nil {
+		call := rpcClient.Go(_pluginServiceName+".Shutdown", 0, new(int), make(chan *rpc.Call, 1))
+		select {
+		case <-call.Done:
+			requested = call.Error == nil
+			if !requested {
+				fslog.Warnf("request plugin %q to shutdown fail: %v", this.conf.Cmd.Path, call.Error)
+			}
+		case <-exited:
+			requested = true
+		case <-time.After(this.conf.StopTimeout):
+		}
+	}
+	if requested && wait(time.Until(timeout)) {
+		return nil
+	}
+
+	fslog.Warnf("plugin %q doesn't exit in time, send SIGTERM", this.conf.Cmd.Path)
+	if err := process.Signal(syscall.SIGTERM); err == nil && wait(this.conf.TermTimeout) {
+		return nil
+	}
+
+	fslog.Warnf("plugin %q doesn't exit after SIGTERM, kill it", this.conf.Cmd.Path)
+	err := process.Kill()
+	<-exited
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+// 杀掉当前插件进程
+func (this *S_Client) killProcess() {
+	this.mutex.Lock()
+	process := this.process
+	this.mutex.Unlock()
+	if process != nil {
+		process.Kill()
+	}
+}
+
+// 定时健康检查，插件进程结束或者停止时退出
+func (this *S_Client) healthLoop(rpcClient *rpc.Client, exited chan struct{}) {
+	ticker := time.NewTicker(this.conf.HealthCheckInterval)
+	defer ticker.Stop()
+	fails := 0
+	for {
+		select {
+		case <-exited:
+			return
+		case <-this.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		var reply string
+		var err error
+		call := rpcClient.Go(_pluginServiceName+".Ping", "ping", &reply, make(chan *rpc.Call, 1))
+		select {
+		case <-call.Done:
+			err = call.Error
+		case <-time.After(this.conf.HealthCheckTimeout):
+			err = errHealthCheckTimeout
+		}
+		if err == nil {
+			fails = 0
+			continue
+		}
+		fails++
+		fslog.Warnf("plugin %q health check fail(%d/%d): %v", this.conf.Cmd.Path, fails, this.conf.HealthCheckFails, err)
+		if fails >= this.conf.HealthCheckFails {
+			this.mutex.Lock()
+			current := this.exited == exited
+			this.mutex.Unlock()
+			if current {
+				fslog.Errorf("plugin %q is unhealthy, kill it", this.conf.Cmd.Path)
+				this.killProcess()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package fsrpcplugin
+
+import (
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 等待插件崩溃后重启，返回从崩溃到开始重启的时间
+func waitRestart(t *testing.T, sl *s_StateLog) time.Duration {
+	waitState(t, sl, StateCrashed, 3*time.Second)
+	start := time.Now()
+	waitState(t, sl, StateStarting, 3*time.Second)
+	cost := time.Since(start)
+	waitState(t, sl, StateReady, 3*time.Second)
+	return cost
+}
+
+// 崩溃后退避重启，退避时间翻倍并且不超过 MaxBackoff，超过重启次数后不再重启
+func TestRestart(t *testing.T) {
+	client, sl := startTestClient(t, &S_ClientConfig{
+		MaxRestarts: 3,
+		MinBackoff:  150 * time.Millisecond,
+		MaxBackoff:  250 * time.Millisecond,
+	})
+	var reply string
+	if err := client.Call("Echo.Say", "a", &reply); err != nil || reply != "echo:a" {
+		t.Fatal(err, reply)
+	}
+
+	for _, backoff := range []time.Duration{150 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond} {
+		pid := pluginPid(client)
+		client.Call("Echo.Crash", "", &reply)
+		if cost := waitRestart(t, sl); cost < backoff-20*time.Millisecond {
+			t.Fatalf("restart after %v, expect backoff %v", cost, backoff)
+		}
+		if pluginPid(client) == pid {
+			t.Fatal("plugin process is not restarted")
+		}
+		if err := client.Call("Echo.Say", "b", &reply); err != nil || reply != "echo:b" {
+			t.Fatal(err, reply)
+		}
+	}
+
+	// 超过重启次数，不再重启
+	client.Call("Echo.Crash", "", &reply)
+	waitState(t, sl, StateCrashed, 3*time.Second)
+	time.Sleep(500 * time.Millisecond)
+	if state := client.State(); state != StateCrashed {
+		t.Fatal("plugin should stay crashed, but is", state)
+	}
+	if err := client.Call("Echo.Say", "c", &reply); err == nil {
+		t.Fatal("call to crashed plugin should fail")
+	}
+
+	client.Stop()
+	waitState(t, sl, StateStopped, 3*time.Second)
+}
+
+// 插件不响应健康检查时被杀掉并重启
+func TestHealthCheck(t *testing.T) {
+	client, sl := startTestClient(t, &S_ClientConfig{
+		MaxRestarts:         -1,
+		MinBackoff:          50 * time.Millisecond,
+		HealthCheckInterval: 50 * time.Millisecond,
+		HealthCheckTimeout:  50 * time.Millisecond,
+		HealthCheckFails:    2,
+	})
+	time.Sleep(300 * time.Millisecond)
+	if state := client.State(); state != StateReady {
+		t.Fatal("healthy plugin should be ready, but is", state)
+	}
+
+	pid := pluginPid(client)
+	syscall.Kill(pid, syscall.SIGSTOP)
+	waitRestart(t, sl)
+	if pluginPid(client) == pid {
+		t.Fatal("unhealthy plugin is not restarted")
+	}
+	var reply string
+	if err := client.Call("Echo.Say", "c", &reply); err != nil || reply != "echo:c" {
+		t.Fatal(err, reply)
+	}
+
+	client.Stop()
+	waitState(t, sl, StateStopped, 3*time.Second)
+	time.Sleep(200 * time.Millisecond)
+	if state := client.State(); state != StateStopped {
+		t.Fatal("stopped plugin should not be restarted, but is", state)
+	}
+}
+
+// 启动期间调用 Stop，Start 杀掉刚启动的进程，不留下孤儿进程
+func TestStopWhileStarting(t *testing.T) {
+	var client *S_Client
+	var once sync.Once
+	sl := &s_StateLog{ch: make(chan T_State, 100)}
+	client, _ = newTestClient(t, &S_ClientConfig{
+		OnStateChange: func(state T_State, err error) {
+			sl.add(state)
+			if state == StateStarting {
+				once.Do(func() { client.Stop() })
+			}
+		},
+	}, "1")
+
+	if err := client.Start(); err != ErrStopped {
+		t.Fatal("expect ErrStopped, got", err)
+	}
+	if state := client.State(); state != StateStopped {
+		t.Fatal("expect stopped, got", state)
+	}
+	if pid := pluginPid(client); pid != 0 {
+		t.Fatal("plugin process is left running:", pid)
+	}
+}
+
+// 宿主进程启动插件时不删除其他进程正在使用的 socket 文件
+func TestStartSocketInUse(t *testing.T) {
+	client, _ := newTestClient(t, &S_ClientConfig{MaxRestarts: -1}, "1")
+	ln, err := net.Listen("unix", client.conf.SocketFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := client.Start(); err == nil {
+		t.Fatal("start plugin on the socket in use should fail")
+	}
+	if pid := pluginPid(client); pid != 0 {
+		t.Fatal("plugin process should not be started:", pid)
+	}
+	conn, err := net.Dial("unix", client.conf.SocketFile)
+	if err != nil {
+		t.Fatal("socket file in use is removed:", err)
+	}
+	conn.Close()
+}